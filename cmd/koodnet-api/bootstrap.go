@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// bootstrapToken creates an API token from the command line, so the first admin
+// token can be minted before there is any token to call the API with.
+func bootstrapToken(args []string) {
+	fs := flag.NewFlagSet("bootstrap-token", flag.ExitOnError)
+	name := fs.String("name", "bootstrap", "Name of the API token")
+	scopes := fs.String("scopes", models.ScopeAdmin, "Comma separated scopes of the API token")
+	connect(loadConfig(fs, args))
+
+	t, raw, err := models.NewAPIToken(*name, strings.Split(*scopes, ","), nil)
+	if err != nil {
+		log.Fatalf("Failed to generate API token: %v", err)
+	}
+
+	if err := database.Conn.Create(t).Error; err != nil {
+		log.Fatalf("Failed to save API token: %v", err)
+	}
+
+	fmt.Println(raw)
+}
@@ -1,41 +1,97 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/koodeyo/koodnet/pkg/api"
+	"github.com/koodeyo/koodnet/pkg/config"
 	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/rpc"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"github.com/koodeyo/koodnet/pkg/webhooks"
 	"github.com/sirupsen/logrus"
 )
 
 func init() {
 	// Load Env
 	godotenv.Load()
+}
+
+// loadConfig parses the flags of the configuration and any others defined on fs, and loads the configuration.
+func loadConfig(fs *flag.FlagSet, args []string) *config.Config {
+	flags := config.NewFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := flags.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Connect to database
-	database.Connect()
+	return cfg
+}
 
-	// Migrate
+// connect connects to the database of cfg and migrates it.
+func connect(cfg *config.Config) {
+	database.Connect(cfg.Database)
 	database.Migrate()
 }
 
-func listenAddress() string {
-	lport := os.Getenv("KOODNET_LISTEN_PORT")
-	laddress := os.Getenv("KOODNET_LISTEN_ADDRESS")
+// newLogger returns the logger of requests and events, with the level and format of cfg.
+func newLogger(cfg *config.Config) *logrus.Logger {
+	l := logrus.New()
+	l.SetLevel(cfg.LogLevel())
+
+	switch cfg.Log.Format {
+	case "json":
+		l.Formatter = &logrus.JSONFormatter{}
+	default:
+		l.Formatter = &logrus.TextFormatter{
+			FullTimestamp: true,
+		}
+	}
+
+	return l
+}
+
+// serveGRPC serves the gRPC API alongside the REST API, over TLS when cfg has a certificate,
+// and rate limited by the policies of the REST API.
+func serveGRPC(l *logrus.Logger, cfg *config.Config) {
+	var opts rpc.Options
 
-	if lport == "" {
-		lport = "8001"
+	limiter, err := api.NewRateLimiter(cfg.RateLimits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.RateLimiter = limiter
+
+	if cfg.Listen.GRPCTLSCert != "" {
+		crt, err := tls.LoadX509KeyPair(cfg.Listen.GRPCTLSCert, cfg.Listen.GRPCTLSKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.TLS = &tls.Config{Certificates: []tls.Certificate{crt}, MinVersion: tls.VersionTLS12}
+	} else if cfg.Env == config.EnvProduction {
+		l.Warn("Serving the gRPC API without TLS, set listen.grpc_tls_cert and listen.grpc_tls_key to protect its API tokens")
 	}
 
-	if laddress == "" {
-		laddress = ":"
+	lis, err := net.Listen("tcp", cfg.Listen.GRPCAddress())
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	return laddress + lport
+	l.Infof("Serving the gRPC API on %s", lis.Addr())
+
+	if err := rpc.NewServer(l, opts).Serve(lis); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // @title           Koodnet API
@@ -53,31 +109,52 @@ func listenAddress() string {
 // @host      localhost:8001
 // @BasePath  /api/v1
 
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 API token, sent as "Bearer <token>". Create the first one with "koodnet-api bootstrap-token".
+
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 func main() {
-	l := logrus.New()
-
-	l.Formatter = &logrus.TextFormatter{
-		FullTimestamp: true,
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-token" {
+		bootstrapToken(os.Args[2:])
+		return
 	}
 
-	koodnetEnv := os.Getenv("KOODNET_ENV") // Get the environment (e.g., "development", "production")
+	cfg := loadConfig(flag.NewFlagSet("koodnet-api", flag.ExitOnError), os.Args[1:])
 
-	// Dynamically set Gin mode based on KOODNET_ENV
-	switch koodnetEnv {
-	case "production":
+	l := newLogger(cfg)
+
+	// Set Gin mode based on the environment
+	switch cfg.Env {
+	case config.EnvProduction:
 		gin.SetMode(gin.ReleaseMode)
-		l.SetLevel(logrus.InfoLevel)
 		fmt.Println("Running in production mode")
 	default:
-		gin.SetMode(gin.DebugMode) // Default to debug mode
-		l.SetLevel(logrus.DebugLevel)
+		gin.SetMode(gin.DebugMode)
 	}
 
-	r := api.NewRouter(l)
+	connect(cfg)
+
+	r, err := api.NewRouter(l, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	service.ConfigWatchInterval = cfg.ConfigWatch.Interval
+
+	// Send webhook events from the outbox
+	go webhooks.NewDispatcher(database.Conn, l, cfg.Webhooks).Run(context.Background())
+
+	// Purge deleted networks and hosts once they can no longer be restored
+	go purgeDeleted(l, cfg.Deleted.Retention)
+
+	if cfg.Listen.GRPCAddress() != "" {
+		go serveGRPC(l, cfg)
+	}
 
-	if err := r.Run(listenAddress()); err != nil {
+	if err := r.Run(cfg.Listen.RESTAddress()); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+const purgeInterval = time.Hour
+
+// purgeDeleted removes the networks and hosts whose retention period has passed, every hour.
+func purgeDeleted(l *logrus.Logger, retention time.Duration) {
+	for {
+		purged, err := models.PurgeDeleted(database.Conn, time.Now().Add(-retention))
+		if err != nil {
+			l.WithError(err).Error("Failed to purge deleted networks and hosts")
+		} else if purged > 0 {
+			l.WithField("purged", purged).Info("Purged deleted networks and hosts")
+		}
+
+		time.Sleep(purgeInterval)
+	}
+}
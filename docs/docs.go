@@ -47,8 +47,215 @@ const docTemplate = `{
                 }
             }
         },
+        "/apply": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Bring a network and its hosts to the state described by a JSON or YAML spec, e.g. kept in git.\nThe network is matched by name within the organization and created when missing. Of existing networks only\nsshUsers are updated, as the IPs, subnets and groups constrain the CA and cannot change. Hosts are matched by name:\nhosts of the spec are created or updated, and hosts of the network that are not in the spec are deleted.\nCertificates of hosts whose IP, groups, subnets or public key change are signed anew.\nHost configs are the defaults with the \"defaults\" of the spec and then the \"configuration\" of the host merged in,\nplus the rules of the firewall policies the host lists. All changes are made in one transaction.\nWith ?dryRun=true the plan is returned with the config diff of every affected host, without applying it.",
+                "consumes": [
+                    "application/json",
+                    "application/x-yaml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Apply a network spec",
+                "parameters": [
+                    {
+                        "description": "Network spec",
+                        "name": "spec",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.NetworkSpec"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the plan without applying it",
+                        "name": "dryRun",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.applyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid spec, with the path of the invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the audit events of every create, update and delete of networks, hosts and certificates, and every config download, newest first.\nTokens confined to an organization only see the events of its networks.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Get the audit log",
+                "parameters": [
+                    {
+                        "enum": [
+                            "create",
+                            "update",
+                            "delete",
+                            "restore",
+                            "download"
+                        ],
+                        "type": "string",
+                        "description": "Filter by action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "network",
+                            "host",
+                            "certificate"
+                        ],
+                        "type": "string",
+                        "description": "Filter by resource type",
+                        "name": "resourceType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by resource ID",
+                        "name": "resourceId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by network ID",
+                        "name": "networkId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by the ID of the API token that made the change",
+                        "name": "actorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by request ID",
+                        "name": "requestId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events at or after this time (RFC 3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events before this time (RFC 3339)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "pageSize for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.paginatedResponse-models_AuditEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit/verify": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Recompute the hash chain of the audit log and report the first event that was changed, removed or inserted out of order.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Verify the audit log",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AuditChainStatus"
+                        }
+                    }
+                }
+            }
+        },
         "/certificates": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Get a list of all certificates with optional pagination",
                 "produces": [
                     "application/json"
@@ -58,6 +265,47 @@ const docTemplate = `{
                 ],
                 "summary": "Get all certificates",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by the ID of the owning network or host",
+                        "name": "ownerId",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "networks",
+                            "hosts"
+                        ],
+                        "type": "string",
+                        "description": "Filter by owner type",
+                        "name": "ownerType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter CAs",
+                        "name": "isCa",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter expired certificates",
+                        "name": "expired",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only certificates expiring at or before this time (RFC 3339)",
+                        "name": "expiresBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Comma-separated sort fields, prefixed with - for descending: notBefore, notAfter, createdAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "default": 1,
@@ -75,17 +323,28 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Private keys are only included with the keys:read scope",
                         "schema": {
                             "$ref": "#/definitions/api.paginatedResponse-models_Certificate"
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
                     }
                 }
             }
         },
         "/hosts": {
             "get": {
-                "description": "Get a list of all hosts with optional pagination",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a list of all hosts with optional filters, sorting and pagination.\nLarge lists are better paged with ?cursor and ?limit, ordered by creation, which skip no hosts while hosts are added.",
                 "produces": [
                     "application/json"
                 ],
@@ -94,6 +353,67 @@ const docTemplate = `{
                 ],
                 "summary": "Get all hosts",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by network ID",
+                        "name": "networkId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by exact name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by names containing the value, case-insensitive",
+                        "name": "name~",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by site",
+                        "name": "site",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by overlay IP, or by a CIDR containing it, e.g. 100.100.0.0/24",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter lighthouses",
+                        "name": "lighthouse",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter relays",
+                        "name": "relay",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List the deleted hosts instead, which can be restored until they are purged",
+                        "name": "deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Comma-separated sort fields, prefixed with - for descending: name, ip, site, createdAt, updatedAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "default": 1,
@@ -107,6 +427,25 @@ const docTemplate = `{
                         "description": "pageSize for pagination",
                         "name": "pageSize",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page by cursor instead, starting after the item of the cursor: empty for the first page, then the nextCursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Page size of cursor pagination",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count the total, which is done by default with pages and not with cursors",
+                        "name": "count",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -115,10 +454,21 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.paginatedResponse-models_Host"
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
                     }
                 }
             },
             "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Create a host with the provided details",
                 "consumes": [
                     "application/json"
@@ -139,9 +489,27 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.HostDto"
                         }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the config changes of every host in the network without creating the host",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "Dry run",
+                        "schema": {
+                            "$ref": "#/definitions/api.dryRunResponse"
+                        }
+                    },
                     "201": {
                         "description": "Created",
                         "schema": {
@@ -159,6 +527,11 @@ const docTemplate = `{
         },
         "/hosts/{id}": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Retrieve details of a single host",
                 "produces": [
                     "application/json"
@@ -192,6 +565,11 @@ const docTemplate = `{
                 }
             },
             "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Update the details of an existing host",
                 "consumes": [
                     "application/json"
@@ -219,13 +597,25 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.HostDto"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the host as last read. The update fails with 412 if the host changed since",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the config changes of every host in the network without updating the host",
+                        "name": "dryRun",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Dry run",
                         "schema": {
-                            "$ref": "#/definitions/models.Host"
+                            "$ref": "#/definitions/api.dryRunResponse"
                         }
                     },
                     "400": {
@@ -239,11 +629,22 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.errorResponse"
                         }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
                     }
                 }
             },
             "delete": {
-                "description": "Delete a host by ID",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a host by ID. It can be restored until it is purged after the retention period (KOODNET_DELETED_RETENTION, 30 days by default),\nwhich also removes its certificate and configuration for good. Its name and IP can be reused meanwhile, which prevents restoring it.",
                 "tags": [
                     "hosts"
                 ],
@@ -278,6 +679,11 @@ const docTemplate = `{
         },
         "/hosts/{id}/config.yml": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Retrieve the YAML configuration of a single host by its ID. Optionally, download the configuration as a file.",
                 "produces": [
                     "application/x-yaml"
@@ -306,6 +712,75 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "type": "string"
+                        },
+                        "headers": {
+                            "X-Koodnet-Config-Revision": {
+                                "type": "string",
+                                "description": "Revision of the config, for /hosts/{id}/config/watch"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/hosts/{id}/config/watch": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Wait for the rendered config of a host to differ from the given revision, instead of polling config.yml.\nWith \"Accept: text/event-stream\", the connection stays open as Server-Sent Events: a \"config\" event with the revision as its ID\nis sent whenever the config changes, and a \"deleted\" event when the host is deleted. Reconnecting clients resume with Last-Event-ID.\nOtherwise the request long-polls: it returns the new revision as soon as it differs from the given one, or 304 when the timeout passes first.\nThe revision of a config is also returned in the X-Koodnet-Config-Revision header of config.yml.",
+                "produces": [
+                    "application/json",
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Watch a host's configuration for changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision of the config the agent has. Defaults to the Last-Event-ID header",
+                        "name": "revision",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "30s",
+                        "description": "How long a long-poll waits, as a duration of at most 5m",
+                        "name": "timeout",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.configRevisionEvent"
+                        }
+                    },
+                    "304": {
+                        "description": "Unchanged when the timeout passed"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
                         }
                     },
                     "404": {
@@ -317,8 +792,59 @@ const docTemplate = `{
                 }
             }
         },
+        "/hosts/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore a deleted host with its certificate and configuration. Deleted hosts are listed with ?deleted=true.\nHosts deleted with their network are restored by restoring the network.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Restore a deleted host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Host"
+                        }
+                    },
+                    "404": {
+                        "description": "The host does not exist or is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The network is deleted, or a host with the same name or IP was created meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/networks": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Get a list of all networks with optional pagination",
                 "produces": [
                     "application/json"
@@ -328,6 +854,53 @@ const docTemplate = `{
                 ],
                 "summary": "Get all networks",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by organization ID",
+                        "name": "organizationId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by exact name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by names containing the value, case-insensitive",
+                        "name": "name~",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "25519",
+                            "P256"
+                        ],
+                        "type": "string",
+                        "description": "Filter by curve",
+                        "name": "curve",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List the deleted networks instead, which can be restored until they are purged",
+                        "name": "deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Comma-separated sort fields, prefixed with - for descending: name, createdAt, updatedAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "default": 1,
@@ -349,10 +922,21 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.paginatedResponse-models_Network"
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
                     }
                 }
             },
             "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Create a network with the provided details",
                 "consumes": [
                     "application/json"
@@ -373,6 +957,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.NetworkDto"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -391,8 +981,111 @@ const docTemplate = `{
                 }
             }
         },
+        "/networks/import": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Import a network exported with GET /networks/{id}/export, with its CAs, hosts, configurations and certificates.\nIDs are kept by default, so that agents and integrations keep working after moving the network between instances.\nWith ?ids=remap everything gets new IDs, e.g. to import a copy next to the exported network under another ?name.\nThe caller becomes the owner of the imported network.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Import a network",
+                "parameters": [
+                    {
+                        "description": "Network export",
+                        "name": "export",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.NetworkExport"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Passphrase of an encrypted export",
+                        "name": "X-Koodnet-Passphrase",
+                        "in": "header"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "remap"
+                        ],
+                        "type": "string",
+                        "default": "keep",
+                        "description": "Keep the IDs of the export or give everything new IDs",
+                        "name": "ids",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Organization to import the network into",
+                        "name": "organizationId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the imported network, instead of its exported name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Network"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid export, or a missing or wrong passphrase",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The network, a host, certificate or configuration with the same ID or name exists already",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unsupported export version",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/networks/{id}": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Retrieve details of a single network",
                 "produces": [
                     "application/json"
@@ -426,7 +1119,12 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Delete a network by ID",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a network by ID, together with its hosts. They can be restored until they are purged after the retention period\n(KOODNET_DELETED_RETENTION, 30 days by default), which also removes their CAs, certificates and configurations for good.\nA network that still has hosts is only deleted with ?confirm set to its name.",
                 "tags": [
                     "networks"
                 ],
@@ -438,6 +1136,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the network, required when it still has hosts",
+                        "name": "confirm",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -455,10 +1159,21 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.errorResponse"
                         }
+                    },
+                    "409": {
+                        "description": "The network has hosts and ?confirm does not match its name",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
                     }
                 }
             },
             "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Update the details of an existing network",
                 "consumes": [
                     "application/json"
@@ -486,13 +1201,25 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.NetworkDto"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the network as last read. The update fails with 412 if the network changed since",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the config changes of every host in the network without updating it",
+                        "name": "dryRun",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Dry run",
                         "schema": {
-                            "$ref": "#/definitions/models.Network"
+                            "$ref": "#/definitions/api.dryRunResponse"
                         }
                     },
                     "400": {
@@ -506,303 +1233,3013 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.errorResponse"
                         }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.apiError": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                }
-            }
         },
-        "api.errorResponse": {
-            "type": "object",
-            "properties": {
-                "errors": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/api.apiError"
+        "/networks/{id}/ca/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue a new CA for a network. Hosts signed afterwards get certificates of the new CA, while the previous CAs\nstay trusted until they expire. Sends the ca.rotated webhook event, and config.changed for every host.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Rotate the CA of a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The key is only included with the keys:read scope",
+                        "schema": {
+                            "$ref": "#/definitions/models.Certificate"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Export the network with its CAs, hosts, configurations and certificates, including their private keys,\nfor offline backups and for importing it into another koodnet instance with POST /networks/import.\nThe export is encrypted with AES-256-GCM, keyed by the Argon2id hash of the passphrase, when one is sent\nin the X-Koodnet-Passphrase header. Deleted hosts are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Export a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Passphrase to encrypt the export with",
+                        "name": "X-Koodnet-Passphrase",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.NetworkExport"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/hosts": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a list of the hosts of a network with optional filters, sorting and pagination.\nLarge lists are better paged with ?cursor and ?limit, ordered by creation, which skip no hosts while hosts are added.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Get the hosts of a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by exact name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by names containing the value, case-insensitive",
+                        "name": "name~",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by site",
+                        "name": "site",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by overlay IP, or by a CIDR containing it, e.g. 100.100.0.0/24",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter lighthouses",
+                        "name": "lighthouse",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter relays",
+                        "name": "relay",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List the deleted hosts instead, which can be restored until they are purged",
+                        "name": "deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Comma-separated sort fields, prefixed with - for descending: name, ip, site, createdAt, updatedAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "pageSize for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page by cursor instead, starting after the item of the cursor: empty for the first page, then the nextCursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Page size of cursor pagination",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count the total, which is done by default with pages and not with cursors",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.paginatedResponse-models_Host"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a host in the network of the path. A networkId in the payload is ignored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Create a host in a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Host Payload",
+                        "name": "host",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.HostDto"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the config changes of every host in the network without creating the host",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dry run",
+                        "schema": {
+                            "$ref": "#/definitions/api.dryRunResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Host"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/hosts/{hostName}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve details of a single host by its name, which is unique within the network",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Get a host of a network by name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "hostName",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Host"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/hosts:batch": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create hosts from a JSON array of hosts, a CSV or YAML body, or a multipart upload of such a file in the \"file\" field.\nHosts without an IP get the next free address of the network. All hosts are created in one transaction:\nwhen any row fails, no host is created and the errors of every failing row are returned with status 422.\nCSV files need a header row with any of the columns name, ip, site, groups, subnets, lighthouses, relays, staticAddresses and inPub.\nList columns separate their values with \";\".",
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-yaml",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Create many hosts in a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Hosts",
+                        "name": "hosts",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.HostDto"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.batchHostsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.batchHostsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/prometheus-sd.json": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the nebula stats endpoints of every host in a network as Prometheus file_sd/http_sd target groups.\nOnly hosts with prometheus stats enabled are listed. Targets use the host's overlay IP and stats port.\nGroups are joined with commas and wrapped in leading and trailing commas, e.g. \",laptop,ssh,\".",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Prometheus service discovery targets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.prometheusTargetGroup"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore a deleted network together with the hosts that were deleted with it. Deleted networks are listed with ?deleted=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Restore a deleted network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Network"
+                        }
+                    },
+                    "404": {
+                        "description": "The network does not exist or is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A network or host with the same name or IP was created meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/roles": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List which API tokens hold which role on a network",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get the role bindings of a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.RoleBinding"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Grant an API token a role (owner, operator, viewer or enroller) on a network",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Grant a role on a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Role Payload",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RoleBindingDto"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoleBinding"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/roles/{roleId}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a role binding of a network by ID",
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Revoke a role on a network",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role binding ID",
+                        "name": "roleId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Delete status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/networks/{id}/sshd": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Enable or disable the nebula sshd on every host of a network, optionally limited to hosts in the given groups.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "networks"
+                ],
+                "summary": "Turn the nebula sshd on or off",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "SSHD toggle",
+                        "name": "sshd",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SSHDToggleDto"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Host"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a list of the organizations visible to the caller with optional pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Get all organizations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "pageSize for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.paginatedResponse-models_Organization"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create an organization with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Create a new organization",
+                "parameters": [
+                    {
+                        "description": "Organization Payload",
+                        "name": "organization",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.OrganizationDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Organization"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve details of a single organization",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Get an organization by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Organization"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete an organization by ID. Organizations that still have networks, including deleted networks that are not purged yet, cannot be deleted.",
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Delete an organization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Delete status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The organization still has networks",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the name or quotas of an existing organization",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Update an organization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated organization details",
+                        "name": "organization",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.OrganizationDto"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Organization"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{id}/networks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a list of the organization's networks visible to the caller with optional pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Get the networks of an organization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by exact name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by names containing the value, case-insensitive",
+                        "name": "name~",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "25519",
+                            "P256"
+                        ],
+                        "type": "string",
+                        "description": "Filter by curve",
+                        "name": "curve",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Comma-separated sort fields, prefixed with - for descending: name, createdAt, updatedAt",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "pageSize for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.paginatedResponse-models_Network"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a network owned by the organization with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Create a network in an organization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Network Payload",
+                        "name": "network",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.NetworkDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Network"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tokens": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a list of all API tokens with optional pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Get all API tokens",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "pageSize for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.paginatedResponse-models_APIToken"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create an API token with the given scopes. The token is only returned in this response.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Create a new API token",
+                "parameters": [
+                    {
+                        "description": "Token Payload",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.APITokenDto"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.apiTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tokens/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke an API token by ID. Revoked tokens are kept for reference but can no longer authenticate.",
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Revoke an API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Revoke status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a list of the webhooks visible to the caller with optional pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get all webhooks",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "pageSize for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.paginatedResponse-models_Webhook"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Register an endpoint that receives events as signed JSON POST requests. The signing secret is only returned in this response.\nRequests carry the headers X-Koodnet-Event, X-Koodnet-Delivery and X-Koodnet-Signature, which is \"t=\u003cunix time\u003e,v1=\u003chex HMAC-SHA256 of \"\u003ct\u003e.\u003cbody\u003e\"\u003e\".\nDeliveries that fail or get a non-2xx response are retried with exponential backoff for about 20 hours.\nWebhooks created with an organization-confined token only get the events of that organization's networks.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Create a new webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook Payload",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve details of a single webhook",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get a webhook by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a webhook and its pending deliveries by ID",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Delete status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the URL, events or network of a webhook, or turn it on or off",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Update a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated webhook details",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookDto"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the events sent or queued for a webhook, newest first, with the outcome of the last attempt",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get the deliveries of a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "delivered",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "pageSize for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.paginatedResponse-models_WebhookDelivery"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.errorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.apiError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "description": "JSON path of the invalid value, for validation errors.",
+                    "type": "string",
+                    "example": "staticAddresses[0].port"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.apiTokenResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "organizationId": {
+                    "description": "Organization the token is confined to. Tokens without one are platform-wide.",
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "First characters of the token, to recognize it without storing it.",
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "token": {
+                    "type": "string",
+                    "example": "knt_3q2-7wX..."
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.applyResponse": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PlanAction"
+                    }
+                },
+                "applied": {
+                    "description": "False for plans.",
+                    "type": "boolean"
+                },
+                "diffs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ConfigDiff"
+                    }
+                },
+                "networkId": {
+                    "description": "Not set in plans of networks that are yet to be created.",
+                    "type": "string"
+                }
+            }
+        },
+        "api.batchHostResult": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.apiError"
+                    }
+                },
+                "host": {
+                    "$ref": "#/definitions/models.Host"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "host-1"
+                },
+                "row": {
+                    "description": "1-based position of the host in the payload, or line in a CSV file after the header.",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.batchHostsResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "Number of hosts created. 0 when any row failed, as the batch is then rolled back.",
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.batchHostResult"
+                    }
+                }
+            }
+        },
+        "api.configRevisionEvent": {
+            "type": "object",
+            "properties": {
+                "hostId": {
+                    "type": "string"
+                },
+                "revision": {
+                    "description": "Changes with the rendered config. Fetch the config from /hosts/{id}/config.yml.",
+                    "type": "string",
+                    "example": "5f0c6e1b9a3d4c2e8b7a6f5e4d3c2b1a"
+                }
+            }
+        },
+        "api.dryRunResponse": {
+            "type": "object",
+            "properties": {
+                "diffs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ConfigDiff"
+                    }
+                }
+            }
+        },
+        "api.errorResponse": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.apiError"
+                    }
+                }
+            }
+        },
+        "api.healthResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.metadata": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "description": "Page size of cursor pagination.",
+                    "type": "integer"
+                },
+                "nextCursor": {
+                    "description": "Cursor of the next page, left out on the last page.",
+                    "type": "string"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "Total represents the total number of items. Left out when not counted.",
+                    "type": "integer"
+                },
+                "totalPages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.paginatedResponse-models_APIToken": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.APIToken"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.paginatedResponse-models_AuditEvent": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditEvent"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.paginatedResponse-models_Certificate": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Certificate"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.paginatedResponse-models_Host": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Host"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.paginatedResponse-models_Network": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Network"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.paginatedResponse-models_Organization": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Organization"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.paginatedResponse-models_Webhook": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Webhook"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.paginatedResponse-models_WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data contains the actual collection of items.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WebhookDelivery"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata contains additional info like the total count.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.metadata"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.prometheusTargetGroup": {
+            "type": "object",
+            "properties": {
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "targets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "100.100.0.1:8080"
+                    ]
+                }
+            }
+        },
+        "api.webhookResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Inactive webhooks get no new events.",
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Events to send. Empty means all.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "networkId": {
+                    "description": "Only send events of this network.",
+                    "type": "string"
+                },
+                "organizationId": {
+                    "description": "Only send events of this organization's networks. Webhooks without one get the events of every network.",
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Key of the HMAC-SHA256 signature in the X-Koodnet-Signature header.",
+                    "type": "string",
+                    "example": "whsec_9Jf2..."
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.APIToken": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "organizationId": {
+                    "description": "Organization the token is confined to. Tokens without one are platform-wide.",
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "First characters of the token, to recognize it without storing it.",
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.APITokenDto": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "expiresAt": {
+                    "type": "string",
+                    "example": "2030-01-01T00:00:00Z"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ci"
+                },
+                "organizationId": {
+                    "type": "string",
+                    "example": "c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "networks:read",
+                        "hosts:write"
+                    ]
+                }
+            }
+        },
+        "models.ArchivedHost": {
+            "type": "object",
+            "properties": {
+                "certificate": {
+                    "$ref": "#/definitions/models.Certificate"
+                },
+                "configuration": {
+                    "$ref": "#/definitions/models.Configuration"
+                },
+                "configurationId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "description": "Set while the host is deleted, until it is restored or purged.",
+                    "type": "string"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "inPub": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "lighthouses": {
+                    "description": "Lighthouses this host uses, by host name or \"site:\u003cname\u003e\". Empty means all.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "network": {
+                    "$ref": "#/definitions/models.Network"
+                },
+                "networkId": {
+                    "type": "string"
+                },
+                "relays": {
+                    "description": "Relays peers may use to reach this host, by host name or \"site:\u003cname\u003e\". Empty means all.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "site": {
+                    "description": "Site or region the host belongs to, used by \"site:\u003cname\u003e\" selectors.",
+                    "type": "string"
+                },
+                "sshHostKey": {
+                    "type": "string"
+                },
+                "sshHostPub": {
+                    "description": "Public sshd host key in authorized_keys format, for known_hosts.",
+                    "type": "string"
+                },
+                "staticAddresses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StaticAddress"
+                    }
+                },
+                "subnets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Incremented on every update, for optimistic concurrency.",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AuditChainStatus": {
+            "type": "object",
+            "properties": {
+                "brokenAt": {
+                    "description": "Sequence of the first event that does not match the chain.",
+                    "type": "integer"
+                },
+                "events": {
+                    "type": "integer"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.AuditChange": {
+            "type": "object",
+            "properties": {
+                "after": {},
+                "before": {},
+                "field": {
+                    "type": "string",
+                    "example": "configuration.listen.port"
+                }
+            }
+        },
+        "models.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actorId": {
+                    "description": "API token that made the request.",
+                    "type": "string"
+                },
+                "actorName": {
+                    "type": "string"
+                },
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditChange"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "networkId": {
+                    "type": "string"
+                },
+                "organizationId": {
+                    "type": "string"
+                },
+                "prevHash": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "resourceId": {
+                    "type": "string"
+                },
+                "resourceType": {
+                    "type": "string"
+                },
+                "sequence": {
+                    "type": "integer"
+                },
+                "sourceIp": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CalculatedRemote": {
+            "type": "object",
+            "properties": {
+                "mask": {
+                    "description": "Mask defines the network range used to calculate the remote IP.",
+                    "type": "string",
+                    "example": "192.168.1.0/24"
+                },
+                "port": {
+                    "description": "Port defines the port to be used for the calculated remote IP.",
+                    "type": "integer",
+                    "example": 4242
+                }
+            }
+        },
+        "models.Certificate": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "crt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "isCa": {
+                    "type": "boolean"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "notAfter": {
+                    "type": "string"
+                },
+                "notBefore": {
+                    "type": "string"
+                },
+                "ownerId": {
+                    "type": "string"
+                },
+                "ownerType": {
+                    "type": "string"
+                },
+                "passphrase": {
+                    "type": "string"
+                },
+                "pub": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Configuration": {
+            "type": "object",
+            "properties": {
+                "cipher": {
+                    "description": "Cipher allows you to choose between the available ciphers for your network. Options are chachapoly or aes\nIMPORTANT: this value must be identical on ALL NODES/LIGHTHOUSES. We do not/will not support use of different ciphers simultaneously!",
+                    "type": "string",
+                    "example": "aes"
+                },
+                "firewall": {
+                    "description": "Nebula security group configuration",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.configFirewall"
+                        }
+                    ]
+                },
+                "handshakes": {
+                    "$ref": "#/definitions/models.configHandshakes"
+                },
+                "lighthouse": {
+                    "$ref": "#/definitions/models.configLighthouse"
+                },
+                "listen": {
+                    "description": "Port Nebula will be listening on. The default here is 4242. For a lighthouse node, the port should be defined,\nhowever using port 0 will dynamically assign a port and is recommended for roaming nodes.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.configListen"
+                        }
+                    ]
+                },
+                "logging": {
+                    "$ref": "#/definitions/models.configLogging"
+                },
+                "pki": {
+                    "description": "PKI defines the location of credentials for this node. Each of these can also be inlined by using the yaml \": |\" syntax.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.configPKI"
+                        }
+                    ]
+                },
+                "preferredRanges": {
+                    "description": "Preferred ranges is used to define a hint about the local network ranges, which speeds up discovering the fastest\npath to a network adjacent nebula node.\nThis setting is reloadable.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "172.16.0.0/24"
+                    ]
+                },
+                "punchy": {
+                    "$ref": "#/definitions/models.configPunchy"
+                },
+                "relay": {
+                    "description": "EXPERIMENTAL: relay support for networks that can't establish direct connections.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.configRelay"
+                        }
+                    ]
+                },
+                "routines": {
+                    "description": "Routines is the number of thread pairs to run that consume from the tun and UDP queues.\nCurrently, this defaults to 1 which means we have 1 tun queue reader and 1\nUDP queue reader. Setting this above one will set IFF_MULTI_QUEUE on the tun\ndevice and SO_REUSEPORT on the UDP socket to allow multiple queues.\nThis option is only supported on Linux.",
+                    "type": "integer",
+                    "example": 1
+                },
+                "sshd": {
+                    "description": "sshd can expose informational and administrative functions via ssh. This can expose informational and administrative\nfunctions, and allows manual tweaking of various network settings when debugging or testing.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.configSSHD"
+                        }
+                    ]
+                },
+                "staticMap": {
+                    "description": "The static_map config stanza can be used to configure how the static_host_map behaves.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.configStaticMap"
+                        }
+                    ]
+                },
+                "stats": {
+                    "$ref": "#/definitions/models.configStats"
+                },
+                "tun": {
+                    "description": "Configure the private interface. Note: addr is baked into the nebula certificate",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.configTun"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.ExportEncryption": {
+            "type": "object",
+            "properties": {
+                "cipher": {
+                    "type": "string",
+                    "example": "aes-256-gcm"
+                },
+                "iterations": {
+                    "type": "integer"
+                },
+                "kdf": {
+                    "type": "string",
+                    "example": "argon2id"
+                },
+                "memory": {
+                    "description": "Argon2 memory in KiB.",
+                    "type": "integer"
+                },
+                "nonce": {
+                    "type": "string"
+                },
+                "parallelism": {
+                    "type": "integer"
+                },
+                "salt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FirewallPolicy": {
+            "type": "object",
+            "properties": {
+                "inbound": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.configFirewallRule"
+                    }
+                },
+                "outbound": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.configFirewallRule"
+                    }
+                }
+            }
+        },
+        "models.Host": {
+            "type": "object",
+            "properties": {
+                "certificate": {
+                    "$ref": "#/definitions/models.Certificate"
+                },
+                "configuration": {
+                    "$ref": "#/definitions/models.Configuration"
+                },
+                "configurationId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "description": "Set while the host is deleted, until it is restored or purged.",
+                    "type": "string"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "inPub": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "lighthouses": {
+                    "description": "Lighthouses this host uses, by host name or \"site:\u003cname\u003e\". Empty means all.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
-                }
-            }
-        },
-        "api.healthResponse": {
-            "type": "object",
-            "properties": {
-                "message": {
+                },
+                "name": {
                     "type": "string"
                 },
-                "status": {
+                "network": {
+                    "$ref": "#/definitions/models.Network"
+                },
+                "networkId": {
+                    "type": "string"
+                },
+                "relays": {
+                    "description": "Relays peers may use to reach this host, by host name or \"site:\u003cname\u003e\". Empty means all.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "site": {
+                    "description": "Site or region the host belongs to, used by \"site:\u003cname\u003e\" selectors.",
+                    "type": "string"
+                },
+                "sshHostPub": {
+                    "description": "Public sshd host key in authorized_keys format, for known_hosts.",
+                    "type": "string"
+                },
+                "staticAddresses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StaticAddress"
+                    }
+                },
+                "subnets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "Incremented on every update, for optimistic concurrency.",
+                    "type": "integer"
                 }
             }
         },
-        "api.metadata": {
+        "models.HostDto": {
             "type": "object",
             "properties": {
-                "page": {
-                    "type": "integer"
+                "configuration": {
+                    "$ref": "#/definitions/models.Configuration"
                 },
-                "pageSize": {
-                    "type": "integer"
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "laptop",
+                        "servers",
+                        "ssh"
+                    ]
                 },
-                "total": {
-                    "description": "Total represents the total number of items.",
-                    "type": "integer"
+                "inPub": {
+                    "type": "string"
                 },
-                "totalPages": {
-                    "type": "integer"
+                "ip": {
+                    "type": "string",
+                    "example": "100.100.0.1/24"
+                },
+                "lighthouses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "lighthouse-1",
+                        "site:eu-west"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "host-1"
+                },
+                "networkId": {
+                    "type": "string",
+                    "example": "c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"
+                },
+                "relays": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "relay-1",
+                        "site:eu-west"
+                    ]
+                },
+                "site": {
+                    "type": "string",
+                    "example": "eu-west"
+                },
+                "staticAddresses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StaticAddress"
+                    }
+                },
+                "subnets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "192.168.1.0/24"
+                    ]
                 }
             }
         },
-        "api.paginatedResponse-models_Certificate": {
+        "models.HostSpec": {
             "type": "object",
             "properties": {
-                "data": {
-                    "description": "Data contains the actual collection of items.",
+                "configuration": {
+                    "description": "Config overrides of the host, merged over the defaults of the spec.",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "firewall": {
+                    "description": "Names of the firewall policies of the host, whose rules are added in order.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Certificate"
+                        "type": "string"
+                    },
+                    "example": [
+                        "ssh",
+                        "web"
+                    ]
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "laptop",
+                        "servers",
+                        "ssh"
+                    ]
+                },
+                "inPub": {
+                    "type": "string"
+                },
+                "ip": {
+                    "description": "New hosts without an IP get the next free address, existing ones keep theirs.",
+                    "type": "string",
+                    "example": "100.100.0.1/24"
+                },
+                "lighthouses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "lighthouse-1",
+                        "site:eu-west"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "host-1"
+                },
+                "relays": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "relay-1",
+                        "site:eu-west"
+                    ]
+                },
+                "site": {
+                    "type": "string",
+                    "example": "eu-west"
+                },
+                "staticAddresses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StaticAddress"
                     }
                 },
-                "metadata": {
-                    "description": "Metadata contains additional info like the total count.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/api.metadata"
-                        }
+                "subnets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "192.168.1.0/24"
                     ]
                 }
             }
         },
-        "api.paginatedResponse-models_Host": {
+        "models.Network": {
             "type": "object",
             "properties": {
-                "data": {
-                    "description": "Data contains the actual collection of items.",
+                "argonIterations": {
+                    "description": "Number of Argon2 iterations for encrypting private key passphrase. Default: 2.",
+                    "type": "integer"
+                },
+                "argonMemory": {
+                    "description": "Argon2 memory parameter in KiB for encrypted private key passphrase. Default: 2 MiB. (2*1024*1024)",
+                    "type": "integer"
+                },
+                "argonParallelism": {
+                    "description": "Argon2 parallelism parameter for encrypting private key passphrase. Default: 4.",
+                    "type": "integer"
+                },
+                "ca": {
+                    "description": "Associated Certificate Authorities (CA) for the network.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Certificate"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "curve": {
+                    "description": "Cryptographic curve for key generation. Options include \"25519\" (default) and \"P256\".",
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "description": "Set while the network is deleted, until it is restored or purged.",
+                    "type": "string"
+                },
+                "duration": {
+                    "description": "Certificate validity duration. Default: 2 years (17,531 hours). (time.Duration(time.Hour*8760))",
+                    "type": "number"
+                },
+                "encrypt": {
+                    "description": "Enables passphrase encryption for private keys. Default: true.",
+                    "type": "boolean"
+                },
+                "groups": {
+                    "description": "List of groups for access control, restricting subordinate certificates' groups.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "hosts": {
+                    "description": "Associated hosts for the network.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Host"
+                    }
+                },
+                "id": {
+                    "description": "Unique identifier for the network (UUID).",
+                    "type": "string"
+                },
+                "ips": {
+                    "description": "List of IPv4 addresses and networks in CIDR notation. Limits the addresses for subordinate certificates.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "description": "Name of the network, must be unique within the organization.",
+                    "type": "string"
+                },
+                "organization": {
+                    "$ref": "#/definitions/models.Organization"
+                },
+                "organizationId": {
+                    "description": "Organization owning the network.",
+                    "type": "string"
+                },
+                "passphrase": {
+                    "description": "Passphrase used for encrypting the private key.",
+                    "type": "string"
+                },
+                "sshUsers": {
+                    "description": "SSH admin users rendered into the sshd block of every host in the network.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Host"
+                        "$ref": "#/definitions/models.configAuthorizedUser"
                     }
                 },
-                "metadata": {
-                    "description": "Metadata contains additional info like the total count.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/api.metadata"
-                        }
-                    ]
+                "subnets": {
+                    "description": "List of IPv4 subnets in CIDR notation. Defines subnets that subordinate certificates can use.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Incremented on every update, for optimistic concurrency.",
+                    "type": "integer"
                 }
             }
         },
-        "api.paginatedResponse-models_Network": {
+        "models.NetworkArchive": {
             "type": "object",
             "properties": {
-                "data": {
-                    "description": "Data contains the actual collection of items.",
+                "hosts": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Network"
+                        "$ref": "#/definitions/models.ArchivedHost"
                     }
                 },
-                "metadata": {
-                    "description": "Metadata contains additional info like the total count.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/api.metadata"
-                        }
-                    ]
+                "network": {
+                    "$ref": "#/definitions/models.Network"
                 }
             }
         },
-        "models.CalculatedRemote": {
+        "models.NetworkDto": {
             "type": "object",
             "properties": {
-                "mask": {
-                    "description": "Mask defines the network range used to calculate the remote IP.",
-                    "type": "string",
-                    "example": "192.168.1.0/24"
+                "argonIterations": {
+                    "type": "integer",
+                    "example": 2
                 },
-                "port": {
-                    "description": "Port defines the port to be used for the calculated remote IP.",
+                "argonMemory": {
                     "type": "integer",
-                    "example": 4242
-                }
-            }
-        },
-        "models.Certificate": {
-            "type": "object",
-            "properties": {
-                "createdAt": {
-                    "type": "string"
+                    "example": 2097152
                 },
-                "crt": {
-                    "type": "string"
+                "argonParallelism": {
+                    "type": "integer",
+                    "example": 4
                 },
-                "id": {
-                    "type": "string"
+                "curve": {
+                    "type": "string",
+                    "enum": [
+                        "25519",
+                        "X25519",
+                        "Curve25519",
+                        "CURVE25519",
+                        "P256"
+                    ],
+                    "example": "25519"
                 },
-                "isCa": {
-                    "type": "boolean"
+                "duration": {
+                    "type": "number",
+                    "example": 17531
                 },
-                "key": {
-                    "type": "string"
+                "encrypt": {
+                    "type": "boolean",
+                    "example": false
                 },
-                "notAfter": {
-                    "type": "string"
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "laptop",
+                        "ssh",
+                        "servers"
+                    ]
                 },
-                "notBefore": {
-                    "type": "string"
+                "ips": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "100.100.0.0/22"
+                    ]
                 },
-                "ownerId": {
-                    "type": "string"
+                "name": {
+                    "type": "string",
+                    "example": "my-network"
                 },
-                "ownerType": {
-                    "type": "string"
+                "organizationId": {
+                    "type": "string",
+                    "example": "c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"
                 },
                 "passphrase": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "orange-duck-walks-happy-sunset-92"
                 },
-                "pub": {
-                    "type": "string"
+                "sshUsers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.configAuthorizedUser"
+                    }
                 },
-                "updatedAt": {
-                    "type": "string"
+                "subnets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "192.168.1.0/24"
+                    ]
                 }
             }
         },
-        "models.Configuration": {
+        "models.NetworkExport": {
             "type": "object",
             "properties": {
-                "cipher": {
-                    "description": "Cipher allows you to choose between the available ciphers for your network. Options are chachapoly or aes\nIMPORTANT: this value must be identical on ALL NODES/LIGHTHOUSES. We do not/will not support use of different ciphers simultaneously!",
-                    "type": "string",
-                    "example": "aes"
+                "archive": {
+                    "$ref": "#/definitions/models.NetworkArchive"
                 },
-                "firewall": {
-                    "description": "Nebula security group configuration",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.configFirewall"
-                        }
-                    ]
+                "ciphertext": {
+                    "type": "string"
                 },
-                "handshakes": {
-                    "$ref": "#/definitions/models.configHandshakes"
+                "encryption": {
+                    "$ref": "#/definitions/models.ExportEncryption"
                 },
-                "lighthouse": {
-                    "$ref": "#/definitions/models.configLighthouse"
+                "exportedAt": {
+                    "type": "string"
                 },
-                "listen": {
-                    "description": "Port Nebula will be listening on. The default here is 4242. For a lighthouse node, the port should be defined,\nhowever using port 0 will dynamically assign a port and is recommended for roaming nodes.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.configListen"
-                        }
-                    ]
+                "format": {
+                    "type": "string"
                 },
-                "logging": {
-                    "$ref": "#/definitions/models.configLogging"
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.NetworkSpec": {
+            "type": "object",
+            "properties": {
+                "defaults": {
+                    "description": "Config overrides of every host, with the fields of a host configuration. Objects are merged into the default\nconfig, other values replace it.",
+                    "type": "object",
+                    "additionalProperties": {}
                 },
-                "pki": {
-                    "description": "PKI defines the location of credentials for this node. Each of these can also be inlined by using the yaml \": |\" syntax.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.configPKI"
-                        }
-                    ]
+                "firewall": {
+                    "description": "Firewall policies by name, which hosts add to their firewall rules.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.FirewallPolicy"
+                    }
                 },
-                "preferredRanges": {
-                    "description": "Preferred ranges is used to define a hint about the local network ranges, which speeds up discovering the fastest\npath to a network adjacent nebula node.\nThis setting is reloadable.",
+                "hosts": {
                     "type": "array",
                     "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "172.16.0.0/24"
-                    ]
-                },
-                "punchy": {
-                    "$ref": "#/definitions/models.configPunchy"
+                        "$ref": "#/definitions/models.HostSpec"
+                    }
                 },
-                "relay": {
-                    "description": "EXPERIMENTAL: relay support for networks that can't establish direct connections.",
+                "network": {
+                    "description": "The network, matched by name within its organization. Only its sshUsers are updated, the other settings\nare those of its CA, which apply when the network is created. Its IPs, subnets and groups cannot change after.",
                     "allOf": [
                         {
-                            "$ref": "#/definitions/models.configRelay"
+                            "$ref": "#/definitions/models.NetworkDto"
                         }
                     ]
+                }
+            }
+        },
+        "models.Organization": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
                 },
-                "routines": {
-                    "description": "Routines is the number of thread pairs to run that consume from the tun and UDP queues.\nCurrently, this defaults to 1 which means we have 1 tun queue reader and 1\nUDP queue reader. Setting this above one will set IFF_MULTI_QUEUE on the tun\ndevice and SO_REUSEPORT on the UDP socket to allow multiple queues.\nThis option is only supported on Linux.",
-                    "type": "integer",
-                    "example": 1
+                "id": {
+                    "type": "string"
                 },
-                "sshd": {
-                    "description": "sshd can expose informational and administrative functions via ssh. This can expose informational and administrative\nfunctions, and allows manual tweaking of various network settings when debugging or testing.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.configSSHD"
-                        }
-                    ]
+                "maxHosts": {
+                    "description": "Maximum number of hosts across all networks. 0 means unlimited.",
+                    "type": "integer"
                 },
-                "staticMap": {
-                    "description": "The static_map config stanza can be used to configure how the static_host_map behaves.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.configStaticMap"
-                        }
-                    ]
+                "maxNetworks": {
+                    "description": "Maximum number of networks. 0 means unlimited.",
+                    "type": "integer"
                 },
-                "stats": {
-                    "$ref": "#/definitions/models.configStats"
+                "name": {
+                    "type": "string"
                 },
-                "tun": {
-                    "description": "Configure the private interface. Note: addr is baked into the nebula certificate",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.configTun"
-                        }
-                    ]
+                "networks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Network"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
                 }
             }
         },
-        "models.Host": {
+        "models.OrganizationDto": {
             "type": "object",
             "properties": {
-                "certificate": {
-                    "$ref": "#/definitions/models.Certificate"
+                "maxHosts": {
+                    "type": "integer",
+                    "example": 500
                 },
-                "configuration": {
-                    "$ref": "#/definitions/models.Configuration"
+                "maxNetworks": {
+                    "type": "integer",
+                    "example": 10
                 },
-                "createdAt": {
-                    "type": "string"
+                "name": {
+                    "type": "string",
+                    "example": "payments"
+                }
+            }
+        },
+        "models.PlanAction": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete",
+                        "reissue"
+                    ],
+                    "example": "update"
                 },
-                "groups": {
+                "fields": {
+                    "description": "Changed fields of an update, or why a certificate is re-issued.",
                     "type": "array",
                     "items": {
                         "type": "string"
-                    }
+                    },
+                    "example": [
+                        "ip",
+                        "groups"
+                    ]
                 },
                 "id": {
+                    "description": "Not set for resources that are yet to be created.",
                     "type": "string"
                 },
-                "inPub": {
-                    "type": "string"
+                "name": {
+                    "type": "string",
+                    "example": "host-1"
                 },
-                "ip": {
+                "resourceType": {
+                    "type": "string",
+                    "enum": [
+                        "network",
+                        "host",
+                        "certificate"
+                    ],
+                    "example": "host"
+                }
+            }
+        },
+        "models.RoleBinding": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
                     "type": "string"
                 },
-                "name": {
+                "id": {
                     "type": "string"
                 },
                 "network": {
@@ -811,227 +4248,199 @@ const docTemplate = `{
                 "networkId": {
                     "type": "string"
                 },
-                "staticAddresses": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "role": {
+                    "type": "string"
                 },
-                "subnets": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "tokenId": {
+                    "type": "string"
                 },
                 "updatedAt": {
                     "type": "string"
                 }
             }
         },
-        "models.HostDto": {
+        "models.RoleBindingDto": {
             "type": "object",
+            "required": [
+                "role",
+                "tokenId"
+            ],
             "properties": {
-                "configuration": {
-                    "$ref": "#/definitions/models.Configuration"
-                },
-                "groups": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "laptop",
-                        "servers",
-                        "ssh"
-                    ]
-                },
-                "inPub": {
-                    "type": "string"
-                },
-                "ip": {
-                    "type": "string",
-                    "example": "100.100.0.1/24"
-                },
-                "name": {
+                "role": {
                     "type": "string",
-                    "example": "host-1"
+                    "enum": [
+                        "owner",
+                        "operator",
+                        "viewer",
+                        "enroller"
+                    ],
+                    "example": "viewer"
                 },
-                "networkId": {
+                "tokenId": {
                     "type": "string",
                     "example": "c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"
+                }
+            }
+        },
+        "models.SSHDToggleDto": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
                 },
-                "staticAddresses": {
+                "groups": {
+                    "description": "Only hosts in at least one of these groups are changed. Empty means all hosts.",
                     "type": "array",
                     "items": {
                         "type": "string"
                     },
                     "example": [
-                        "109.243.69.39"
+                        "servers"
                     ]
                 },
-                "subnets": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "192.168.1.0/24"
-                    ]
+                "listen": {
+                    "description": "Optional listen address to set on the changed hosts.",
+                    "type": "string",
+                    "example": "127.0.0.1:2222"
                 }
             }
         },
-        "models.Network": {
+        "models.StaticAddress": {
             "type": "object",
             "properties": {
-                "argonIterations": {
-                    "description": "Number of Argon2 iterations for encrypting private key passphrase. Default: 2.",
-                    "type": "integer"
-                },
-                "argonMemory": {
-                    "description": "Argon2 memory parameter in KiB for encrypted private key passphrase. Default: 2 MiB. (2*1024*1024)",
-                    "type": "integer"
+                "family": {
+                    "description": "Family is the address family: \"ip4\", \"ip6\" or \"dns\". It is derived from Host when empty.",
+                    "type": "string",
+                    "enum": [
+                        "ip4",
+                        "ip6",
+                        "dns"
+                    ],
+                    "example": "ip4"
                 },
-                "argonParallelism": {
-                    "description": "Argon2 parallelism parameter for encrypting private key passphrase. Default: 4.",
-                    "type": "integer"
+                "host": {
+                    "description": "Host is an IPv4/IPv6 literal or a DNS name. DNS names are resolved by nebula according to static_map.",
+                    "type": "string",
+                    "example": "109.243.69.39"
                 },
-                "ca": {
-                    "description": "Associated Certificate Authorities (CA) for the network.",
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Certificate"
-                    }
+                "port": {
+                    "description": "Port is the routable port, e.g. a NAT-mapped port. When 0, the host's listen port is used.",
+                    "type": "integer",
+                    "example": 4242
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Inactive webhooks get no new events.",
+                    "type": "boolean"
                 },
                 "createdAt": {
                     "type": "string"
                 },
-                "curve": {
-                    "description": "Cryptographic curve for key generation. Options include \"25519\" (default) and \"P256\".",
-                    "type": "string"
-                },
-                "duration": {
-                    "description": "Certificate validity duration. Default: 2 years (17,531 hours). (time.Duration(time.Hour*8760))",
-                    "type": "number"
-                },
-                "encrypt": {
-                    "description": "Enables passphrase encryption for private keys. Default: true.",
-                    "type": "boolean"
-                },
-                "groups": {
-                    "description": "List of groups for access control, restricting subordinate certificates' groups.",
+                "events": {
+                    "description": "Events to send. Empty means all.",
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
                 },
-                "hosts": {
-                    "description": "Associated hosts for the network.",
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Host"
-                    }
-                },
                 "id": {
-                    "description": "Unique identifier for the network (UUID).",
                     "type": "string"
                 },
-                "ips": {
-                    "description": "List of IPv4 addresses and networks in CIDR notation. Limits the addresses for subordinate certificates.",
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
-                },
                 "name": {
-                    "description": "Name of the network, must be unique in combination with the CIDR.",
                     "type": "string"
                 },
-                "passphrase": {
-                    "description": "Passphrase used for encrypting the private key.",
+                "networkId": {
+                    "description": "Only send events of this network.",
                     "type": "string"
                 },
-                "subnets": {
-                    "description": "List of IPv4 subnets in CIDR notation. Defines subnets that subordinate certificates can use.",
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "organizationId": {
+                    "description": "Only send events of this organization's networks. Webhooks without one get the events of every network.",
+                    "type": "string"
                 },
                 "updatedAt": {
                     "type": "string"
+                },
+                "url": {
+                    "type": "string"
                 }
             }
         },
-        "models.NetworkDto": {
+        "models.WebhookDelivery": {
             "type": "object",
             "properties": {
-                "argonIterations": {
-                    "type": "integer",
-                    "example": 2
+                "attempts": {
+                    "type": "integer"
                 },
-                "argonMemory": {
-                    "type": "integer",
-                    "example": 2097152
+                "createdAt": {
+                    "type": "string"
                 },
-                "argonParallelism": {
-                    "type": "integer",
-                    "example": 4
+                "deliveredAt": {
+                    "type": "string"
                 },
-                "curve": {
-                    "type": "string",
-                    "enum": [
-                        "25519",
-                        "X25519",
-                        "Curve25519",
-                        "CURVE25519",
-                        "P256"
-                    ],
-                    "example": "25519"
+                "event": {
+                    "type": "string"
                 },
-                "duration": {
-                    "type": "number",
-                    "example": 17531
+                "id": {
+                    "type": "string"
                 },
-                "encrypt": {
-                    "type": "boolean",
-                    "example": false
+                "lastError": {
+                    "type": "string"
                 },
-                "groups": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "laptop",
-                        "ssh",
-                        "servers"
-                    ]
+                "lastStatusCode": {
+                    "description": "HTTP status of the last attempt, 0 when the request failed.",
+                    "type": "integer"
                 },
-                "ips": {
+                "nextAttemptAt": {
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "JSON body of the request, a WebhookEvent.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "webhookId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookDto": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "events": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     },
                     "example": [
-                        "100.100.0.0/22"
+                        "config.changed",
+                        "host.deleted"
                     ]
                 },
                 "name": {
                     "type": "string",
-                    "example": "my-network"
+                    "example": "deploy"
                 },
-                "passphrase": {
+                "networkId": {
                     "type": "string",
-                    "example": "orange-duck-walks-happy-sunset-92"
+                    "example": "c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"
                 },
-                "subnets": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "192.168.1.0/24"
-                    ]
+                "url": {
+                    "type": "string",
+                    "example": "https://ci.example.com/hooks/koodnet"
                 }
             }
         },
@@ -1652,6 +5061,28 @@ const docTemplate = `{
                     "example": "192.168.100.99"
                 }
             }
+        },
+        "service.ConfigDiff": {
+            "type": "object",
+            "properties": {
+                "diff": {
+                    "type": "string"
+                },
+                "hostId": {
+                    "type": "string"
+                },
+                "hostName": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "API token, sent as \"Bearer \u003ctoken\u003e\". Create the first one with \"koodnet-api bootstrap-token\".",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     },
     "externalDocs": {
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// applyResponse lists the changes of applying a spec, and for plans the config changes of every affected host.
+type applyResponse struct {
+	NetworkID *uuid.UUID           `json:"networkId,omitempty"` // Not set in plans of networks that are yet to be created.
+	Applied   bool                 `json:"applied"`             // False for plans.
+	Actions   []models.PlanAction  `json:"actions"`
+	Diffs     []service.ConfigDiff `json:"diffs,omitempty"`
+}
+
+// Apply godoc
+// @Summary Apply a network spec
+// @Description Bring a network and its hosts to the state described by a JSON or YAML spec, e.g. kept in git.
+// @Description The network is matched by name within the organization and created when missing. Of existing networks only
+// @Description sshUsers are updated, as the IPs, subnets and groups constrain the CA and cannot change. Hosts are matched by name:
+// @Description hosts of the spec are created or updated, and hosts of the network that are not in the spec are deleted.
+// @Description Certificates of hosts whose IP, groups, subnets or public key change are signed anew.
+// @Description Host configs are the defaults with the "defaults" of the spec and then the "configuration" of the host merged in,
+// @Description plus the rules of the firewall policies the host lists. All changes are made in one transaction.
+// @Description With ?dryRun=true the plan is returned with the config diff of every affected host, without applying it.
+// @Tags networks
+// @Accept json
+// @Accept application/x-yaml
+// @Produce json
+// @Param spec body models.NetworkSpec true "Network spec"
+// @Param dryRun query bool false "Return the plan without applying it"
+// @Success 200 {object} api.applyResponse
+// @Failure 400 {object} api.errorResponse
+// @Failure 403 {object} api.errorResponse
+// @Failure 409 {object} api.errorResponse
+// @Failure 422 {object} api.errorResponse "Invalid spec, with the path of the invalid field"
+// @Security BearerAuth
+// @Router /apply [post]
+func Apply(c *gin.Context) {
+	var spec models.NetworkSpec
+
+	if err := parseNetworkSpec(c, &spec); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	dryRun := isDryRun(c)
+	result, err := service.Apply(callerContext(c), &spec, dryRun)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, applyResponse{
+		NetworkID: result.NetworkID,
+		Applied:   !dryRun,
+		Actions:   result.Actions,
+		Diffs:     result.Diffs,
+	})
+}
+
+// parseNetworkSpec reads a JSON or YAML network spec from the request body.
+func parseNetworkSpec(c *gin.Context, spec *models.NetworkSpec) error {
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+
+	switch contentType {
+	case "application/x-yaml", "application/yaml", "text/yaml":
+		return decodeYAML(c.Request.Body, spec)
+	default:
+		return c.ShouldBindJSON(spec)
+	}
+}
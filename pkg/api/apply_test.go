@@ -0,0 +1,67 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestApplyRequiresHostsWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	connectTestDB(t)
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	r, err := NewRouter(l, config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := `
+network:
+  name: office
+  ips: [100.100.0.0/16]
+  duration: 86400000000000 # 24h
+  curve: "25519"
+hosts:
+  - name: web
+`
+
+	tests := []struct {
+		scopes []string
+		want   int
+	}{
+		{[]string{models.ScopeNetworksWrite}, http.StatusForbidden},
+		{[]string{models.ScopeNetworksWrite, models.ScopeHostsWrite}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		token, raw, err := models.NewAPIToken("ci", tt.scopes, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := database.Conn.Create(token).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/apply?dryRun=true", strings.NewReader(spec))
+		req.Header.Set("Authorization", "Bearer "+raw)
+		req.Header.Set("Content-Type", "application/x-yaml")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("applying with %v got %d %s, want %d", tt.scopes, w.Code, w.Body, tt.want)
+		}
+	}
+}
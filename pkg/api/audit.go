@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// FindAuditEvents godoc
+// @Summary Get the audit log
+// @Description Get the audit events of every create, update and delete of networks, hosts and certificates, and every config download, newest first.
+// @Description Tokens confined to an organization only see the events of its networks.
+// @Tags audit
+// @Produce json
+// @Param action query string false "Filter by action" Enums(create, update, delete, restore, download)
+// @Param resourceType query string false "Filter by resource type" Enums(network, host, certificate)
+// @Param resourceId query string false "Filter by resource ID"
+// @Param networkId query string false "Filter by network ID"
+// @Param actorId query string false "Filter by the ID of the API token that made the change"
+// @Param requestId query string false "Filter by request ID"
+// @Param since query string false "Only events at or after this time (RFC 3339)"
+// @Param until query string false "Only events before this time (RFC 3339)"
+// @Param page query int false "page for pagination" default(1)
+// @Param pageSize query int false "pageSize for pagination" default(10)
+// @Success 200 {object} api.paginatedResponse[models.AuditEvent]
+// @Failure 400 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /audit [get]
+func FindAuditEvents(c *gin.Context) {
+	filter := service.AuditFilter{
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resourceType"),
+		ResourceID:   c.Query("resourceId"),
+		NetworkID:    c.Query("networkId"),
+		ActorID:      c.Query("actorId"),
+		RequestID:    c.Query("requestId"),
+	}
+
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse{
+				Errors: []apiError{
+					{
+						Code:    "INVALID_INPUT",
+						Message: param + " must be an RFC 3339 time: " + err.Error(),
+					},
+				},
+			})
+			return
+		}
+
+		*t = parsed
+	}
+
+	events, err := service.ListAuditEvents(callerContext(c), filter, c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
+
+	response := paginated(events, c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyAuditEvents godoc
+// @Summary Verify the audit log
+// @Description Recompute the hash chain of the audit log and report the first event that was changed, removed or inserted out of order.
+// @Tags audit
+// @Produce json
+// @Success 200 {object} models.AuditChainStatus
+// @Security BearerAuth
+// @Router /audit/verify [get]
+func VerifyAuditEvents(c *gin.Context) {
+	status, err := service.VerifyAuditChain(callerContext(c))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
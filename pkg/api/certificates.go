@@ -4,8 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/koodeyo/koodnet/pkg/database"
-	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
 )
 
 // FindCertificates godoc
@@ -13,15 +12,24 @@ import (
 // @Description Get a list of all certificates with optional pagination
 // @Tags certificates
 // @Produce json
+// @Param ownerId query string false "Filter by the ID of the owning network or host"
+// @Param ownerType query string false "Filter by owner type" Enums(networks, hosts)
+// @Param isCa query bool false "Filter CAs"
+// @Param expired query bool false "Filter expired certificates"
+// @Param expiresBefore query string false "Only certificates expiring at or before this time (RFC 3339)"
+// @Param sort query string false "Comma-separated sort fields, prefixed with - for descending: notBefore, notAfter, createdAt" default(createdAt)
 // @Param page query int false "page for pagination" default(1)
 // @Param pageSize query int false "pageSize for pagination" default(10)
-// @Success 200 {object} api.paginatedResponse[models.Certificate]
+// @Success 200 {object} api.paginatedResponse[models.Certificate] "Private keys are only included with the keys:read scope"
+// @Failure 400 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /certificates [get]
 func FindCertificates(c *gin.Context) {
-	var certificates []models.Certificate
-
-	// Fetch data from the database
-	database.Conn.Model(&models.Certificate{}).Scopes(models.Paginate(c)).Find(&certificates)
+	certificates, err := service.ListCertificates(callerContext(c), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
 
 	response := paginated(certificates, c)
 
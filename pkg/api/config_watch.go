@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"gorm.io/gorm"
+)
+
+const (
+	configRevisionHeader      = "X-Koodnet-Config-Revision"
+	defaultConfigWatchTimeout = 30 * time.Second
+	maxConfigWatchTimeout     = 5 * time.Minute
+	configWatchKeepAlive      = 25 * time.Second
+)
+
+// configRevisionEvent is sent to agents watching the config of a host.
+type configRevisionEvent struct {
+	HostID   uuid.UUID `json:"hostId"`
+	Revision string    `json:"revision" example:"5f0c6e1b9a3d4c2e8b7a6f5e4d3c2b1a"` // Changes with the rendered config. Fetch the config from /hosts/{id}/config.yml.
+}
+
+// WatchHostConfig godoc
+// @Summary Watch a host's configuration for changes
+// @Description Wait for the rendered config of a host to differ from the given revision, instead of polling config.yml.
+// @Description With "Accept: text/event-stream", the connection stays open as Server-Sent Events: a "config" event with the revision as its ID
+// @Description is sent whenever the config changes, and a "deleted" event when the host is deleted. Reconnecting clients resume with Last-Event-ID.
+// @Description Otherwise the request long-polls: it returns the new revision as soon as it differs from the given one, or 304 when the timeout passes first.
+// @Description The revision of a config is also returned in the X-Koodnet-Config-Revision header of config.yml.
+// @Tags hosts
+// @Produce json
+// @Produce text/event-stream
+// @Param id path string true "Host ID"
+// @Param revision query string false "Revision of the config the agent has. Defaults to the Last-Event-ID header"
+// @Param timeout query string false "How long a long-poll waits, as a duration of at most 5m" default(30s)
+// @Success 200 {object} api.configRevisionEvent
+// @Success 304 "Unchanged when the timeout passed"
+// @Failure 400 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /hosts/{id}/config/watch [get]
+func WatchHostConfig(c *gin.Context) {
+	timeout := defaultConfigWatchTimeout
+	if v := c.Query("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxConfigWatchTimeout {
+			c.JSON(http.StatusBadRequest, errorResponse{
+				Errors: []apiError{
+					{
+						Code:    "INVALID_INPUT",
+						Message: "timeout must be a positive duration of at most 5m, e.g. 30s",
+					},
+				},
+			})
+			return
+		}
+		timeout = d
+	}
+
+	cursor := c.Query("revision")
+	if cursor == "" {
+		cursor = c.GetHeader("Last-Event-ID")
+	}
+
+	// Subscribe before rendering, so that no change is missed in between
+	watch, err := service.WatchHostConfig(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+	defer watch.Close()
+
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		streamHostConfig(c, watch, cursor)
+		return
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		revision, err := watch.Revision()
+		if err != nil {
+			dbErrorHandler(err, c)
+			return
+		}
+
+		c.Header(configRevisionHeader, revision)
+		if revision != cursor {
+			c.JSON(http.StatusOK, configRevisionEvent{HostID: watch.HostID, Revision: revision})
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-deadline.C:
+			c.Status(http.StatusNotModified)
+			return
+		case <-watch.Changed():
+		}
+	}
+}
+
+// streamHostConfig sends a Server-Sent Event for every new revision of the config, until the client leaves or the host is deleted.
+func streamHostConfig(c *gin.Context, watch *service.ConfigWatch, cursor string) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprint(c.Writer, "retry: 5000\n\n")
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(configWatchKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		revision, err := watch.Revision()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			data, _ := json.Marshal(gin.H{"hostId": watch.HostID})
+			fmt.Fprintf(c.Writer, "event: deleted\ndata: %s\n\n", data)
+			c.Writer.Flush()
+			return
+		}
+
+		// The client reconnects and resumes from its last revision
+		if err != nil {
+			return
+		}
+
+		if revision != cursor {
+			data, _ := json.Marshal(configRevisionEvent{HostID: watch.HostID, Revision: revision})
+			fmt.Fprintf(c.Writer, "id: %s\nevent: config\ndata: %s\n\n", revision, data)
+			c.Writer.Flush()
+			cursor = revision
+		}
+
+		if !waitForConfigChange(c, watch, keepAlive) {
+			return
+		}
+	}
+}
+
+// waitForConfigChange blocks until the config may have changed, keeping the stream alive meanwhile.
+// It reports false when the client left.
+func waitForConfigChange(c *gin.Context, watch *service.ConfigWatch, keepAlive *time.Ticker) bool {
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case <-watch.Changed():
+			return true
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWatchHostConfigLongPoll(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	connectTestDB(t)
+
+	interval := service.ConfigWatchInterval
+	service.ConfigWatchInterval = 10 * time.Millisecond
+	t.Cleanup(func() { service.ConfigWatchInterval = interval })
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	r, err := NewRouter(l, config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, raw, err := models.NewAPIToken("agent", []string{models.ScopeNetworksWrite, models.ScopeHostsRead, models.ScopeHostsWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Conn.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx := service.NewContext(context.Background(), service.Caller{Token: token})
+
+	n, err := service.CreateNetwork(ctx, models.NetworkDto{Name: "office", IPs: []string{"100.100.0.0/16"}, Duration: 24 * time.Hour, Curve: "25519"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, _, err := service.CreateHost(ctx, models.HostDto{NetworkID: n.ID, Name: "laptop", IP: "100.100.0.1/16"}, service.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watch := func(query string) (*httptest.ResponseRecorder, configRevisionEvent) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/"+host.ID.String()+"/config/watch?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+raw)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var event configRevisionEvent
+		json.Unmarshal(w.Body.Bytes(), &event)
+
+		return w, event
+	}
+
+	// Without a revision, the current one is returned at once
+	w, current := watch("")
+	if w.Code != http.StatusOK || current.Revision == "" || w.Header().Get(configRevisionHeader) != current.Revision {
+		t.Fatalf("got %d %s, want the current revision", w.Code, w.Body)
+	}
+
+	if w, _ := watch("revision=" + current.Revision + "&timeout=50ms"); w.Code != http.StatusNotModified {
+		t.Errorf("unchanged config got %d, want 304 after the timeout", w.Code)
+	}
+
+	// A new lighthouse changes the config of the host, which ends the long-poll
+	type result struct {
+		w     *httptest.ResponseRecorder
+		event configRevisionEvent
+		at    time.Time
+	}
+	done := make(chan result, 1)
+	go func() {
+		w, event := watch("revision=" + current.Revision + "&timeout=5s")
+		done <- result{w, event, time.Now()}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	changedAt := time.Now()
+
+	lighthouse := models.HostDto{
+		NetworkID:       n.ID,
+		Name:            "lighthouse",
+		IP:              "100.100.0.2/16",
+		StaticAddresses: []models.StaticAddress{{Host: "203.0.113.1", Port: 4242}},
+	}
+	if err := json.Unmarshal([]byte(`{"lighthouse": {"amLighthouse": true}}`), &lighthouse.Configuration); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.CreateHost(ctx, lighthouse, service.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-done:
+		if res.w.Code != http.StatusOK || res.event.Revision == "" || res.event.Revision == current.Revision {
+			t.Errorf("got %d %s, want a new revision", res.w.Code, res.w.Body)
+		}
+		if res.at.Before(changedAt) {
+			t.Error("the long-poll returned before the config changed")
+		}
+
+		// The new revision is the one of config.yml
+		cfg, err := service.RenderHostConfig(database.Conn, host.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := models.ConfigRevision(cfg.YAML); res.event.Revision != want {
+			t.Errorf("got revision %s, want %s of the rendered config", res.event.Revision, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the long-poll did not return after the config changed")
+	}
+}
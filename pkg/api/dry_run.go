@@ -0,0 +1,18 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// dryRunResponse lists the config changes of every affected host of the network.
+type dryRunResponse struct {
+	Diffs []service.ConfigDiff `json:"diffs"`
+}
+
+func isDryRun(c *gin.Context) bool {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	return dryRun
+}
@@ -0,0 +1,10 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// setETag sets the ETag header of the response.
+func setETag(c *gin.Context, etag string) {
+	c.Header("ETag", etag)
+}
@@ -1,15 +1,20 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/middleware"
 	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
 )
 
 type apiError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	Field   string `json:"field,omitempty" example:"staticAddresses[0].port"` // JSON path of the invalid value, for validation errors.
 }
 
 type errorResponse struct {
@@ -17,10 +22,12 @@ type errorResponse struct {
 }
 
 type metadata struct {
-	Page       int `json:"page"`
-	PageSize   int `json:"pageSize"`
-	TotalPages int `json:"totalPages"`
-	Total      int `json:"total"` // Total represents the total number of items.
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"pageSize,omitempty"`
+	TotalPages *int   `json:"totalPages,omitempty"`
+	Total      *int   `json:"total,omitempty"`      // Total represents the total number of items. Left out when not counted.
+	Limit      int    `json:"limit,omitempty"`      // Page size of cursor pagination.
+	NextCursor string `json:"nextCursor,omitempty"` // Cursor of the next page, left out on the last page.
 }
 
 // for a paginated response.
@@ -31,34 +38,68 @@ type paginatedResponse[T interface{}] struct {
 }
 
 func paginated[T interface{}](data []T, c *gin.Context) paginatedResponse[T] {
-	return paginatedResponse[T]{
+	response := paginatedResponse[T]{
 		Data: data,
 		Metadata: metadata{
 			Page:       c.GetInt("page"),
-			Total:      c.GetInt("total"),
 			PageSize:   c.GetInt("pageSize"),
-			TotalPages: c.GetInt("totalPages"),
+			TotalPages: optionalInt(c, "totalPages"),
+			Total:      optionalInt(c, "total"),
 		},
 	}
+
+	// Cursor pages are fetched with one item more than the limit when there is a next page
+	if limit := c.GetInt("limit"); limit > 0 {
+		response.Metadata = metadata{Limit: limit, Total: optionalInt(c, "total")}
+
+		if len(data) > limit {
+			response.Data = data[:limit]
+			if last, ok := any(data[limit-1]).(models.Cursored); ok {
+				response.Metadata.NextCursor = last.PageCursor().String()
+			}
+		}
+	}
+
+	return response
+}
+
+func optionalInt(c *gin.Context, key string) *int {
+	if _, ok := c.Get(key); !ok {
+		return nil
+	}
+
+	i := c.GetInt(key)
+	return &i
 }
 
-// Middleware for centralized error handling
+// Middleware for centralized error handling. Errors added with c.Error are reported
+// unless the handler already responded, in which case they are only logged.
 func errorHandler(c *gin.Context) {
 	c.Next()
 
-	if len(c.Errors) > 0 {
-		var errors []apiError
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	var errors []apiError
+	status := 0
+
+	for _, err := range c.Errors {
+		errStatus, apiErr := toAPIError(err.Err)
+		if err.IsType(gin.ErrorTypeBind) {
+			errStatus, apiErr = http.StatusBadRequest, apiError{Code: "INVALID_DATA", Message: err.Error()}
+		}
 
-		for _, err := range c.Errors {
-			errors = append(errors, apiError{
-				Code:    "ERR_UNKNOWN", // Default error code
-				Message: err.Error(),   // Use the error message from Gin
-			})
+		// The first error decides the status
+		if status == 0 {
+			status = errStatus
 		}
 
-		// Respond with a JSON error response
-		c.JSON(http.StatusBadRequest, errorResponse{Errors: errors})
+		errors = append(errors, apiErr)
 	}
+
+	// Respond with a JSON error response
+	c.JSON(status, errorResponse{Errors: errors})
 }
 
 // Custom 404 Handler
@@ -73,27 +114,111 @@ func notFoundHandler(c *gin.Context) {
 	})
 }
 
-func dbErrorHandler(err error, c *gin.Context) {
-	// Look up the error in the map
-	if errInfo, found := models.Errors[err]; found {
-		c.JSON(errInfo.Status, errorResponse{
+// listErrorHandler responds to the error of a list query, which is a 400 for invalid filters or sorting.
+func listErrorHandler(err error, c *gin.Context) {
+	var invalid models.ValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, errorResponse{
 			Errors: []apiError{
 				{
-					Code:    errInfo.Code,
-					Message: errInfo.Message + " Details: " + err.Error(),
+					Code:    "INVALID_QUERY",
+					Message: invalid.Error(),
 				},
 			},
 		})
 		return
 	}
 
-	// Default case for unexpected errors
-	c.JSON(http.StatusInternalServerError, errorResponse{
-		Errors: []apiError{
-			{
-				Code:    "ERR_INTERNAL",
-				Message: "An internal server error occurred. " + err.Error(),
-			},
-		},
+	dbErrorHandler(err, c)
+}
+
+// callerContext returns the context of the request with its caller, for the service layer.
+func callerContext(c *gin.Context) context.Context {
+	return service.NewContext(c.Request.Context(), service.Caller{
+		Token:     middleware.CurrentToken(c),
+		RequestID: middleware.CurrentRequestID(c),
+		SourceIP:  c.ClientIP(),
 	})
 }
+
+// dbErrorHandler responds with the status and API error of err.
+func dbErrorHandler(err error, c *gin.Context) {
+	status, apiErr := toAPIError(err)
+
+	// Failed preconditions tell the current ETag of the resource
+	var precondition *service.PreconditionFailedError
+	if errors.As(err, &precondition) {
+		setETag(c, precondition.ETag)
+	}
+
+	// Internal errors are logged, as their details are not shown in release mode
+	if status == http.StatusInternalServerError {
+		c.Error(err)
+	}
+
+	c.JSON(status, errorResponse{Errors: []apiError{apiErr}})
+}
+
+// toAPIError converts err into its status and API error:
+//   - a ValidationError is a 422 INVALID_DATA with the path of the invalid field,
+//   - the errors of the service layer are a 403, 409 or 412 with their message,
+//   - an error of models.Errors, or wrapping one, is reported as listed there,
+//   - anything else is a 500 ERR_INTERNAL.
+//
+// The text of the error itself is only added in debug mode, as it can reveal internals such as database details.
+func toAPIError(err error) (int, apiError) {
+	var invalid models.ValidationError
+	if errors.As(err, &invalid) {
+		return http.StatusUnprocessableEntity, apiError{
+			Code:    "INVALID_DATA",
+			Message: invalid.Error(),
+			Field:   invalid.Field,
+		}
+	}
+
+	var forbidden *service.ForbiddenError
+	if errors.As(err, &forbidden) {
+		return http.StatusForbidden, apiError{
+			Code:    "ERR_FORBIDDEN",
+			Message: forbidden.Message,
+		}
+	}
+
+	var confirmation *service.ConfirmationRequiredError
+	if errors.As(err, &confirmation) {
+		return http.StatusConflict, apiError{
+			Code:    "ERR_CONFIRMATION_REQUIRED",
+			Message: confirmation.Error(),
+		}
+	}
+
+	var precondition *service.PreconditionFailedError
+	if errors.As(err, &precondition) {
+		return http.StatusPreconditionFailed, apiError{
+			Code:    "ERR_PRECONDITION_FAILED",
+			Message: precondition.Error(),
+		}
+	}
+
+	if errInfo, found := models.LookupError(err); found {
+		return errInfo.Status, apiError{
+			Code:    errInfo.Code,
+			Message: errInfo.Message + errorDetails(" Details: ", err),
+		}
+	}
+
+	// Default case for unexpected errors
+	return http.StatusInternalServerError, apiError{
+		Code:    "ERR_INTERNAL",
+		Message: "An internal server error occurred." + errorDetails(" ", err),
+	}
+}
+
+// errorDetails returns the text of err after prefix, or nothing in release mode.
+func errorDetails(prefix string, err error) string {
+	if gin.Mode() == gin.ReleaseMode {
+		return ""
+	}
+
+	return prefix + err.Error()
+}
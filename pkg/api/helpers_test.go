@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"gorm.io/gorm"
+)
+
+func TestToAPIError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		code      string
+		field     string
+		inMessage string
+	}{
+		{
+			name:   "validation error",
+			err:    models.NewFieldError("port", "invalid port").Within("staticAddresses[0]"),
+			status: http.StatusUnprocessableEntity, code: "INVALID_DATA", field: "staticAddresses[0].port",
+		},
+		{
+			name:   "wrapped validation error",
+			err:    fmt.Errorf("line 3: %w", models.NewValidationError("name cannot be empty")),
+			status: http.StatusUnprocessableEntity, code: "INVALID_DATA", inMessage: "name cannot be empty",
+		},
+		{
+			name:   "forbidden",
+			err:    &service.ForbiddenError{Message: "Your role on this network does not allow host:create."},
+			status: http.StatusForbidden, code: "ERR_FORBIDDEN", inMessage: "host:create",
+		},
+		{
+			name:   "confirmation required",
+			err:    &service.ConfirmationRequiredError{Network: "prod", Hosts: 2},
+			status: http.StatusConflict, code: "ERR_CONFIRMATION_REQUIRED", inMessage: "prod",
+		},
+		{
+			name:   "precondition failed",
+			err:    &service.PreconditionFailedError{ETag: `"1"`},
+			status: http.StatusPreconditionFailed, code: "ERR_PRECONDITION_FAILED",
+		},
+		{
+			name:   "listed error",
+			err:    gorm.ErrRecordNotFound,
+			status: http.StatusNotFound, code: "ERR_NOT_FOUND",
+		},
+		{
+			name:   "wrapped listed error",
+			err:    fmt.Errorf("creating host: %w", gorm.ErrDuplicatedKey),
+			status: http.StatusConflict, code: "ERR_DUPLICATED_KEY", inMessage: "creating host",
+		},
+		{
+			name:   "unexpected error",
+			err:    errors.New("disk on fire"),
+			status: http.StatusInternalServerError, code: "ERR_INTERNAL", inMessage: "disk on fire",
+		},
+	}
+
+	for _, tt := range tests {
+		status, apiErr := toAPIError(tt.err)
+
+		if status != tt.status || apiErr.Code != tt.code || apiErr.Field != tt.field {
+			t.Errorf("%s: got %d %+v, want %d %s with field %q", tt.name, status, apiErr, tt.status, tt.code, tt.field)
+		}
+
+		if !strings.Contains(apiErr.Message, tt.inMessage) {
+			t.Errorf("%s: message %q does not contain %q", tt.name, apiErr.Message, tt.inMessage)
+		}
+	}
+}
+
+func TestToAPIErrorHidesDetailsInReleaseMode(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+
+	for _, err := range []error{errors.New("pq: password authentication failed"), fmt.Errorf("pq: %w", gorm.ErrRecordNotFound)} {
+		if _, apiErr := toAPIError(err); strings.Contains(apiErr.Message, "pq:") {
+			t.Errorf("message %q reveals the error in release mode", apiErr.Message)
+		}
+	}
+}
@@ -4,27 +4,41 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/koodeyo/koodnet/pkg/database"
 	"github.com/koodeyo/koodnet/pkg/models"
-	"gorm.io/gorm"
+	"github.com/koodeyo/koodnet/pkg/service"
 )
 
 // FindHosts godoc
 // @Summary Get all hosts
-// @Description Get a list of all hosts with optional pagination
+// @Description Get a list of all hosts with optional filters, sorting and pagination.
+// @Description Large lists are better paged with ?cursor and ?limit, ordered by creation, which skip no hosts while hosts are added.
 // @Tags hosts
 // @Produce json
+// @Param networkId query string false "Filter by network ID"
+// @Param name query string false "Filter by exact name"
+// @Param name~ query string false "Filter by names containing the value, case-insensitive"
+// @Param site query string false "Filter by site"
+// @Param group query string false "Filter by group"
+// @Param ip query string false "Filter by overlay IP, or by a CIDR containing it, e.g. 100.100.0.0/24"
+// @Param lighthouse query bool false "Filter lighthouses"
+// @Param relay query bool false "Filter relays"
+// @Param deleted query bool false "List the deleted hosts instead, which can be restored until they are purged"
+// @Param sort query string false "Comma-separated sort fields, prefixed with - for descending: name, ip, site, createdAt, updatedAt" default(createdAt)
 // @Param page query int false "page for pagination" default(1)
 // @Param pageSize query int false "pageSize for pagination" default(10)
+// @Param cursor query string false "Page by cursor instead, starting after the item of the cursor: empty for the first page, then the nextCursor of the previous page"
+// @Param limit query int false "Page size of cursor pagination" default(10)
+// @Param count query bool false "Count the total, which is done by default with pages and not with cursors"
 // @Success 200 {object} api.paginatedResponse[models.Host]
+// @Failure 400 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /hosts [get]
 func FindHosts(c *gin.Context) {
-	var hosts []models.Host
-	// TODO: filter lighthouse and relays
-	// db.Joins("Configuration").Where("configuration.lighthouse_am_lighthouse = ?", true).Find(&hosts)
-
-	// Fetch data from the database with pagination
-	database.Conn.Model(&models.Host{}).Scopes(models.Paginate(c)).Find(&hosts)
+	hosts, err := service.ListHosts(callerContext(c), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
 
 	response := paginated(hosts, c)
 
@@ -38,8 +52,12 @@ func FindHosts(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param host body models.HostDto true "Host Payload"
+// @Param dryRun query bool false "Preview the config changes of every host in the network without creating the host"
 // @Success 201 {object} models.Host
+// @Success 200 {object} api.dryRunResponse "Dry run"
 // @Failure 400 {object} api.errorResponse
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Security BearerAuth
 // @Router /hosts [post]
 func CreateHost(c *gin.Context) {
 	var dto models.HostDto
@@ -57,39 +75,39 @@ func CreateHost(c *gin.Context) {
 		return
 	}
 
-	// Create new host instance
-	host := models.Host{
-		IP:              dto.IP,
-		Name:            dto.Name,
-		Groups:          dto.Groups,
-		Subnets:         dto.Subnets,
-		NetworkID:       dto.NetworkID,
-		Configuration:   dto.Configuration,
-		InPub:           []byte(dto.InPub),
-		StaticAddresses: dto.StaticAddresses,
-	}
+	createHost(c, dto)
+}
 
-	// Save to database
-	if err := database.Conn.Create(&host).Error; err != nil {
+// createHost creates a host in the network of the payload, or previews it on dry runs.
+func createHost(c *gin.Context, dto models.HostDto) {
+	opts := service.WriteOptions{DryRun: isDryRun(c)}
+	host, diffs, err := service.CreateHost(callerContext(c), dto, opts)
+	if err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
 
+	if opts.DryRun {
+		c.JSON(http.StatusOK, dryRunResponse{Diffs: diffs})
+		return
+	}
+
+	setETag(c, host.ETag())
 	c.JSON(http.StatusCreated, host)
 }
 
 // DeleteHost godoc
 // @Summary Delete a host
-// @Description Delete a host by ID
+// @Description Delete a host by ID. It can be restored until it is purged after the retention period (KOODNET_DELETED_RETENTION, 30 days by default),
+// @Description which also removes its certificate and configuration for good. Its name and IP can be reused meanwhile, which prevents restoring it.
 // @Tags hosts
 // @Param id path string true "Host ID"
 // @Success 200 {object} map[string]bool "Delete status"
 // @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /hosts/{id} [delete]
 func DeleteHost(c *gin.Context) {
-	id := c.Param("id")
-
-	if err := database.Conn.Delete(&models.Host{}, "id = ?", id).Error; err != nil {
+	if err := service.DeleteHost(callerContext(c), c.Param("id")); err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
@@ -105,16 +123,16 @@ func DeleteHost(c *gin.Context) {
 // @Produce json
 // @Success 200 {object} models.Host
 // @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /hosts/{id} [get]
 func FindHost(c *gin.Context) {
-	id := c.Param("id")
-	var host models.Host
-
-	if err := database.Conn.Preload("Configuration").First(&host, "id = ?", id).Error; err != nil {
+	host, err := service.GetHost(callerContext(c), c.Param("id"))
+	if err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
 
+	setETag(c, host.ETag())
 	c.JSON(http.StatusOK, host)
 }
 
@@ -126,20 +144,16 @@ func FindHost(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Host ID"
 // @Param host body models.HostDto true "Updated host details"
+// @Param If-Match header string false "ETag of the host as last read. The update fails with 412 if the host changed since"
+// @Param dryRun query bool false "Preview the config changes of every host in the network without updating the host"
 // @Success 200 {object} models.Host
+// @Success 200 {object} api.dryRunResponse "Dry run"
 // @Failure 400 {object} api.errorResponse
 // @Failure 404 {object} api.errorResponse
+// @Failure 412 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /hosts/{id} [put]
 func UpdateHost(c *gin.Context) {
-	id := c.Param("id")
-	var host models.Host
-
-	// Find existing host
-	if err := database.Conn.Preload("Configuration").First(&host, "id = ?", id).Error; err != nil {
-		dbErrorHandler(err, c)
-		return
-	}
-
 	// Bind the update payload
 	var dto models.Host
 	if err := c.ShouldBindJSON(&dto); err != nil {
@@ -154,24 +168,19 @@ func UpdateHost(c *gin.Context) {
 		return
 	}
 
-	hasCfg := dto.Configuration != nil
-
-	// - Full association saving is conditionally enabled when a "Configuration" update is present.
-	dto.ID = host.ID
-	if hasCfg {
-		dto.Configuration.ID = host.Configuration.ID
-		dto.ConfigurationID = host.ConfigurationID
-	}
-
-	// Update the host
-	if err := database.Conn.Session(&gorm.Session{FullSaveAssociations: hasCfg}).Updates(&dto).Error; err != nil {
+	opts := service.WriteOptions{IfMatch: c.GetHeader("If-Match"), DryRun: isDryRun(c)}
+	host, diffs, err := service.UpdateHost(callerContext(c), c.Param("id"), dto, opts)
+	if err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
 
-	// Refresh host updates
-	database.Conn.Preload("Configuration").First(&host, "id = ?", id)
+	if opts.DryRun {
+		c.JSON(http.StatusOK, dryRunResponse{Diffs: diffs})
+		return
+	}
 
+	setETag(c, host.ETag())
 	c.JSON(http.StatusOK, host)
 }
 
@@ -183,20 +192,20 @@ func UpdateHost(c *gin.Context) {
 // @Param download query string false "Set this parameter to trigger file download (e.g., ?download=true)"
 // @Produce application/x-yaml
 // @Success 200 {string} YAML configuration of the host
+// @Header 200 {string} X-Koodnet-Config-Revision "Revision of the config, for /hosts/{id}/config/watch"
 // @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /hosts/{id}/config.yml [get]
 func FindHostYamlConfig(c *gin.Context) {
-	id := c.Param("id")
-	download := c.Query("download")
-	var host models.Host
-
-	if err := database.Conn.Scopes(models.PreloadHostWithFullDetails(id)).First(&host).Error; err != nil {
+	ymlStr, revision, err := service.HostConfig(callerContext(c), c.Param("id"))
+	if err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
 
-	ymlStr, _ := host.Marshal(true)
-	if download == "" {
+	c.Header(configRevisionHeader, revision)
+
+	if c.Query("download") == "" {
 		c.String(http.StatusOK, ymlStr)
 		return
 	}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"gopkg.in/yaml.v2"
+)
+
+// maxBatchHosts limits the number of hosts created by a single batch request.
+const maxBatchHosts = 1000
+
+// batchHostResult is the outcome of one row of a batch.
+type batchHostResult struct {
+	Row    int          `json:"row" example:"1"` // 1-based position of the host in the payload, or line in a CSV file after the header.
+	Name   string       `json:"name" example:"host-1"`
+	Host   *models.Host `json:"host,omitempty"`
+	Errors []apiError   `json:"errors,omitempty"`
+}
+
+type batchHostsResponse struct {
+	Created int               `json:"created"` // Number of hosts created. 0 when any row failed, as the batch is then rolled back.
+	Results []batchHostResult `json:"results"`
+}
+
+// networkAction dispatches POST /networks/:id/<resource>:<action> routes, which gin cannot match literally.
+func networkAction(c *gin.Context) {
+	switch c.Param("action") {
+	case "hosts:batch":
+		BatchCreateNetworkHosts(c)
+	default:
+		notFoundHandler(c)
+	}
+}
+
+// BatchCreateNetworkHosts godoc
+// @Summary Create many hosts in a network
+// @Description Create hosts from a JSON array of hosts, a CSV or YAML body, or a multipart upload of such a file in the "file" field.
+// @Description Hosts without an IP get the next free address of the network. All hosts are created in one transaction:
+// @Description when any row fails, no host is created and the errors of every failing row are returned with status 422.
+// @Description CSV files need a header row with any of the columns name, ip, site, groups, subnets, lighthouses, relays, staticAddresses and inPub.
+// @Description List columns separate their values with ";".
+// @Tags networks
+// @Accept json
+// @Accept text/csv
+// @Accept application/x-yaml
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Network ID"
+// @Param hosts body []models.HostDto true "Hosts"
+// @Success 201 {object} api.batchHostsResponse
+// @Failure 400 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Failure 422 {object} api.batchHostsResponse
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Security BearerAuth
+// @Router /networks/{id}/hosts:batch [post]
+func BatchCreateNetworkHosts(c *gin.Context) {
+	dtos, err := parseBatchHosts(c)
+	if err == nil && len(dtos) == 0 {
+		err = errors.New("no hosts given")
+	}
+	if err == nil && len(dtos) > maxBatchHosts {
+		err = fmt.Errorf("at most %d hosts can be created at once", maxBatchHosts)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	created, err := service.BatchCreateHosts(callerContext(c), c.Param("id"), dtos)
+	if err != nil && !errors.Is(err, service.ErrBatchFailed) {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	results := make([]batchHostResult, len(created))
+	for i, r := range created {
+		results[i] = batchHostResult{Row: r.Row, Name: r.Name, Host: r.Host}
+		for _, err := range r.Errors {
+			_, apiErr := toAPIError(err)
+			results[i].Errors = append(results[i].Errors, apiErr)
+		}
+	}
+
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, batchHostsResponse{Results: results})
+		return
+	}
+
+	c.JSON(http.StatusCreated, batchHostsResponse{Created: len(results), Results: results})
+}
+
+// parseBatchHosts reads the hosts of a batch from the request body or an uploaded file.
+func parseBatchHosts(c *gin.Context) ([]models.HostDto, error) {
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+
+	if contentType == "multipart/form-data" {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		switch strings.ToLower(filepath.Ext(fh.Filename)) {
+		case ".csv":
+			return parseHostsCSV(f)
+		case ".yaml", ".yml":
+			return parseHostsYAML(f)
+		case ".json":
+			return parseHostsJSON(f)
+		default:
+			return nil, errors.New("unsupported file type " + filepath.Ext(fh.Filename) + "; use .csv, .yaml, .yml or .json")
+		}
+	}
+
+	switch contentType {
+	case "text/csv":
+		return parseHostsCSV(c.Request.Body)
+	case "application/x-yaml", "application/yaml", "text/yaml":
+		return parseHostsYAML(c.Request.Body)
+	default:
+		return parseHostsJSON(c.Request.Body)
+	}
+}
+
+func parseHostsJSON(r io.Reader) ([]models.HostDto, error) {
+	var dtos []models.HostDto
+	if err := json.NewDecoder(r).Decode(&dtos); err != nil {
+		return nil, err
+	}
+
+	return dtos, nil
+}
+
+// parseHostsYAML reads a YAML list of hosts with the same fields as the JSON payload.
+func parseHostsYAML(r io.Reader) ([]models.HostDto, error) {
+	var dtos []models.HostDto
+	if err := decodeYAML(r, &dtos); err != nil {
+		return nil, err
+	}
+
+	return dtos, nil
+}
+
+// decodeYAML decodes a YAML document into out, round-tripping it through JSON so that YAML uses the JSON field names.
+func decodeYAML(r io.Reader, out any) error {
+	var doc any
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(stringKeys(doc))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, out)
+}
+
+// stringKeys converts the maps of a decoded YAML document, which have keys of any type, into maps with string keys
+// that can be encoded as JSON.
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = stringKeys(value)
+		}
+		return m
+	case []any:
+		for i := range v {
+			v[i] = stringKeys(v[i])
+		}
+	}
+
+	return v
+}
+
+// parseHostsCSV reads hosts from a CSV file with a header row. List values are separated by ";".
+func parseHostsCSV(r io.Reader) ([]models.HostDto, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	list := func(v string) []string {
+		var values []string
+		for _, s := range strings.Split(v, ";") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	var dtos []models.HostDto
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var dto models.HostDto
+		for i, column := range header {
+			v := strings.TrimSpace(record[i])
+
+			switch strings.TrimSpace(column) {
+			case "name":
+				dto.Name = v
+			case "ip":
+				dto.IP = v
+			case "site":
+				dto.Site = v
+			case "inPub":
+				dto.InPub = v
+			case "groups":
+				dto.Groups = list(v)
+			case "subnets":
+				dto.Subnets = list(v)
+			case "lighthouses":
+				dto.Lighthouses = list(v)
+			case "relays":
+				dto.Relays = list(v)
+			case "staticAddresses":
+				for _, s := range list(v) {
+					addr, err := models.ParseStaticAddress(s)
+					if err != nil {
+						return nil, fmt.Errorf("line %d: %w", line, err)
+					}
+					dto.StaticAddresses = append(dto.StaticAddresses, addr)
+				}
+			default:
+				return nil, errors.New("unknown CSV column: " + column)
+			}
+		}
+
+		dtos = append(dtos, dto)
+	}
+
+	return dtos, nil
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/middleware"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	idempotencyKeyMaxLen = 255
+)
+
+// responseRecorder keeps a copy of the response body written by a handler.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent replays the stored response of requests retried with the same Idempotency-Key header.
+// Keys are scoped to the API token and route, and kept for the TTL. Stored responses leave out private keys,
+// which callers with keys:read can read from the resource. Server errors and panics release the key, so that
+// the request can be retried, and so does the end of its lease when the server stops mid-request.
+// Requests without the header and dry runs are not affected.
+func idempotent(ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" || isDryRun(c) {
+			c.Next()
+			return
+		}
+
+		if len(key) > idempotencyKeyMaxLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse{
+				Errors: []apiError{
+					{
+						Code:    "INVALID_INPUT",
+						Message: "The Idempotency-Key header cannot be longer than 255 characters.",
+					},
+				},
+			})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse{
+				Errors: []apiError{
+					{
+						Code:    "INVALID_DATA",
+						Message: err.Error(),
+					},
+				},
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		scope := middleware.CurrentToken(c).ID.String() + " " + c.Request.Method + " " + c.Request.URL.Path
+
+		record, claimed, err := models.ClaimIdempotencyKey(database.Conn, scope, key, hex.EncodeToString(sum[:]), models.IdempotencyLease)
+		if err != nil {
+			dbErrorHandler(err, c)
+			c.Abort()
+			return
+		}
+
+		// Replay the first response
+		if !claimed {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		// Release the key when the handler panics, before the recovery middleware responds
+		defer func() {
+			if r := recover(); r != nil {
+				database.Conn.Delete(record)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			database.Conn.Delete(record)
+			return
+		}
+
+		if err := record.Complete(database.Conn, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes(), ttl); err != nil {
+			c.Error(err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/middleware"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm/logger"
+)
+
+// connectTestDB points database.Conn at a fresh SQLite database.
+func connectTestDB(t *testing.T) {
+	t.Helper()
+
+	database.Connect(config.DatabaseConfig{SQLitePath: filepath.Join(t.TempDir(), "koodnet.db")})
+	database.Conn.Logger = logger.Discard
+	database.Migrate()
+}
+
+// newIdempotencyRouter serves POST /items with a handler that counts its calls. Every bearer token is accepted
+// as a token of its own. Requests with the body "fail" get a 500, those with "panic" panic, and those with
+// "keys" get a network with key material.
+func newIdempotencyRouter(t *testing.T, calls *int) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	connectTestDB(t)
+
+	tokens := map[string]*models.APIToken{}
+	auth := middleware.Auth(func(raw string) (*models.APIToken, error) {
+		if tokens[raw] == nil {
+			tokens[raw] = &models.APIToken{ID: uuid.New(), Name: raw}
+		}
+		return tokens[raw], nil
+	})
+
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.POST("/items", auth, idempotent(time.Hour), func(c *gin.Context) {
+		*calls++
+
+		body, _ := c.GetRawData()
+		switch string(body) {
+		case "fail":
+			c.JSON(http.StatusInternalServerError, gin.H{"call": *calls})
+		case "panic":
+			panic("handler failed")
+		case "keys":
+			c.JSON(http.StatusCreated, gin.H{
+				"call":       *calls,
+				"passphrase": "secret",
+				"ca":         []gin.H{{"crt": "cert", "key": "private", "passphrase": "secret"}},
+				"pki":        gin.H{"ca": "/etc/nebula/ca.crt", "key": "/etc/nebula/host.key"},
+			})
+		default:
+			c.JSON(http.StatusCreated, gin.H{"call": *calls})
+		}
+	})
+
+	return r
+}
+
+func postItem(r http.Handler, token, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotentReplaysResponse(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(t, &calls)
+
+	first := postItem(r, "alice", "key-1", `{"name":"a"}`)
+	retry := postItem(r, "alice", "key-1", `{"name":"a"}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry got %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response is missing the Idempotent-Replayed header")
+	}
+
+	if retry.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("retry has Content-Type %q, want %q", retry.Header().Get("Content-Type"), first.Header().Get("Content-Type"))
+	}
+}
+
+func TestIdempotentKeyScopes(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(t, &calls)
+
+	postItem(r, "alice", "key-1", `{}`)
+
+	// Requests without a key, with another key or of another token are not replays
+	postItem(r, "alice", "", `{}`)
+	postItem(r, "alice", "key-2", `{}`)
+	postItem(r, "bob", "key-1", `{}`)
+
+	if calls != 4 {
+		t.Errorf("handler ran %d times, want 4", calls)
+	}
+}
+
+func TestIdempotentKeyConflicts(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(t, &calls)
+
+	postItem(r, "alice", "key-1", `{"name":"a"}`)
+
+	// The same key with another body
+	w := postItem(r, "alice", "key-1", `{"name":"b"}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "ERR_IDEMPOTENCY_KEY_REUSED") {
+		t.Errorf("reused key got %d %s, want a 422 ERR_IDEMPOTENCY_KEY_REUSED", w.Code, w.Body)
+	}
+
+	// A key whose first request has not finished
+	_, _, err := models.ClaimIdempotencyKey(database.Conn, "scope", "pending", "hash", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := models.ClaimIdempotencyKey(database.Conn, "scope", "pending", "hash", time.Hour); !errors.Is(err, models.ErrIdempotencyKeyInProgress) {
+		t.Errorf("claiming a pending key returned %v, want ErrIdempotencyKeyInProgress", err)
+	}
+
+	// Keys that are too long
+	w = postItem(r, "alice", strings.Repeat("k", idempotencyKeyMaxLen+1), `{}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("long key got %d, want 400", w.Code)
+	}
+
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want once", calls)
+	}
+}
+
+func TestIdempotentServerErrorsAreRetried(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(t, &calls)
+
+	for i := 1; i <= 2; i++ {
+		w := postItem(r, "alice", "key-1", "fail")
+		if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), strconv.Itoa(i)) {
+			t.Errorf("attempt %d got %d %s, want the 500 of call %d", i, w.Code, w.Body, i)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want twice", calls)
+	}
+}
+
+func TestIdempotentPanicsAreRetried(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(t, &calls)
+
+	for i := 1; i <= 2; i++ {
+		if w := postItem(r, "alice", "key-1", "panic"); w.Code != http.StatusInternalServerError {
+			t.Errorf("attempt %d got %d, want 500", i, w.Code)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want twice", calls)
+	}
+}
+
+func TestIdempotentReplaysLeaveOutKeys(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(t, &calls)
+
+	first := postItem(r, "alice", "key-1", "keys")
+	if !strings.Contains(first.Body.String(), "private") {
+		t.Fatalf("first response %s is missing the key", first.Body)
+	}
+
+	var stored models.IdempotencyKey
+	if err := database.Conn.First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	retry := postItem(r, "alice", "key-1", "keys")
+	for _, body := range []string{string(stored.Body), retry.Body.String()} {
+		if strings.Contains(body, "private") || strings.Contains(body, "secret") {
+			t.Errorf("stored or replayed response %s has key material", body)
+		}
+
+		// Paths of key files are no key material
+		if !strings.Contains(body, "/etc/nebula/host.key") {
+			t.Errorf("stored or replayed response %s lost the key path", body)
+		}
+	}
+}
+
+func TestIdempotentExpiredKeysCanBeReused(t *testing.T) {
+	connectTestDB(t)
+
+	// The lease of a request that never completed ended
+	if _, claimed, err := models.ClaimIdempotencyKey(database.Conn, "scope", "key", "first", -time.Second); err != nil || !claimed {
+		t.Fatalf("first claim = %v, %v, want claimed", claimed, err)
+	}
+
+	record, claimed, err := models.ClaimIdempotencyKey(database.Conn, "scope", "key", "second", models.IdempotencyLease)
+	if err != nil || !claimed {
+		t.Fatalf("claim of an expired lease = %v, %v, want claimed", claimed, err)
+	}
+
+	// The response of a completed request expired
+	if err := record.Complete(database.Conn, http.StatusCreated, "application/json", []byte("{}"), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, claimed, err := models.ClaimIdempotencyKey(database.Conn, "scope", "key", "third", models.IdempotencyLease); err != nil || !claimed {
+		t.Errorf("claim of an expired key = %v, %v, want claimed", claimed, err)
+	}
+}
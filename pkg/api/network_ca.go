@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// RotateNetworkCA godoc
+// @Summary Rotate the CA of a network
+// @Description Issue a new CA for a network. Hosts signed afterwards get certificates of the new CA, while the previous CAs
+// @Description stay trusted until they expire. Sends the ca.rotated webhook event, and config.changed for every host.
+// @Tags networks
+// @Produce json
+// @Param id path string true "Network ID"
+// @Success 201 {object} models.Certificate "The key is only included with the keys:read scope"
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/ca/rotate [post]
+func RotateNetworkCA(c *gin.Context) {
+	ca, err := service.RotateNetworkCA(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ca)
+}
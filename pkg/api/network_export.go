@@ -0,0 +1,125 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// passphraseHeader carries the passphrase that encrypts network exports, kept out of URLs and access logs.
+const passphraseHeader = "X-Koodnet-Passphrase"
+
+// ExportNetwork godoc
+// @Summary Export a network
+// @Description Export the network with its CAs, hosts, configurations and certificates, including their private keys,
+// @Description for offline backups and for importing it into another koodnet instance with POST /networks/import.
+// @Description The export is encrypted with AES-256-GCM, keyed by the Argon2id hash of the passphrase, when one is sent
+// @Description in the X-Koodnet-Passphrase header. Deleted hosts are left out.
+// @Tags networks
+// @Produce json
+// @Param id path string true "Network ID"
+// @Param X-Koodnet-Passphrase header string false "Passphrase to encrypt the export with"
+// @Success 200 {object} models.NetworkExport
+// @Failure 403 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/export [get]
+func ExportNetwork(c *gin.Context) {
+	n, export, err := service.ExportNetwork(callerContext(c), c.Param("id"), c.GetHeader(passphraseHeader))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": n.Name + ".koodnet.json"}))
+	c.JSON(http.StatusOK, export)
+}
+
+// ImportNetwork godoc
+// @Summary Import a network
+// @Description Import a network exported with GET /networks/{id}/export, with its CAs, hosts, configurations and certificates.
+// @Description IDs are kept by default, so that agents and integrations keep working after moving the network between instances.
+// @Description With ?ids=remap everything gets new IDs, e.g. to import a copy next to the exported network under another ?name.
+// @Description The caller becomes the owner of the imported network.
+// @Tags networks
+// @Accept json
+// @Produce json
+// @Param export body models.NetworkExport true "Network export"
+// @Param X-Koodnet-Passphrase header string false "Passphrase of an encrypted export"
+// @Param ids query string false "Keep the IDs of the export or give everything new IDs" Enums(keep, remap) default(keep)
+// @Param organizationId query string false "Organization to import the network into"
+// @Param name query string false "Name of the imported network, instead of its exported name"
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Success 201 {object} models.Network
+// @Failure 400 {object} api.errorResponse "Invalid export, or a missing or wrong passphrase"
+// @Failure 403 {object} api.errorResponse
+// @Failure 409 {object} api.errorResponse "The network, a host, certificate or configuration with the same ID or name exists already"
+// @Failure 422 {object} api.errorResponse "Unsupported export version"
+// @Security BearerAuth
+// @Router /networks/import [post]
+func ImportNetwork(c *gin.Context) {
+	var export models.NetworkExport
+
+	if err := c.ShouldBindJSON(&export); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	ids := c.DefaultQuery("ids", "keep")
+	if ids != "keep" && ids != "remap" {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_INPUT",
+					Message: "ids must be 'keep' or 'remap'",
+					Field:   "ids",
+				},
+			},
+		})
+		return
+	}
+
+	var requested *uuid.UUID
+	if param := c.Query("organizationId"); param != "" {
+		id, err := uuid.Parse(param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse{
+				Errors: []apiError{
+					{
+						Code:    "INVALID_INPUT",
+						Message: "organizationId must be a UUID",
+						Field:   "organizationId",
+					},
+				},
+			})
+			return
+		}
+		requested = &id
+	}
+
+	n, err := service.ImportNetwork(callerContext(c), export, service.ImportOptions{
+		Passphrase:     c.GetHeader(passphraseHeader),
+		RemapIDs:       ids == "remap",
+		OrganizationID: requested,
+		Name:           c.Query("name"),
+	})
+
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	setETag(c, n.ETag())
+	c.JSON(http.StatusCreated, n)
+}
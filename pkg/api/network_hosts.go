@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// FindNetworkHosts godoc
+// @Summary Get the hosts of a network
+// @Description Get a list of the hosts of a network with optional filters, sorting and pagination.
+// @Description Large lists are better paged with ?cursor and ?limit, ordered by creation, which skip no hosts while hosts are added.
+// @Tags networks
+// @Produce json
+// @Param id path string true "Network ID"
+// @Param name query string false "Filter by exact name"
+// @Param name~ query string false "Filter by names containing the value, case-insensitive"
+// @Param site query string false "Filter by site"
+// @Param group query string false "Filter by group"
+// @Param ip query string false "Filter by overlay IP, or by a CIDR containing it, e.g. 100.100.0.0/24"
+// @Param lighthouse query bool false "Filter lighthouses"
+// @Param relay query bool false "Filter relays"
+// @Param deleted query bool false "List the deleted hosts instead, which can be restored until they are purged"
+// @Param sort query string false "Comma-separated sort fields, prefixed with - for descending: name, ip, site, createdAt, updatedAt" default(createdAt)
+// @Param page query int false "page for pagination" default(1)
+// @Param pageSize query int false "pageSize for pagination" default(10)
+// @Param cursor query string false "Page by cursor instead, starting after the item of the cursor: empty for the first page, then the nextCursor of the previous page"
+// @Param limit query int false "Page size of cursor pagination" default(10)
+// @Param count query bool false "Count the total, which is done by default with pages and not with cursors"
+// @Success 200 {object} api.paginatedResponse[models.Host]
+// @Failure 400 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/hosts [get]
+func FindNetworkHosts(c *gin.Context) {
+	hosts, err := service.ListNetworkHosts(callerContext(c), c.Param("id"), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
+
+	response := paginated(hosts, c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateNetworkHost godoc
+// @Summary Create a host in a network
+// @Description Create a host in the network of the path. A networkId in the payload is ignored.
+// @Tags networks
+// @Accept json
+// @Produce json
+// @Param id path string true "Network ID"
+// @Param host body models.HostDto true "Host Payload"
+// @Param dryRun query bool false "Preview the config changes of every host in the network without creating the host"
+// @Success 201 {object} models.Host
+// @Success 200 {object} api.dryRunResponse "Dry run"
+// @Failure 400 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Security BearerAuth
+// @Router /networks/{id}/hosts [post]
+func CreateNetworkHost(c *gin.Context) {
+	var dto models.HostDto
+
+	// Validate the request body
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	opts := service.WriteOptions{DryRun: isDryRun(c)}
+	host, diffs, err := service.CreateNetworkHost(callerContext(c), c.Param("id"), dto, opts)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	if opts.DryRun {
+		c.JSON(http.StatusOK, dryRunResponse{Diffs: diffs})
+		return
+	}
+
+	setETag(c, host.ETag())
+	c.JSON(http.StatusCreated, host)
+}
+
+// FindNetworkHost godoc
+// @Summary Get a host of a network by name
+// @Description Retrieve details of a single host by its name, which is unique within the network
+// @Tags networks
+// @Param id path string true "Network ID"
+// @Param hostName path string true "Host name"
+// @Produce json
+// @Success 200 {object} models.Host
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/hosts/{hostName} [get]
+func FindNetworkHost(c *gin.Context) {
+	host, err := service.GetNetworkHost(callerContext(c), c.Param("id"), c.Param("hostName"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	setETag(c, host.ETag())
+	c.JSON(http.StatusOK, host)
+}
@@ -4,9 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/koodeyo/koodnet/pkg/database"
 	"github.com/koodeyo/koodnet/pkg/models"
-	"gorm.io/gorm/clause"
+	"github.com/koodeyo/koodnet/pkg/service"
 )
 
 // FindNetworks godoc
@@ -14,15 +13,25 @@ import (
 // @Description Get a list of all networks with optional pagination
 // @Tags networks
 // @Produce json
+// @Param organizationId query string false "Filter by organization ID"
+// @Param name query string false "Filter by exact name"
+// @Param name~ query string false "Filter by names containing the value, case-insensitive"
+// @Param group query string false "Filter by group"
+// @Param curve query string false "Filter by curve" Enums(25519, P256)
+// @Param deleted query bool false "List the deleted networks instead, which can be restored until they are purged"
+// @Param sort query string false "Comma-separated sort fields, prefixed with - for descending: name, createdAt, updatedAt" default(createdAt)
 // @Param page query int false "page for pagination" default(1)
 // @Param pageSize query int false "pageSize for pagination" default(10)
 // @Success 200 {object} api.paginatedResponse[models.Network]
+// @Failure 400 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /networks [get]
 func FindNetworks(c *gin.Context) {
-	var networks []models.Network
-
-	// Fetch data from the database
-	database.Conn.Model(&models.Network{}).Scopes(models.Paginate(c)).Find(&networks)
+	networks, err := service.ListNetworks(callerContext(c), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
 
 	response := paginated(networks, c)
 
@@ -39,6 +48,8 @@ func FindNetworks(c *gin.Context) {
 // @Param network body models.NetworkDto true "Network Payload"
 // @Success 201 {object} models.Network
 // @Failure 400 {object} api.errorResponse
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Security BearerAuth
 // @Router /networks [post]
 func CreateNetwork(c *gin.Context) {
 	var dto models.NetworkDto
@@ -56,43 +67,37 @@ func CreateNetwork(c *gin.Context) {
 		return
 	}
 
-	n := models.Network{
-		Name:             dto.Name,             // Name of the network
-		IPs:              dto.IPs,              // List of IP ranges
-		Subnets:          dto.Subnets,          // List of subnets
-		Groups:           dto.Groups,           // Associated groups
-		Duration:         dto.Duration,         // Duration in seconds
-		Encrypt:          dto.Encrypt,          // Whether encryption is enabled
-		Passphrase:       dto.Passphrase,       // Encryption passphrase
-		ArgonMemory:      dto.ArgonMemory,      // Memory usage for Argon2
-		ArgonIterations:  dto.ArgonIterations,  // Iterations for Argon2
-		ArgonParallelism: dto.ArgonParallelism, // Parallelism for Argon2
-		Curve:            dto.Curve,            // Cryptographic curve (e.g., 25519)
-	}
+	createNetwork(c, dto)
+}
 
-	// Save to the database
-	if err := database.Conn.Create(&n).Error; err != nil {
+// createNetwork creates a network in the caller's organization, making the caller its owner.
+func createNetwork(c *gin.Context, dto models.NetworkDto) {
+	n, err := service.CreateNetwork(callerContext(c), dto)
+	if err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
 
 	// Respond with the created network
+	setETag(c, n.ETag())
 	c.JSON(http.StatusCreated, n)
 }
 
 // DeleteNetwork godoc
 // @Summary Delete a network
-// @Description Delete a network by ID
+// @Description Delete a network by ID, together with its hosts. They can be restored until they are purged after the retention period
+// @Description (KOODNET_DELETED_RETENTION, 30 days by default), which also removes their CAs, certificates and configurations for good.
+// @Description A network that still has hosts is only deleted with ?confirm set to its name.
 // @Tags networks
 // @Param id path string true "Network ID"
+// @Param confirm query string false "Name of the network, required when it still has hosts"
 // @Success 200 {object} map[string]bool "Delete status"
 // @Failure 404 {object} api.errorResponse
+// @Failure 409 {object} api.errorResponse "The network has hosts and ?confirm does not match its name"
+// @Security BearerAuth
 // @Router /networks/{id} [delete]
 func DeleteNetwork(c *gin.Context) {
-	id := c.Param("id")
-
-	// Attempt to delete the network
-	if err := database.Conn.Delete(&models.Network{}, "id = ?", id).Error; err != nil {
+	if err := service.DeleteNetwork(callerContext(c), c.Param("id"), c.Query("confirm")); err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
@@ -108,18 +113,17 @@ func DeleteNetwork(c *gin.Context) {
 // @Produce json
 // @Success 200 {object} models.Network
 // @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /networks/{id} [get]
 func FindNetwork(c *gin.Context) {
-	id := c.Param("id")
-	var network models.Network
-
-	// Attempt to find the network
-	if err := database.Conn.Preload("Ca").Preload("Hosts").First(&network, "id = ?", id).Error; err != nil {
+	network, err := service.GetNetwork(callerContext(c), c.Param("id"))
+	if err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
 
 	// Respond with the found network
+	setETag(c, network.ETag())
 	c.JSON(http.StatusOK, network)
 }
 
@@ -131,20 +135,16 @@ func FindNetwork(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Network ID"
 // @Param network body models.NetworkDto true "Updated network details"
+// @Param If-Match header string false "ETag of the network as last read. The update fails with 412 if the network changed since"
+// @Param dryRun query bool false "Preview the config changes of every host in the network without updating it"
 // @Success 200 {object} models.Network
+// @Success 200 {object} api.dryRunResponse "Dry run"
 // @Failure 400 {object} api.errorResponse
 // @Failure 404 {object} api.errorResponse
+// @Failure 412 {object} api.errorResponse
+// @Security BearerAuth
 // @Router /networks/{id} [patch]
 func UpdateNetwork(c *gin.Context) {
-	id := c.Param("id")
-	var n models.Network
-
-	// Attempt to find the existing network
-	if err := database.Conn.Omit(clause.Associations).First(&n, "id = ?", id).Error; err != nil {
-		dbErrorHandler(err, c)
-		return
-	}
-
 	// Bind the payload JSON to a new network struct
 	var u models.NetworkDto
 	if err := c.ShouldBindJSON(&u); err != nil {
@@ -159,12 +159,19 @@ func UpdateNetwork(c *gin.Context) {
 		return
 	}
 
-	// Save the updated network to the database
-	if err := database.Conn.Model(&n).Updates(u).Error; err != nil {
+	opts := service.WriteOptions{IfMatch: c.GetHeader("If-Match"), DryRun: isDryRun(c)}
+	n, diffs, err := service.UpdateNetwork(callerContext(c), c.Param("id"), u, opts)
+	if err != nil {
 		dbErrorHandler(err, c)
 		return
 	}
 
+	if opts.DryRun {
+		c.JSON(http.StatusOK, dryRunResponse{Diffs: diffs})
+		return
+	}
+
 	// Respond with the updated network
+	setETag(c, n.ETag())
 	c.JSON(http.StatusOK, n)
 }
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// FindOrganizations godoc
+// @Summary Get all organizations
+// @Description Get a list of the organizations visible to the caller with optional pagination
+// @Tags organizations
+// @Produce json
+// @Param page query int false "page for pagination" default(1)
+// @Param pageSize query int false "pageSize for pagination" default(10)
+// @Success 200 {object} api.paginatedResponse[models.Organization]
+// @Security BearerAuth
+// @Router /organizations [get]
+func FindOrganizations(c *gin.Context) {
+	organizations, err := service.ListOrganizations(callerContext(c), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
+
+	response := paginated(organizations, c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateOrganization godoc
+// @Summary Create a new organization
+// @Description Create an organization with the provided details
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param organization body models.OrganizationDto true "Organization Payload"
+// @Success 201 {object} models.Organization
+// @Failure 400 {object} api.errorResponse
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Security BearerAuth
+// @Router /organizations [post]
+func CreateOrganization(c *gin.Context) {
+	var dto models.OrganizationDto
+
+	// Validate the payload
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	org, err := service.CreateOrganization(callerContext(c), dto)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// FindOrganization godoc
+// @Summary Get an organization by ID
+// @Description Retrieve details of a single organization
+// @Tags organizations
+// @Param id path string true "Organization ID"
+// @Produce json
+// @Success 200 {object} models.Organization
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /organizations/{id} [get]
+func FindOrganization(c *gin.Context) {
+	org, err := service.GetOrganization(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// UpdateOrganization godoc
+// @Summary Update an organization
+// @Description Update the name or quotas of an existing organization
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param organization body models.OrganizationDto true "Updated organization details"
+// @Success 200 {object} models.Organization
+// @Failure 400 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /organizations/{id} [patch]
+func UpdateOrganization(c *gin.Context) {
+	var dto models.OrganizationDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_INPUT",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	org, err := service.UpdateOrganization(callerContext(c), c.Param("id"), dto)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// DeleteOrganization godoc
+// @Summary Delete an organization
+// @Description Delete an organization by ID. Organizations that still have networks, including deleted networks that are not purged yet, cannot be deleted.
+// @Tags organizations
+// @Param id path string true "Organization ID"
+// @Success 200 {object} map[string]bool "Delete status"
+// @Failure 404 {object} api.errorResponse
+// @Failure 409 {object} api.errorResponse "The organization still has networks"
+// @Security BearerAuth
+// @Router /organizations/{id} [delete]
+func DeleteOrganization(c *gin.Context) {
+	if err := service.DeleteOrganization(callerContext(c), c.Param("id")); err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delete": true})
+}
+
+// FindOrganizationNetworks godoc
+// @Summary Get the networks of an organization
+// @Description Get a list of the organization's networks visible to the caller with optional pagination
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param name query string false "Filter by exact name"
+// @Param name~ query string false "Filter by names containing the value, case-insensitive"
+// @Param group query string false "Filter by group"
+// @Param curve query string false "Filter by curve" Enums(25519, P256)
+// @Param sort query string false "Comma-separated sort fields, prefixed with - for descending: name, createdAt, updatedAt" default(createdAt)
+// @Param page query int false "page for pagination" default(1)
+// @Param pageSize query int false "pageSize for pagination" default(10)
+// @Success 200 {object} api.paginatedResponse[models.Network]
+// @Failure 400 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /organizations/{id}/networks [get]
+func FindOrganizationNetworks(c *gin.Context) {
+	networks, err := service.ListOrganizationNetworks(callerContext(c), c.Param("id"), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
+
+	response := paginated(networks, c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateOrganizationNetwork godoc
+// @Summary Create a network in an organization
+// @Description Create a network owned by the organization with the provided details
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param network body models.NetworkDto true "Network Payload"
+// @Success 201 {object} models.Network
+// @Failure 400 {object} api.errorResponse
+// @Failure 403 {object} api.errorResponse
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Security BearerAuth
+// @Router /organizations/{id}/networks [post]
+func CreateOrganizationNetwork(c *gin.Context) {
+	org, err := service.GetOrganization(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	var dto models.NetworkDto
+
+	// Validate the payload
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	dto.OrganizationID = &org.ID
+
+	createNetwork(c, dto)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// prometheusTargetGroup is a target group in the Prometheus file_sd/http_sd format.
+type prometheusTargetGroup struct {
+	Targets []string          `json:"targets" example:"100.100.0.1:8080"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// FindNetworkPrometheusTargets godoc
+// @Summary Prometheus service discovery targets
+// @Description Get the nebula stats endpoints of every host in a network as Prometheus file_sd/http_sd target groups.
+// @Description Only hosts with prometheus stats enabled are listed. Targets use the host's overlay IP and stats port.
+// @Description Groups are joined with commas and wrapped in leading and trailing commas, e.g. ",laptop,ssh,".
+// @Tags networks
+// @Param id path string true "Network ID"
+// @Produce json
+// @Success 200 {array} api.prometheusTargetGroup
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/prometheus-sd.json [get]
+func FindNetworkPrometheusTargets(c *gin.Context) {
+	n, err := service.GetNetworkHosts(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	groups := []prometheusTargetGroup{}
+	for _, host := range n.Hosts {
+		cfg := host.Configuration
+		if cfg == nil || cfg.Stats.Type != "prometheus" {
+			continue
+		}
+
+		_, port, err := net.SplitHostPort(cfg.Stats.Listen)
+		if err != nil || port == "" {
+			continue
+		}
+
+		labels := map[string]string{
+			"network":    n.Name,
+			"host":       host.Name,
+			"groups":     "",
+			"lighthouse": strconv.FormatBool(cfg.Lighthouse.AmLighthouse),
+			"relay":      strconv.FormatBool(cfg.Relay.AmRelay),
+		}
+
+		if len(host.Groups) > 0 {
+			labels["groups"] = "," + strings.Join(host.Groups, ",") + ","
+		}
+
+		if cfg.Stats.Path != "" {
+			labels["__metrics_path__"] = cfg.Stats.Path
+		}
+
+		groups = append(groups, prometheusTargetGroup{
+			Targets: []string{net.JoinHostPort(host.GetIp(), port)},
+			Labels:  labels,
+		})
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
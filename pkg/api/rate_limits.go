@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/middleware"
+)
+
+// NewRateLimiter returns a rate limiter applying the policies of cfg, which keeps their counts in memory, or in
+// the database when the store is "database" so that API servers behind a load balancer share them.
+func NewRateLimiter(cfg config.RateLimitsConfig) (*middleware.RateLimiter, error) {
+	policies, err := middleware.ParseRatePolicies(cfg.Policies)
+	if err != nil {
+		return nil, fmt.Errorf("rate_limits.policies: %w", err)
+	}
+
+	var store middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if cfg.Store == "database" {
+		store = &middleware.DatabaseRateLimitStore{DB: database.Conn}
+	}
+
+	return &middleware.RateLimiter{Store: store, Policies: policies}, nil
+}
+
+// rateClass sorts authenticated routes into the rate limit classes of their policies.
+func rateClass(c *gin.Context) string {
+	path := strings.TrimPrefix(c.FullPath(), "/api/v1")
+
+	switch {
+	case c.Request.Method == http.MethodPost && (path == "/hosts/" || path == "/networks/:id/hosts" || path == "/networks/:id/:action"):
+		return middleware.RateClassEnroll
+	case path == "/hosts/:id/config.yml" || path == "/hosts/:id/config/watch":
+		return middleware.RateClassConfig
+	case strings.HasPrefix(path, "/tokens") || strings.HasPrefix(path, "/audit") || strings.HasPrefix(path, "/webhooks"):
+		return middleware.RateClassAdmin
+	case (path == "/organizations/" || path == "/organizations/:id") && c.Request.Method != http.MethodGet:
+		return middleware.RateClassAdmin
+	case path == "/networks/import" || path == "/networks/:id/export" || path == "/apply":
+		return middleware.RateClassAdmin
+	}
+
+	return middleware.RateClassDefault
+}
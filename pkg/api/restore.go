@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// RestoreNetwork godoc
+// @Summary Restore a deleted network
+// @Description Restore a deleted network together with the hosts that were deleted with it. Deleted networks are listed with ?deleted=true.
+// @Tags networks
+// @Produce json
+// @Param id path string true "Network ID"
+// @Success 200 {object} models.Network
+// @Failure 404 {object} api.errorResponse "The network does not exist or is not deleted"
+// @Failure 409 {object} api.errorResponse "A network or host with the same name or IP was created meanwhile"
+// @Security BearerAuth
+// @Router /networks/{id}/restore [post]
+func RestoreNetwork(c *gin.Context) {
+	n, err := service.RestoreNetwork(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	setETag(c, n.ETag())
+	c.JSON(http.StatusOK, n)
+}
+
+// RestoreHost godoc
+// @Summary Restore a deleted host
+// @Description Restore a deleted host with its certificate and configuration. Deleted hosts are listed with ?deleted=true.
+// @Description Hosts deleted with their network are restored by restoring the network.
+// @Tags hosts
+// @Produce json
+// @Param id path string true "Host ID"
+// @Success 200 {object} models.Host
+// @Failure 404 {object} api.errorResponse "The host does not exist or is not deleted"
+// @Failure 409 {object} api.errorResponse "The network is deleted, or a host with the same name or IP was created meanwhile"
+// @Security BearerAuth
+// @Router /hosts/{id}/restore [post]
+func RestoreHost(c *gin.Context) {
+	host, err := service.RestoreHost(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	setETag(c, host.ETag())
+	c.JSON(http.StatusOK, host)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// FindNetworkRoles godoc
+// @Summary Get the role bindings of a network
+// @Description List which API tokens hold which role on a network
+// @Tags roles
+// @Produce json
+// @Param id path string true "Network ID"
+// @Success 200 {array} models.RoleBinding
+// @Failure 403 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/roles [get]
+func FindNetworkRoles(c *gin.Context) {
+	bindings, err := service.ListRoleBindings(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, bindings)
+}
+
+// CreateNetworkRole godoc
+// @Summary Grant a role on a network
+// @Description Grant an API token a role (owner, operator, viewer or enroller) on a network
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param id path string true "Network ID"
+// @Param role body models.RoleBindingDto true "Role Payload"
+// @Success 201 {object} models.RoleBinding
+// @Failure 400 {object} api.errorResponse
+// @Failure 403 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/roles [post]
+func CreateNetworkRole(c *gin.Context) {
+	var dto models.RoleBindingDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	binding, err := service.GrantRole(callerContext(c), c.Param("id"), dto)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, binding)
+}
+
+// DeleteNetworkRole godoc
+// @Summary Revoke a role on a network
+// @Description Delete a role binding of a network by ID
+// @Tags roles
+// @Param id path string true "Network ID"
+// @Param roleId path string true "Role binding ID"
+// @Success 200 {object} map[string]bool "Delete status"
+// @Failure 403 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/roles/{roleId} [delete]
+func DeleteNetworkRole(c *gin.Context) {
+	if err := service.RevokeRole(callerContext(c), c.Param("id"), c.Param("roleId")); err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delete": true})
+}
@@ -1,32 +1,59 @@
 package api
 
 import (
-	"time"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
 	"github.com/koodeyo/koodnet/docs"
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
 	"github.com/koodeyo/koodnet/pkg/middleware"
+	"github.com/koodeyo/koodnet/pkg/models"
 	"github.com/sirupsen/logrus"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"golang.org/x/time/rate"
 )
 
-func NewRouter(l *logrus.Logger) *gin.Engine {
+// NewRouter returns the REST API configured by cfg. It fails when the rate limit policies are invalid.
+func NewRouter(l *logrus.Logger, cfg *config.Config) (*gin.Engine, error) {
+	limiter, err := NewRateLimiter(cfg.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+
 	r := gin.Default()
+
+	// Client IPs come from X-Forwarded-For only behind the trusted proxies, for rate limits and the audit log
+	if err := r.SetTrustedProxies(cfg.Security.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("security.trusted_proxies: %w", err)
+	}
+
 	// Use centralized error handling middleware
 	r.Use(errorHandler)
 
+	// Tag every request with an ID for logs and the audit log
+	r.Use(middleware.RequestID())
+
 	// Apply the logging middleware
 	r.Use(middleware.Logger(l))
 
-	if gin.Mode() == gin.ReleaseMode {
-		r.Use(middleware.Security())
+	if cfg.SecurityHeaders() {
+		r.Use(middleware.Security(middleware.SecurityOptions{
+			AllowedHosts:          cfg.Security.AllowedHosts,
+			SSLRedirect:           cfg.Security.SSLRedirect,
+			STSSeconds:            cfg.Security.STSSeconds,
+			ContentSecurityPolicy: cfg.Security.ContentSecurityPolicy,
+		}))
 		r.Use(middleware.Xss())
 	}
 
-	r.Use(middleware.Cors())
-	r.Use(middleware.RateLimiter(rate.Every(1*time.Minute), 60)) // 60 requests per minute
+	// Without allowed origins, browsers only call the API from its own origin
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		r.Use(middleware.Cors(cfg.CORS.AllowedOrigins, cfg.CORS.AllowCredentials))
+	}
+
+	// Limit requests per client IP, then per API token and route class once authenticated
+	r.Use(limiter.Limit(middleware.RateClass(middleware.RateClassIP)))
 
 	docs.SwaggerInfo.BasePath = "/api/v1"
 
@@ -35,29 +62,93 @@ func NewRouter(l *logrus.Logger) *gin.Engine {
 		// Health route
 		v1.GET("/", healthCheckHandler)
 
+		// Every other route requires an API token
+		auth := v1.Group("/", middleware.Auth(func(token string) (*models.APIToken, error) {
+			return models.VerifyAPIToken(database.Conn, token)
+		}), limiter.Limit(rateClass))
+
+		// Create routes replay their first response to retries with the same Idempotency-Key
+		idempotentCreate := idempotent(cfg.Idempotency.TTL)
+
 		// Network routes
-		networks := v1.Group("/networks")
+		networks := auth.Group("/networks")
 		{
-			networks.GET("/", FindNetworks)
-			networks.POST("/", CreateNetwork)
-			networks.GET("/:id", FindNetwork)
-			networks.DELETE("/:id", DeleteNetwork)
-			networks.PATCH("/:id", UpdateNetwork)
+			networks.GET("/", middleware.RequireScope(models.ScopeNetworksRead), FindNetworks)
+			networks.POST("/", middleware.RequireScope(models.ScopeNetworksWrite), idempotentCreate, CreateNetwork)
+			networks.POST("/import", middleware.RequireScope(models.ScopeNetworksWrite, models.ScopeHostsWrite), idempotentCreate, ImportNetwork)
+			networks.GET("/:id", middleware.RequireScope(models.ScopeNetworksRead), FindNetwork)
+			networks.DELETE("/:id", middleware.RequireScope(models.ScopeNetworksWrite), DeleteNetwork)
+			networks.PATCH("/:id", middleware.RequireScope(models.ScopeNetworksWrite), UpdateNetwork)
+			networks.POST("/:id/restore", middleware.RequireScope(models.ScopeNetworksWrite), RestoreNetwork)
+			networks.GET("/:id/export", middleware.RequireScope(models.ScopeNetworksRead, models.ScopeHostsRead, models.ScopeKeysRead), ExportNetwork)
+			networks.POST("/:id/ca/rotate", middleware.RequireScope(models.ScopeNetworksWrite), RotateNetworkCA)
+			networks.PUT("/:id/sshd", middleware.RequireScope(models.ScopeHostsWrite), UpdateNetworkSSHD)
+			networks.GET("/:id/hosts", middleware.RequireScope(models.ScopeHostsRead), FindNetworkHosts)
+			networks.POST("/:id/hosts", middleware.RequireScope(models.ScopeHostsWrite), idempotentCreate, CreateNetworkHost)
+			networks.POST("/:id/:action", middleware.RequireScope(models.ScopeHostsWrite), idempotentCreate, networkAction) // hosts:batch
+			networks.GET("/:id/hosts/:hostName", middleware.RequireScope(models.ScopeHostsRead), FindNetworkHost)
+			networks.GET("/:id/prometheus-sd.json", middleware.RequireScope(models.ScopeNetworksRead, models.ScopeHostsRead), FindNetworkPrometheusTargets)
+			networks.GET("/:id/roles", middleware.RequireScope(models.ScopeNetworksRead), FindNetworkRoles)
+			networks.POST("/:id/roles", middleware.RequireScope(models.ScopeNetworksWrite), CreateNetworkRole)
+			networks.DELETE("/:id/roles/:roleId", middleware.RequireScope(models.ScopeNetworksWrite), DeleteNetworkRole)
 		}
 
 		// Host routes
-		hosts := v1.Group("/hosts")
+		hosts := auth.Group("/hosts")
 		{
-			hosts.GET("/", FindHosts)
-			hosts.POST("/", CreateHost)
-			hosts.GET("/:id", FindHost)
-			hosts.PUT("/:id", UpdateHost)
-			hosts.DELETE("/:id", DeleteHost)
-			hosts.GET("/:id/config.yml", FindHostYamlConfig)
+			hosts.GET("/", middleware.RequireScope(models.ScopeHostsRead), FindHosts)
+			hosts.POST("/", middleware.RequireScope(models.ScopeHostsWrite), idempotentCreate, CreateHost)
+			hosts.GET("/:id", middleware.RequireScope(models.ScopeHostsRead), FindHost)
+			hosts.PUT("/:id", middleware.RequireScope(models.ScopeHostsWrite), UpdateHost)
+			hosts.DELETE("/:id", middleware.RequireScope(models.ScopeHostsWrite), DeleteHost)
+			hosts.POST("/:id/restore", middleware.RequireScope(models.ScopeHostsWrite), RestoreHost)
+			hosts.GET("/:id/config.yml", middleware.RequireScope(models.ScopeHostsRead, models.ScopeKeysRead), FindHostYamlConfig)
+			hosts.GET("/:id/config/watch", middleware.RequireScope(models.ScopeHostsRead), WatchHostConfig)
 		}
 
+		// Declarative network specs
+		auth.POST("/apply", middleware.RequireScope(models.ScopeNetworksWrite, models.ScopeHostsWrite), Apply)
+
 		// Certificate routes
-		v1.GET("/certificates", FindCertificates)
+		auth.GET("/certificates", middleware.RequireScope(models.ScopeCertificatesRead), FindCertificates)
+
+		// Organization routes
+		organizations := auth.Group("/organizations")
+		{
+			organizations.GET("/", middleware.RequireScope(models.ScopeNetworksRead), FindOrganizations)
+			organizations.POST("/", middleware.RequireScope(models.ScopeAdmin), idempotentCreate, CreateOrganization)
+			organizations.GET("/:id", middleware.RequireScope(models.ScopeNetworksRead), FindOrganization)
+			organizations.PATCH("/:id", middleware.RequireScope(models.ScopeAdmin), UpdateOrganization)
+			organizations.DELETE("/:id", middleware.RequireScope(models.ScopeAdmin), DeleteOrganization)
+			organizations.GET("/:id/networks", middleware.RequireScope(models.ScopeNetworksRead), FindOrganizationNetworks)
+			organizations.POST("/:id/networks", middleware.RequireScope(models.ScopeNetworksWrite), idempotentCreate, CreateOrganizationNetwork)
+		}
+
+		// Audit log routes
+		auditLog := auth.Group("/audit", middleware.RequireScope(models.ScopeAdmin))
+		{
+			auditLog.GET("/", FindAuditEvents)
+			auditLog.GET("/verify", VerifyAuditEvents)
+		}
+
+		// Webhook routes
+		webhooks := auth.Group("/webhooks", middleware.RequireScope(models.ScopeAdmin))
+		{
+			webhooks.GET("/", FindWebhooks)
+			webhooks.POST("/", idempotentCreate, CreateWebhook)
+			webhooks.GET("/:id", FindWebhook)
+			webhooks.PATCH("/:id", UpdateWebhook)
+			webhooks.DELETE("/:id", DeleteWebhook)
+			webhooks.GET("/:id/deliveries", FindWebhookDeliveries)
+		}
+
+		// API token routes
+		tokens := auth.Group("/tokens", middleware.RequireScope(models.ScopeAdmin))
+		{
+			tokens.GET("/", FindAPITokens)
+			tokens.POST("/", CreateAPIToken)
+			tokens.DELETE("/:id", DeleteAPIToken)
+		}
 	}
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
@@ -65,5 +156,5 @@ func NewRouter(l *logrus.Logger) *gin.Engine {
 	// Handle 404 (unmatched routes)
 	r.NoRoute(notFoundHandler)
 
-	return r
+	return r, nil
 }
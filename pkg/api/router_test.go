@@ -0,0 +1,54 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRouterTrustsForwardedForOnlyFromTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	tests := []struct {
+		proxies []string
+		want    int // Status of the second request, from the same proxy for another client.
+	}{
+		{nil, http.StatusTooManyRequests},
+		{[]string{"192.0.2.1"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.RateLimits.Policies = map[string]string{"ip": "1/1h"}
+		cfg.Security.TrustedProxies = tt.proxies
+
+		r, err := NewRouter(l, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var status int
+		for _, client := range []string{"198.51.100.1", "198.51.100.2"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", client)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			status = w.Code
+		}
+
+		if status != tt.want {
+			t.Errorf("trusted proxies %v: second client got %d, want %d", tt.proxies, status, tt.want)
+		}
+	}
+}
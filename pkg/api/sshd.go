@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// UpdateNetworkSSHD godoc
+// @Summary Turn the nebula sshd on or off
+// @Description Enable or disable the nebula sshd on every host of a network, optionally limited to hosts in the given groups.
+// @Tags networks
+// @Accept json
+// @Produce json
+// @Param id path string true "Network ID"
+// @Param sshd body models.SSHDToggleDto true "SSHD toggle"
+// @Success 200 {array} models.Host
+// @Failure 400 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /networks/{id}/sshd [put]
+func UpdateNetworkSSHD(c *gin.Context) {
+	var dto models.SSHDToggleDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_INPUT",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	updated, err := service.ToggleNetworkSSHD(callerContext(c), c.Param("id"), dto)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
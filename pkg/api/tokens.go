@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// apiTokenResponse is returned once, when a token is created. It is the only time the token is shown.
+type apiTokenResponse struct {
+	models.APIToken
+	Token string `json:"token" example:"knt_3q2-7wX..."`
+}
+
+// FindAPITokens godoc
+// @Summary Get all API tokens
+// @Description Get a list of all API tokens with optional pagination
+// @Tags tokens
+// @Produce json
+// @Param page query int false "page for pagination" default(1)
+// @Param pageSize query int false "pageSize for pagination" default(10)
+// @Success 200 {object} api.paginatedResponse[models.APIToken]
+// @Security BearerAuth
+// @Router /tokens [get]
+func FindAPITokens(c *gin.Context) {
+	tokens, err := service.ListAPITokens(callerContext(c), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
+
+	response := paginated(tokens, c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateAPIToken godoc
+// @Summary Create a new API token
+// @Description Create an API token with the given scopes. The token is only returned in this response.
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Param token body models.APITokenDto true "Token Payload"
+// @Success 201 {object} api.apiTokenResponse
+// @Failure 400 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /tokens [post]
+func CreateAPIToken(c *gin.Context) {
+	var dto models.APITokenDto
+
+	// Validate the payload
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	t, raw, err := service.CreateAPIToken(callerContext(c), dto)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, apiTokenResponse{APIToken: *t, Token: raw})
+}
+
+// DeleteAPIToken godoc
+// @Summary Revoke an API token
+// @Description Revoke an API token by ID. Revoked tokens are kept for reference but can no longer authenticate.
+// @Tags tokens
+// @Param id path string true "Token ID"
+// @Success 200 {object} map[string]bool "Revoke status"
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /tokens/{id} [delete]
+func DeleteAPIToken(c *gin.Context) {
+	if err := service.RevokeAPIToken(callerContext(c), c.Param("id")); err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoke": true})
+}
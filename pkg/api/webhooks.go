@@ -0,0 +1,178 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+// webhookResponse is returned once, when a webhook is created. It is the only time the secret is shown.
+type webhookResponse struct {
+	models.Webhook
+	Secret string `json:"secret" example:"whsec_9Jf2..."` // Key of the HMAC-SHA256 signature in the X-Koodnet-Signature header.
+}
+
+// FindWebhooks godoc
+// @Summary Get all webhooks
+// @Description Get a list of the webhooks visible to the caller with optional pagination
+// @Tags webhooks
+// @Produce json
+// @Param page query int false "page for pagination" default(1)
+// @Param pageSize query int false "pageSize for pagination" default(10)
+// @Success 200 {object} api.paginatedResponse[models.Webhook]
+// @Security BearerAuth
+// @Router /webhooks [get]
+func FindWebhooks(c *gin.Context) {
+	webhooks, err := service.ListWebhooks(callerContext(c), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
+
+	response := paginated(webhooks, c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateWebhook godoc
+// @Summary Create a new webhook
+// @Description Register an endpoint that receives events as signed JSON POST requests. The signing secret is only returned in this response.
+// @Description Requests carry the headers X-Koodnet-Event, X-Koodnet-Delivery and X-Koodnet-Signature, which is "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+// @Description Deliveries that fail or get a non-2xx response are retried with exponential backoff for about 20 hours.
+// @Description Webhooks created with an organization-confined token only get the events of that organization's networks.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.WebhookDto true "Webhook Payload"
+// @Success 201 {object} api.webhookResponse
+// @Failure 400 {object} api.errorResponse
+// @Failure 403 {object} api.errorResponse
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
+// @Security BearerAuth
+// @Router /webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	var dto models.WebhookDto
+
+	// Validate the payload
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_DATA",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	w, err := service.CreateWebhook(callerContext(c), dto)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhookResponse{Webhook: *w, Secret: w.Secret})
+}
+
+// FindWebhook godoc
+// @Summary Get a webhook by ID
+// @Description Retrieve details of a single webhook
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Produce json
+// @Success 200 {object} models.Webhook
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /webhooks/{id} [get]
+func FindWebhook(c *gin.Context) {
+	w, err := service.GetWebhook(callerContext(c), c.Param("id"))
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook
+// @Description Update the URL, events or network of a webhook, or turn it on or off
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param webhook body models.WebhookDto true "Updated webhook details"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} api.errorResponse
+// @Failure 403 {object} api.errorResponse
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /webhooks/{id} [patch]
+func UpdateWebhook(c *gin.Context) {
+	var dto models.WebhookDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{
+			Errors: []apiError{
+				{
+					Code:    "INVALID_INPUT",
+					Message: err.Error(),
+				},
+			},
+		})
+		return
+	}
+
+	w, err := service.UpdateWebhook(callerContext(c), c.Param("id"), dto)
+	if err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Delete a webhook and its pending deliveries by ID
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} map[string]bool "Delete status"
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	if err := service.DeleteWebhook(callerContext(c), c.Param("id")); err != nil {
+		dbErrorHandler(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delete": true})
+}
+
+// FindWebhookDeliveries godoc
+// @Summary Get the deliveries of a webhook
+// @Description Get the events sent or queued for a webhook, newest first, with the outcome of the last attempt
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param status query string false "Filter by status" Enums(pending, delivered, failed)
+// @Param page query int false "page for pagination" default(1)
+// @Param pageSize query int false "pageSize for pagination" default(10)
+// @Success 200 {object} api.paginatedResponse[models.WebhookDelivery]
+// @Failure 404 {object} api.errorResponse
+// @Security BearerAuth
+// @Router /webhooks/{id}/deliveries [get]
+func FindWebhookDeliveries(c *gin.Context) {
+	deliveries, err := service.ListWebhookDeliveries(callerContext(c), c.Param("id"), c.Query("status"), c)
+	if err != nil {
+		listErrorHandler(err, c)
+		return
+	}
+
+	response := paginated(deliveries, c)
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// ApplyResult lists the changes of applying a network spec.
+type ApplyResult struct {
+	NetworkID *uuid.UUID          `json:"networkId,omitempty"` // Not set in plans of networks that are yet to be created.
+	Applied   bool                `json:"applied"`
+	Actions   []models.PlanAction `json:"actions"`
+	Diffs     []ConfigDiff        `json:"diffs,omitempty"` // Config changes of every affected host, in plans only.
+}
+
+// ConfigDiff is the unified diff of the config of a host.
+type ConfigDiff struct {
+	HostID   uuid.UUID `json:"hostId"`
+	HostName string    `json:"hostName"`
+	Diff     string    `json:"diff"`
+}
+
+// Apply brings a network and its hosts to the state of the spec, in one transaction.
+// Hosts of the network that are not in the spec are deleted.
+func (c *Client) Apply(ctx context.Context, spec models.NetworkSpec) (*ApplyResult, error) {
+	return c.apply(ctx, spec, "application/json", false)
+}
+
+// PlanApply returns what Apply would change, with the config diff of every affected host, without applying it.
+func (c *Client) PlanApply(ctx context.Context, spec models.NetworkSpec) (*ApplyResult, error) {
+	return c.apply(ctx, spec, "application/json", true)
+}
+
+// ApplyYAML is Apply with a YAML spec, e.g. read from a file kept in git. With plan, nothing is applied.
+func (c *Client) ApplyYAML(ctx context.Context, spec []byte, plan bool) (*ApplyResult, error) {
+	return c.apply(ctx, spec, "application/x-yaml", plan)
+}
+
+func (c *Client) apply(ctx context.Context, spec any, contentType string, plan bool) (*ApplyResult, error) {
+	q := url.Values{}
+	if plan {
+		q.Set("dryRun", "true")
+	}
+
+	var result ApplyResult
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/apply", query: q, body: spec, contentType: contentType}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
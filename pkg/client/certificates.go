@@ -0,0 +1,18 @@
+package client
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// ListCertificates returns a page of certificates. Filters: ownerId, ownerType, isCa, expired and expiresBefore.
+// Private keys are only included with the keys:read scope.
+func (c *Client) ListCertificates(ctx context.Context, opts ListOptions) (*Page[models.Certificate], error) {
+	return list[models.Certificate](ctx, c, "/certificates", opts)
+}
+
+// Certificates iterates over the certificates.
+func (c *Client) Certificates(opts ListOptions) *Iterator[models.Certificate] {
+	return newIterator[models.Certificate](c, "/certificates", opts)
+}
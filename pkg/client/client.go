@@ -0,0 +1,232 @@
+// Package client is a Go client for the koodnet API.
+//
+//	c, err := client.New("https://koodnet.example.com/api/v1", token)
+//	hosts := c.Hosts(client.ListOptions{Filters: url.Values{"site": {"eu-west"}}})
+//	for hosts.Next(ctx) {
+//		fmt.Println(hosts.Value().Name)
+//	}
+//	if err := hosts.Err(); err != nil {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMaxRetries = 3
+	minRetryDelay     = 500 * time.Millisecond
+	maxRetryDelay     = 30 * time.Second
+)
+
+// Client calls the koodnet API with an API token. Its fields may be changed before first use.
+type Client struct {
+	BaseURL    *url.URL     // URL of the API including /api/v1.
+	Token      string       // API token, sent as a bearer token.
+	HTTPClient *http.Client // Defaults to http.DefaultClient.
+	UserAgent  string
+
+	// MaxRetries is how often a request is retried after network errors, 429s and 502, 503 and 504 responses.
+	// Creates are retried with the same Idempotency-Key, so that they are not repeated by the server.
+	MaxRetries int
+}
+
+// New returns a client of the API at baseURL, e.g. "https://koodnet.example.com/api/v1".
+func New(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("client: base URL must be an http or https URL")
+	}
+
+	return &Client{
+		BaseURL:    u,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+		UserAgent:  "koodnet-go-client",
+		MaxRetries: defaultMaxRetries,
+	}, nil
+}
+
+// request is an API call. Body is encoded as JSON unless it is an io.Reader or []byte.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any
+	contentType string
+	header      http.Header
+}
+
+// do sends the request, retrying when worthwhile, and decodes a JSON response into out unless it is nil.
+// Responses other than 2xx and 304 are returned as *Error.
+func (c *Client) do(ctx context.Context, req request, out any) (*http.Response, error) {
+	var body []byte
+	switch b := req.body.(type) {
+	case nil:
+	case []byte:
+		body = b
+	case io.Reader:
+		var err error
+		if body, err = io.ReadAll(b); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			return nil, err
+		}
+		if req.contentType == "" {
+			req.contentType = "application/json"
+		}
+	}
+
+	// Retried creates must not create twice
+	if req.method == http.MethodPost && req.header.Get("Idempotency-Key") == "" {
+		if req.header == nil {
+			req.header = http.Header{}
+		}
+		req.header.Set("Idempotency-Key", uuid.NewString())
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, req, body)
+
+		retry, delay := c.shouldRetry(attempt, res, err)
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+
+			return res, decodeResponse(res, out)
+		}
+
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	u := *c.BaseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + req.path
+	u.RawQuery = req.query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range req.header {
+		r.Header[key] = values
+	}
+
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+	if r.Header.Get("Accept") == "" {
+		r.Header.Set("Accept", "application/json")
+	}
+	if c.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.UserAgent != "" {
+		r.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return httpClient.Do(r)
+}
+
+// shouldRetry reports whether to retry after the attempt, and how long to wait first.
+// The Retry-After header of the response is honoured when it is not longer than maxRetryDelay.
+func (c *Client) shouldRetry(attempt int, res *http.Response, err error) (bool, time.Duration) {
+	if attempt >= c.MaxRetries {
+		return false, 0
+	}
+
+	if err != nil {
+		// Cancelled contexts are not worth retrying
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false, 0
+		}
+
+		return true, backoff(attempt)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	default:
+		return false, 0
+	}
+
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		delay := time.Duration(seconds) * time.Second
+		if delay > maxRetryDelay {
+			return false, 0
+		}
+
+		return true, delay
+	}
+
+	return true, backoff(attempt)
+}
+
+// backoff doubles the delay with every attempt, up to maxRetryDelay.
+func backoff(attempt int) time.Duration {
+	delay := time.Duration(float64(minRetryDelay) * math.Pow(2, float64(attempt)))
+	return min(delay, maxRetryDelay)
+}
+
+func decodeResponse(res *http.Response, out any) error {
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || (res.StatusCode >= 300 && res.StatusCode != http.StatusNotModified) {
+		return decodeError(res)
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+
+	if b, ok := out.(*[]byte); ok {
+		var err error
+		*b, err = io.ReadAll(res.Body)
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,267 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/api"
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/logger"
+)
+
+// testServer serves the REST API on a fresh SQLite database, and records the requests made to it.
+// Requests can be failed before they reach the API with fail.
+type testServer struct {
+	*httptest.Server
+	token string
+
+	mu       sync.Mutex
+	requests []*http.Request
+	failures []int // Statuses to respond with, in order, before passing requests on to the API.
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	cfg := config.Default()
+	cfg.Database.SQLitePath = filepath.Join(t.TempDir(), "koodnet.db")
+	database.Connect(cfg.Database)
+	database.Conn.Logger = logger.Discard
+	database.Migrate()
+
+	token, raw, err := models.NewAPIToken("test", []string{models.ScopeAdmin}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Conn.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	router, err := api.NewRouter(l, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{token: raw}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Clone(context.Background()))
+		var status int
+		if len(s.failures) > 0 {
+			status, s.failures = s.failures[0], s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if status != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testServer) fail(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+func (s *testServer) requestLog() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+func (s *testServer) client(t *testing.T) *Client {
+	t.Helper()
+
+	c, err := New(s.URL+"/api/v1", s.token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func createTestNetwork(t *testing.T, c *Client, name string) *models.Network {
+	t.Helper()
+
+	n, err := c.CreateNetwork(context.Background(), models.NetworkDto{
+		Name:     name,
+		IPs:      []string{"100.100.0.0/16"},
+		Duration: 24 * time.Hour,
+		Curve:    "25519",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestIterators(t *testing.T) {
+	s := newTestServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	n := createTestNetwork(t, c, "iterators")
+	for i := 1; i <= 5; i++ {
+		dto := models.HostDto{Name: fmt.Sprintf("host-%d", i), IP: fmt.Sprintf("100.100.0.%d/16", i)}
+		if _, err := c.CreateNetworkHost(ctx, n.ID, dto); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+	}{
+		{"pages", ListOptions{PageSize: 2}},
+		{"cursor", ListOptions{Limit: 2}},
+		{"sorted", ListOptions{PageSize: 2, Sort: "-name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := c.NetworkHosts(n.ID, tt.opts).All(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			seen := map[string]bool{}
+			for _, h := range hosts {
+				if seen[h.Name] {
+					t.Errorf("host %s seen twice", h.Name)
+				}
+				seen[h.Name] = true
+			}
+
+			if len(seen) != 5 {
+				t.Errorf("got %d hosts, want 5", len(seen))
+			}
+		})
+	}
+
+	hosts, err := c.NetworkHosts(n.ID, ListOptions{PageSize: 2, Sort: "-name"}).All(ctx)
+	if err != nil || len(hosts) == 0 || hosts[0].Name != "host-5" {
+		t.Errorf("got %v, %v sorted by -name, want host-5 first", hosts, err)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	s := newTestServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	_, err := c.GetNetwork(ctx, uuid.New())
+	if !IsNotFound(err) {
+		t.Fatalf("got %v, want a 404", err)
+	}
+	if apiErr := err.(*Error); !apiErr.HasCode("ERR_NOT_FOUND") {
+		t.Errorf("got codes %v, want ERR_NOT_FOUND", apiErr.Errors)
+	}
+
+	createTestNetwork(t, c, "taken")
+	_, err = c.CreateNetwork(ctx, models.NetworkDto{Name: "taken", IPs: []string{"100.101.0.0/16"}, Duration: time.Hour, Curve: "25519"})
+	if !IsConflict(err) {
+		t.Fatalf("got %v, want a 409", err)
+	}
+
+	n := createTestNetwork(t, c, "validation")
+	_, err = c.CreateNetworkHost(ctx, n.ID, models.HostDto{
+		Name:            "invalid",
+		IP:              "100.100.0.1/16",
+		StaticAddresses: []models.StaticAddress{{Host: "example.com", Port: 70000}},
+	})
+	if !IsStatus(err, http.StatusUnprocessableEntity) {
+		t.Fatalf("got %v, want a 422", err)
+	}
+	if field := err.(*Error).Errors[0].Field; field != "staticAddresses[0].port" {
+		t.Errorf("got field %q, want staticAddresses[0].port", field)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	s := newTestServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	// Creates are retried with the same Idempotency-Key
+	s.fail(http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	n := createTestNetwork(t, c, "retried")
+
+	requests := s.requestLog()
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+
+	key := requests[0].Header.Get("Idempotency-Key")
+	for _, r := range requests {
+		if got := r.Header.Get("Idempotency-Key"); key == "" || got != key {
+			t.Errorf("got Idempotency-Key %q, want %q on every attempt", got, key)
+		}
+	}
+
+	// Retrying gives up after MaxRetries
+	c.MaxRetries = 1
+	before := len(s.requestLog())
+	s.fail(http.StatusBadGateway, http.StatusBadGateway)
+	if _, err := c.GetNetwork(ctx, n.ID); !IsStatus(err, http.StatusBadGateway) {
+		t.Errorf("got %v, want a 502 after retrying once", err)
+	}
+	if got := len(s.requestLog()) - before; got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+
+	// Other errors are not retried
+	before = len(s.requestLog())
+	s.fail(http.StatusInternalServerError)
+	if _, err := c.GetNetwork(ctx, n.ID); !IsStatus(err, http.StatusInternalServerError) {
+		t.Errorf("got %v, want a 500", err)
+	}
+	if got := len(s.requestLog()) - before; got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	s := newTestServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	if _, err := c.ListNetworks(ctx, ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := s.requestLog()[0].Header.Get("Authorization"), "Bearer "+s.token; got != want {
+		t.Errorf("got Authorization %q, want %q", got, want)
+	}
+
+	c.Token = "knt_wrong"
+	if _, err := c.ListNetworks(ctx, ListOptions{}); !IsStatus(err, http.StatusUnauthorized) {
+		t.Errorf("got %v, want a 401", err)
+	}
+}
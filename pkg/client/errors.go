@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIError is one of the errors of a response, as sent by the API.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"` // JSON path of the invalid value, for validation errors.
+}
+
+// Error is returned for responses other than 2xx and 304.
+type Error struct {
+	StatusCode int
+	Errors     []APIError `json:"errors"`
+
+	body []byte // For responses that carry more than errors, such as failed batches.
+}
+
+func (e *Error) Error() string {
+	if len(e.Errors) == 0 {
+		return "koodnet: " + http.StatusText(e.StatusCode)
+	}
+
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		message := err.Message
+		if err.Field != "" {
+			message = err.Field + ": " + message
+		}
+		messages = append(messages, message)
+	}
+
+	return "koodnet: " + strings.Join(messages, "; ")
+}
+
+// HasCode reports whether any of the errors has the code, e.g. "ERR_RATE_LIMITED".
+func (e *Error) HasCode(code string) bool {
+	for _, err := range e.Errors {
+		if err.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsStatus reports whether err is an *Error of a response with the status code.
+func IsStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is a 409 response, such as a duplicate name, or a 412 response
+// to an update of a resource that changed since it was read.
+func IsConflict(err error) bool {
+	return IsStatus(err, http.StatusConflict) || IsStatus(err, http.StatusPreconditionFailed)
+}
+
+// decodeError decodes the error response of the API. Other bodies, e.g. of proxies, are ignored.
+func decodeError(res *http.Response) error {
+	apiErr := &Error{StatusCode: res.StatusCode}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return apiErr
+	}
+
+	json.Unmarshal(body, apiErr)
+	apiErr.StatusCode = res.StatusCode
+	apiErr.body = body
+
+	return apiErr
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// BatchHostResult is the outcome of one host of a batch.
+type BatchHostResult struct {
+	Row    int          `json:"row"` // 1-based position of the host in the batch.
+	Name   string       `json:"name"`
+	Host   *models.Host `json:"host,omitempty"`
+	Errors []APIError   `json:"errors,omitempty"`
+}
+
+// BatchHostsResult is the outcome of a batch. When any host failed, the batch was rolled back
+// and the response is returned with a 422 *Error alongside.
+type BatchHostsResult struct {
+	Created int               `json:"created"`
+	Results []BatchHostResult `json:"results"`
+}
+
+// ListHosts returns a page of hosts. Filters: networkId, name, name~, site, group, ip, lighthouse, relay and deleted.
+func (c *Client) ListHosts(ctx context.Context, opts ListOptions) (*Page[models.Host], error) {
+	return list[models.Host](ctx, c, "/hosts/", opts)
+}
+
+// Hosts iterates over the hosts, by cursor unless sorted.
+func (c *Client) Hosts(opts ListOptions) *Iterator[models.Host] {
+	return newCursorIterator[models.Host](c, "/hosts/", opts)
+}
+
+// ListNetworkHosts returns a page of the hosts of a network, with the filters of ListHosts.
+func (c *Client) ListNetworkHosts(ctx context.Context, networkID uuid.UUID, opts ListOptions) (*Page[models.Host], error) {
+	return list[models.Host](ctx, c, "/networks/"+networkID.String()+"/hosts", opts)
+}
+
+// NetworkHosts iterates over the hosts of a network, by cursor unless sorted.
+func (c *Client) NetworkHosts(networkID uuid.UUID, opts ListOptions) *Iterator[models.Host] {
+	return newCursorIterator[models.Host](c, "/networks/"+networkID.String()+"/hosts", opts)
+}
+
+func (c *Client) GetHost(ctx context.Context, id uuid.UUID) (*models.Host, error) {
+	var host models.Host
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/hosts/" + id.String()}, &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// GetNetworkHost returns the host of a network by name.
+func (c *Client) GetNetworkHost(ctx context.Context, networkID uuid.UUID, name string) (*models.Host, error) {
+	var host models.Host
+	path := "/networks/" + networkID.String() + "/hosts/" + url.PathEscape(name)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// CreateHost creates a host in dto.NetworkID and signs its certificate.
+func (c *Client) CreateHost(ctx context.Context, dto models.HostDto) (*models.Host, error) {
+	var host models.Host
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/hosts/", body: dto}, &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// CreateNetworkHost creates a host in a network.
+func (c *Client) CreateNetworkHost(ctx context.Context, networkID uuid.UUID, dto models.HostDto) (*models.Host, error) {
+	var host models.Host
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/networks/" + networkID.String() + "/hosts", body: dto}, &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// BatchCreateHosts creates the hosts in a network, all or none of them.
+func (c *Client) BatchCreateHosts(ctx context.Context, networkID uuid.UUID, dtos []models.HostDto) (*BatchHostsResult, error) {
+	var result BatchHostsResult
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/networks/" + networkID.String() + "/hosts:batch", body: dtos}, &result)
+	if err == nil {
+		return &result, nil
+	}
+
+	// The results tell which hosts failed, so they are decoded from the error response too
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || json.Unmarshal(apiErr.body, &result) != nil {
+		return nil, err
+	}
+
+	for _, row := range result.Results {
+		for _, rowErr := range row.Errors {
+			rowErr.Message = fmt.Sprintf("row %d (%s): %s", row.Row, row.Name, rowErr.Message)
+			apiErr.Errors = append(apiErr.Errors, rowErr)
+		}
+	}
+
+	return &result, err
+}
+
+// UpdateHost updates a host. When ifMatch is the ETag of the host as last read, e.g. host.ETag() of a host
+// from GetHost, the update fails with a 412 *Error if it changed since. Listed hosts lack their configuration,
+// so their ETag() cannot be used.
+func (c *Client) UpdateHost(ctx context.Context, id uuid.UUID, dto models.HostDto, ifMatch string) (*models.Host, error) {
+	var host models.Host
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: "/hosts/" + id.String(), body: dto, header: ifMatchHeader(ifMatch)}, &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// DeleteHost deletes a host, which can be restored until it is purged.
+func (c *Client) DeleteHost(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/hosts/" + id.String()}, nil)
+	return err
+}
+
+// RestoreHost restores a deleted host.
+func (c *Client) RestoreHost(ctx context.Context, id uuid.UUID) (*models.Host, error) {
+	var host models.Host
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/hosts/" + id.String() + "/restore"}, &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// HostConfig downloads the nebula config of a host, and returns it with its revision.
+// It needs the keys:read scope, as the config holds the host's private key.
+func (c *Client) HostConfig(ctx context.Context, id uuid.UUID) ([]byte, string, error) {
+	var yml []byte
+	header := http.Header{"Accept": {"application/yaml"}}
+
+	res, err := c.do(ctx, request{method: http.MethodGet, path: "/hosts/" + id.String() + "/config.yml", header: header}, &yml)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return yml, res.Header.Get("X-Koodnet-Config-Revision"), nil
+}
+
+// WaitForHostConfig waits up to timeout, of at most 5 minutes, for the config of a host to differ
+// from revision, and returns the new revision. It reports false when the timeout passed without a change.
+// An empty revision returns the current one right away.
+func (c *Client) WaitForHostConfig(ctx context.Context, id uuid.UUID, revision string, timeout time.Duration) (string, bool, error) {
+	q := url.Values{}
+	if revision != "" {
+		q.Set("revision", revision)
+	}
+	if timeout > 0 {
+		q.Set("timeout", timeout.String())
+	}
+
+	var event struct {
+		Revision string `json:"revision"`
+	}
+
+	res, err := c.do(ctx, request{method: http.MethodGet, path: "/hosts/" + id.String() + "/config/watch", query: q}, &event)
+	if err != nil {
+		return "", false, err
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		return revision, false, nil
+	}
+
+	return event.Revision, true, nil
+}
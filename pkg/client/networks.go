@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// ListNetworks returns a page of networks. Filters: organizationId, name, name~, group, curve and deleted.
+func (c *Client) ListNetworks(ctx context.Context, opts ListOptions) (*Page[models.Network], error) {
+	return list[models.Network](ctx, c, "/networks/", opts)
+}
+
+// Networks iterates over the networks.
+func (c *Client) Networks(opts ListOptions) *Iterator[models.Network] {
+	return newIterator[models.Network](c, "/networks/", opts)
+}
+
+func (c *Client) GetNetwork(ctx context.Context, id uuid.UUID) (*models.Network, error) {
+	var network models.Network
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/networks/" + id.String()}, &network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+// CreateNetwork creates a network and signs its CA.
+func (c *Client) CreateNetwork(ctx context.Context, dto models.NetworkDto) (*models.Network, error) {
+	var network models.Network
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/networks/", body: dto}, &network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+// UpdateNetwork updates the fields of a network that are set in dto. When ifMatch is the ETag
+// of the network as last read, e.g. network.ETag(), the update fails with a 412 *Error if it changed since.
+func (c *Client) UpdateNetwork(ctx context.Context, id uuid.UUID, dto models.NetworkDto, ifMatch string) (*models.Network, error) {
+	var network models.Network
+	if _, err := c.do(ctx, request{method: http.MethodPatch, path: "/networks/" + id.String(), body: dto, header: ifMatchHeader(ifMatch)}, &network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+// DeleteNetwork deletes a network with its hosts, which can be restored until they are purged.
+// A network that still has hosts is only deleted when confirm is its name.
+func (c *Client) DeleteNetwork(ctx context.Context, id uuid.UUID, confirm string) error {
+	q := url.Values{}
+	if confirm != "" {
+		q.Set("confirm", confirm)
+	}
+
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/networks/" + id.String(), query: q}, nil)
+	return err
+}
+
+// RotateNetworkCA issues a new CA for a network. The previous CAs stay trusted until they expire.
+func (c *Client) RotateNetworkCA(ctx context.Context, id uuid.UUID) (*models.Certificate, error) {
+	var ca models.Certificate
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/networks/" + id.String() + "/ca/rotate"}, &ca); err != nil {
+		return nil, err
+	}
+
+	return &ca, nil
+}
+
+// RestoreNetwork restores a deleted network with the hosts that were deleted with it.
+func (c *Client) RestoreNetwork(ctx context.Context, id uuid.UUID) (*models.Network, error) {
+	var network models.Network
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/networks/" + id.String() + "/restore"}, &network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+// ExportNetwork exports a network with its CAs, hosts, configurations and certificates, including private keys.
+// The export is encrypted when passphrase is not empty. It needs the keys:read scope.
+func (c *Client) ExportNetwork(ctx context.Context, id uuid.UUID, passphrase string) (*models.NetworkExport, error) {
+	var export models.NetworkExport
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/networks/" + id.String() + "/export", header: passphraseHeader(passphrase)}, &export); err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// ImportOptions are the options of ImportNetwork.
+type ImportOptions struct {
+	Passphrase     string     // Passphrase of an encrypted export.
+	RemapIDs       bool       // Give everything new IDs instead of keeping those of the export.
+	OrganizationID *uuid.UUID // Organization to import the network into.
+	Name           string     // Name of the imported network, instead of its exported name.
+}
+
+// ImportNetwork imports a network exported with ExportNetwork, possibly from another koodnet instance.
+// It fails with a 409 *Error when the network or anything in it exists already.
+func (c *Client) ImportNetwork(ctx context.Context, export *models.NetworkExport, opts ImportOptions) (*models.Network, error) {
+	q := url.Values{}
+	if opts.RemapIDs {
+		q.Set("ids", "remap")
+	}
+	if opts.OrganizationID != nil {
+		q.Set("organizationId", opts.OrganizationID.String())
+	}
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+	}
+
+	var network models.Network
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/networks/import", query: q, body: export, header: passphraseHeader(opts.Passphrase)}, &network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+func passphraseHeader(passphrase string) http.Header {
+	if passphrase == "" {
+		return nil
+	}
+
+	return http.Header{"X-Koodnet-Passphrase": {passphrase}}
+}
+
+func ifMatchHeader(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+
+	return http.Header{"If-Match": {etag}}
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Metadata describes the page of a list response.
+type Metadata struct {
+	Page       int    `json:"page"`
+	PageSize   int    `json:"pageSize"`
+	TotalPages int    `json:"totalPages"`
+	Total      int    `json:"total"`      // Total represents the total number of items, 0 when not counted.
+	Limit      int    `json:"limit"`      // Page size of cursor pagination.
+	NextCursor string `json:"nextCursor"` // Cursor of the next page, empty on the last page.
+}
+
+// Page is a page of a list response, in the shape of the API's paginated responses.
+type Page[T any] struct {
+	Data     []T      `json:"data"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// ListOptions selects the items of a list, and the page of them.
+type ListOptions struct {
+	Page     int        // 1-based. Iterators start at this page.
+	PageSize int        // At most 100, the API's default is 10.
+	Sort     string     // Comma-separated sort fields, prefixed with - for descending, e.g. "-createdAt".
+	Cursor   string     // NextCursor of the previous page, for lists paged by cursor.
+	Limit    int        // Pages by cursor instead of page number when set, at most 100. Cannot be combined with Sort.
+	Filters  url.Values // Filters of the list, e.g. {"site": {"eu-west"}, "name~": {"web"}}.
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	for key, values := range o.Filters {
+		q[key] = values
+	}
+
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		q.Set("pageSize", strconv.Itoa(o.PageSize))
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	if o.Cursor != "" || o.Limit > 0 {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+
+	return q
+}
+
+func list[T any](ctx context.Context, c *Client, path string, opts ListOptions) (*Page[T], error) {
+	var page Page[T]
+	if _, err := c.do(ctx, request{method: "GET", path: path, query: opts.query()}, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// Iterator walks the items of a list page by page, fetching the next page when needed.
+// Items created or deleted meanwhile may shift numbered pages, so they can be skipped or seen twice.
+// Iterators of lists paged by cursor are not affected, unless they are sorted.
+type Iterator[T any] struct {
+	fetch func(ctx context.Context, opts ListOptions) (*Page[T], error)
+	opts  ListOptions
+
+	items []T
+	item  T
+	done  bool
+	err   error
+}
+
+// newCursorIterator iterates by cursor over lists that support it, unless sorted by other fields.
+func newCursorIterator[T any](c *Client, path string, opts ListOptions) *Iterator[T] {
+	if opts.Sort != "" {
+		return newIterator[T](c, path, opts)
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = 100
+	}
+
+	return &Iterator[T]{
+		opts: opts,
+		fetch: func(ctx context.Context, opts ListOptions) (*Page[T], error) {
+			return list[T](ctx, c, path, opts)
+		},
+	}
+}
+
+func newIterator[T any](c *Client, path string, opts ListOptions) *Iterator[T] {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = 100
+	}
+
+	return &Iterator[T]{
+		opts: opts,
+		fetch: func(ctx context.Context, opts ListOptions) (*Page[T], error) {
+			return list[T](ctx, c, path, opts)
+		},
+	}
+}
+
+// Next advances to the next item, and reports whether there is one. After it returns false, Err tells why.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for len(it.items) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		page, err := it.fetch(ctx, it.opts)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.items = page.Data
+		if it.opts.Limit > 0 {
+			it.done = page.Metadata.NextCursor == ""
+			it.opts.Cursor = page.Metadata.NextCursor
+			continue
+		}
+
+		it.done = len(page.Data) == 0 || it.opts.Page >= page.Metadata.TotalPages
+		it.opts.Page++
+	}
+
+	it.item, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Value returns the current item.
+func (it *Iterator[T]) Value() T {
+	return it.item
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All collects the remaining items.
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for it.Next(ctx) {
+		items = append(items, it.Value())
+	}
+
+	return items, it.Err()
+}
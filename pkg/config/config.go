@@ -0,0 +1,330 @@
+// Package config is the configuration of koodnet-api. It is loaded from a YAML file, the environment and
+// command line flags, in that order of precedence from lowest to highest, over the defaults.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Environments
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production" // Runs gin in release mode, with security headers and less logging by default.
+)
+
+// Config is the configuration of koodnet-api.
+type Config struct {
+	Env         string            `yaml:"env"` // "development" or "production".
+	Listen      ListenConfig      `yaml:"listen"`
+	Database    DatabaseConfig    `yaml:"database"`
+	CORS        CORSConfig        `yaml:"cors"`
+	RateLimits  RateLimitsConfig  `yaml:"rate_limits"`
+	Security    SecurityConfig    `yaml:"security"`
+	Log         LogConfig         `yaml:"log"`
+	Deleted     DeletedConfig     `yaml:"deleted"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	ConfigWatch ConfigWatchConfig `yaml:"config_watch"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+}
+
+// ListenConfig is where the REST and gRPC APIs listen.
+type ListenConfig struct {
+	Address  string `yaml:"address"`   // Host name or IP address of the interface to listen on. Empty listens on all of them.
+	Port     int    `yaml:"port"`      // Port of the REST API.
+	GRPCPort int    `yaml:"grpc_port"` // Port of the gRPC API. It is not served when 0.
+
+	// Certificate and key files of the gRPC API, PEM encoded. It is served without TLS when they are not set.
+	GRPCTLSCert string `yaml:"grpc_tls_cert"`
+	GRPCTLSKey  string `yaml:"grpc_tls_key"`
+}
+
+// DatabaseConfig selects the database. Postgres is used when it is configured, with a URL or with a host,
+// a name and a user, and SQLite otherwise.
+type DatabaseConfig struct {
+	URL        string `yaml:"url"` // Postgres URL or DSN, which takes precedence over the fields below.
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port"`
+	Name       string `yaml:"name"`
+	User       string `yaml:"user"`
+	Password   string `yaml:"password"`
+	SSLMode    string `yaml:"sslmode"`
+	SQLitePath string `yaml:"sqlite_path"` // SQLite database file.
+}
+
+// CORSConfig is which browser origins may call the REST API.
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`   // Origins such as "https://app.example.com", or "*" for any. Empty disables CORS.
+	AllowCredentials bool     `yaml:"allow_credentials"` // Allow requests with cookies and TLS client certificates. Requires explicit origins.
+}
+
+// RateLimitsConfig is how requests are rate limited, see middleware.RateLimiter.
+type RateLimitsConfig struct {
+	Policies map[string]string `yaml:"policies"` // Policies of the classes, as "limit/period" or "off". Other classes keep their defaults.
+	Store    string            `yaml:"store"`    // "memory", or "database" to share the counts between API servers.
+}
+
+// SecurityConfig is the security headers of the REST API, and the proxies it trusts.
+type SecurityConfig struct {
+	Headers               *bool    `yaml:"headers"`       // Send the security headers and filter XSS. Defaults to on in production only.
+	AllowedHosts          []string `yaml:"allowed_hosts"` // Host names requests must be made to. Empty allows any.
+	SSLRedirect           bool     `yaml:"ssl_redirect"`  // Redirect HTTP requests to HTTPS.
+	STSSeconds            int64    `yaml:"sts_seconds"`   // max-age of the Strict-Transport-Security header. 0 leaves it out.
+	ContentSecurityPolicy string   `yaml:"content_security_policy"`
+	TrustedProxies        []string `yaml:"trusted_proxies"` // IPs or CIDRs of the proxies whose X-Forwarded-For is trusted. Empty trusts none.
+}
+
+// LogConfig is how requests and events are logged.
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error. Defaults to debug in development and info in production.
+	Format string `yaml:"format"` // text or json.
+}
+
+// DeletedConfig is how long deleted networks and hosts are kept.
+type DeletedConfig struct {
+	Retention time.Duration `yaml:"retention"` // How long they can be restored before they are purged, e.g. "720h".
+}
+
+// IdempotencyConfig is how long responses are replayed to requests retried with the same Idempotency-Key.
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// ConfigWatchConfig is how agents watching their config are woken.
+type ConfigWatchConfig struct {
+	Interval time.Duration `yaml:"interval"` // How often the config revisions of the watched networks are polled.
+}
+
+// WebhooksConfig is how webhook events are sent.
+type WebhooksConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"` // How often the outbox is checked for due deliveries.
+	ExpiryWindow time.Duration `yaml:"expiry_window"` // How long before expiry certificate.expiring is sent.
+}
+
+// Default returns the configuration used for everything that is not configured.
+func Default() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Listen: ListenConfig{
+			Port:     8001,
+			GRPCPort: 9001,
+		},
+		Database: DatabaseConfig{
+			Port:       5432,
+			SSLMode:    "disable",
+			SQLitePath: "koodnet.db",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+		},
+		RateLimits: RateLimitsConfig{
+			Store: "memory",
+		},
+		Security: SecurityConfig{
+			STSSeconds:            315360000,
+			ContentSecurityPolicy: "default-src 'self'",
+		},
+		Log: LogConfig{
+			Format: "text",
+		},
+		Deleted: DeletedConfig{
+			Retention: 30 * 24 * time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: 24 * time.Hour,
+		},
+		ConfigWatch: ConfigWatchConfig{
+			Interval: 2 * time.Second,
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: 5 * time.Second,
+			ExpiryWindow: 30 * 24 * time.Hour,
+		},
+	}
+}
+
+// host is the host name or IP address to listen on. Addresses used to be joined with the port as they are,
+// so ":" and addresses ending with ":", e.g. "127.0.0.1:", are accepted too.
+func (l ListenConfig) host() string {
+	if net.ParseIP(l.Address) != nil {
+		return l.Address
+	}
+
+	host := strings.TrimSuffix(l.Address, ":")
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// RESTAddress is the address the REST API listens on.
+func (l ListenConfig) RESTAddress() string {
+	return net.JoinHostPort(l.host(), strconv.Itoa(l.Port))
+}
+
+// GRPCAddress is the address the gRPC API listens on, or empty when it is not served.
+func (l ListenConfig) GRPCAddress() string {
+	if l.GRPCPort == 0 {
+		return ""
+	}
+
+	return net.JoinHostPort(l.host(), strconv.Itoa(l.GRPCPort))
+}
+
+// PostgresDSN returns the DSN to connect to Postgres with, or empty when Postgres is not configured.
+func (d DatabaseConfig) PostgresDSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+
+	if d.Host == "" || d.Name == "" || d.User == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		d.Host,
+		d.User,
+		d.Password,
+		d.Name,
+		d.Port,
+		d.SSLMode,
+	)
+}
+
+// SecurityHeaders reports whether the security headers are sent.
+func (c *Config) SecurityHeaders() bool {
+	if c.Security.Headers != nil {
+		return *c.Security.Headers
+	}
+
+	return c.Env == EnvProduction
+}
+
+// LogLevel returns the configured log level, or the default of the environment.
+func (c *Config) LogLevel() logrus.Level {
+	if level, err := logrus.ParseLevel(c.Log.Level); err == nil {
+		return level
+	}
+
+	if c.Env == EnvProduction {
+		return logrus.InfoLevel
+	}
+
+	return logrus.DebugLevel
+}
+
+// Validate reports every invalid setting, by its key in the YAML file.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		invalid("env", "must be %s or %s, not %q", EnvDevelopment, EnvProduction, c.Env)
+	}
+
+	// Listener
+	if host := c.Listen.host(); strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		invalid("listen.address", "must be a host name or IP address without a port, not %q", c.Listen.Address)
+	}
+
+	if c.Listen.Port < 1 || c.Listen.Port > 65535 {
+		invalid("listen.port", "must be between 1 and 65535")
+	}
+
+	if c.Listen.GRPCPort < 0 || c.Listen.GRPCPort > 65535 {
+		invalid("listen.grpc_port", "must be between 1 and 65535, or 0 to not serve the gRPC API")
+	}
+
+	if c.Listen.GRPCPort == c.Listen.Port {
+		invalid("listen.grpc_port", "must differ from listen.port")
+	}
+
+	if (c.Listen.GRPCTLSCert == "") != (c.Listen.GRPCTLSKey == "") {
+		invalid("listen.grpc_tls_cert", "and listen.grpc_tls_key must be set together")
+	}
+
+	// Database
+	if c.Database.URL == "" {
+		postgres := []string{c.Database.Host, c.Database.Name, c.Database.User}
+		if slices.ContainsFunc(postgres, func(s string) bool { return s != "" }) && slices.Contains(postgres, "") {
+			invalid("database", "host, name and user are all required to use Postgres")
+		}
+	}
+
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		invalid("database.port", "must be between 1 and 65535")
+	}
+
+	if c.Database.PostgresDSN() == "" && c.Database.SQLitePath == "" {
+		invalid("database.sqlite_path", "is required when Postgres is not configured")
+	}
+
+	// CORS
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				invalid("cors.allowed_origins", `cannot be "*" with cors.allow_credentials, list the origins instead`)
+			}
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			invalid("cors.allowed_origins", "%q must be an http:// or https:// origin without a path, or *", origin)
+		}
+	}
+
+	// Rate limits. The policies are parsed by the rate limiter.
+	if c.RateLimits.Store != "memory" && c.RateLimits.Store != "database" {
+		invalid("rate_limits.store", "must be memory or database, not %q", c.RateLimits.Store)
+	}
+
+	// Security headers
+	if c.Security.STSSeconds < 0 {
+		invalid("security.sts_seconds", "cannot be negative")
+	}
+
+	for _, proxy := range c.Security.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			invalid("security.trusted_proxies", "%q must be an IP address or CIDR", proxy)
+		}
+	}
+
+	// Logging
+	if c.Log.Level != "" {
+		if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+			invalid("log.level", "must be debug, info, warn or error, not %q", c.Log.Level)
+		}
+	}
+
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		invalid("log.format", "must be text or json, not %q", c.Log.Format)
+	}
+
+	// Background work
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"deleted.retention", c.Deleted.Retention},
+		{"idempotency.ttl", c.Idempotency.TTL},
+		{"config_watch.interval", c.ConfigWatch.Interval},
+		{"webhooks.poll_interval", c.Webhooks.PollInterval},
+		{"webhooks.expiry_window", c.Webhooks.ExpiryWindow},
+	}
+
+	for _, d := range durations {
+		if d.value <= 0 {
+			invalid(d.key, "must be a positive duration, e.g. 30s or 24h")
+		}
+	}
+
+	return errors.Join(errs...)
+}
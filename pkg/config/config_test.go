@@ -0,0 +1,171 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("the default configuration is invalid: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		key    string
+		modify func(c *Config)
+	}{
+		{"env", func(c *Config) { c.Env = "staging" }},
+		{"listen.address", func(c *Config) { c.Listen.Address = "127.0.0.1:80" }},
+		{"listen.port", func(c *Config) { c.Listen.Port = 0 }},
+		{"listen.grpc_port", func(c *Config) { c.Listen.GRPCPort = 70000 }},
+		{"listen.grpc_port", func(c *Config) { c.Listen.GRPCPort = c.Listen.Port }},
+		{"listen.grpc_tls_cert", func(c *Config) { c.Listen.GRPCTLSKey = "grpc.key" }},
+		{"database", func(c *Config) { c.Database.Host = "db" }},
+		{"database.port", func(c *Config) { c.Database.Port = -1 }},
+		{"database.sqlite_path", func(c *Config) { c.Database.SQLitePath = "" }},
+		{"cors.allowed_origins", func(c *Config) { c.CORS.AllowCredentials = true }},
+		{"cors.allowed_origins", func(c *Config) { c.CORS.AllowedOrigins = []string{"https://example.com/app"} }},
+		{"cors.allowed_origins", func(c *Config) { c.CORS.AllowedOrigins = []string{"example.com"} }},
+		{"rate_limits.store", func(c *Config) { c.RateLimits.Store = "redis" }},
+		{"security.sts_seconds", func(c *Config) { c.Security.STSSeconds = -1 }},
+		{"security.trusted_proxies", func(c *Config) { c.Security.TrustedProxies = []string{"proxy.example.com"} }},
+		{"log.level", func(c *Config) { c.Log.Level = "loud" }},
+		{"log.format", func(c *Config) { c.Log.Format = "xml" }},
+		{"deleted.retention", func(c *Config) { c.Deleted.Retention = 0 }},
+		{"idempotency.ttl", func(c *Config) { c.Idempotency.TTL = -time.Hour }},
+		{"config_watch.interval", func(c *Config) { c.ConfigWatch.Interval = 0 }},
+		{"webhooks.poll_interval", func(c *Config) { c.Webhooks.PollInterval = 0 }},
+		{"webhooks.expiry_window", func(c *Config) { c.Webhooks.ExpiryWindow = 0 }},
+	}
+
+	for _, tt := range tests {
+		c := Default()
+		tt.modify(c)
+
+		if err := c.Validate(); err == nil || !strings.HasPrefix(err.Error(), tt.key+":") {
+			t.Errorf("%s: got %v, want an error about %s", tt.key, err, tt.key)
+		}
+	}
+
+	// Every invalid setting is reported
+	c := Default()
+	c.Env, c.Log.Format = "staging", "xml"
+
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "env:") || !strings.Contains(err.Error(), "log.format:") {
+		t.Errorf("got %v, want errors about env and log.format", err)
+	}
+}
+
+func TestValidConfigurations(t *testing.T) {
+	tests := map[string]func(c *Config){
+		"postgres": func(c *Config) {
+			c.Database.Host, c.Database.Name, c.Database.User, c.Database.SQLitePath = "db", "koodnet", "koodnet", ""
+		},
+		"postgres url": func(c *Config) { c.Database.URL, c.Database.SQLitePath = "postgres://db/koodnet", "" },
+		"no grpc":      func(c *Config) { c.Listen.GRPCPort = 0 },
+		"grpc tls":     func(c *Config) { c.Listen.GRPCTLSCert, c.Listen.GRPCTLSKey = "grpc.crt", "grpc.key" },
+		"cors credentials": func(c *Config) {
+			c.CORS.AllowedOrigins, c.CORS.AllowCredentials = []string{"https://app.example.com/"}, true
+		},
+		"no cors":           func(c *Config) { c.CORS.AllowedOrigins = nil },
+		"production":        func(c *Config) { c.Env = EnvProduction },
+		"database store":    func(c *Config) { c.RateLimits.Store = "database" },
+		"legacy address":    func(c *Config) { c.Listen.Address = "127.0.0.1:" },
+		"ipv6 address":      func(c *Config) { c.Listen.Address = "[::1]" },
+		"host name address": func(c *Config) { c.Listen.Address = "localhost" },
+		"upper case level":  func(c *Config) { c.Log.Level = "WARN" },
+		"json logs":         func(c *Config) { c.Log.Format = "json" },
+		"no sts header":     func(c *Config) { c.Security.STSSeconds = 0 },
+		"trusted proxies":   func(c *Config) { c.Security.TrustedProxies = []string{"10.0.0.1", "fd00::/8"} },
+	}
+
+	for name, modify := range tests {
+		c := Default()
+		modify(c)
+
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestListenAddresses(t *testing.T) {
+	tests := []struct {
+		address    string
+		rest, grpc string
+	}{
+		{"", ":8001", ":9001"},
+		{":", ":8001", ":9001"},
+		{"127.0.0.1", "127.0.0.1:8001", "127.0.0.1:9001"},
+		{"127.0.0.1:", "127.0.0.1:8001", "127.0.0.1:9001"},
+		{"localhost", "localhost:8001", "localhost:9001"},
+		{"::", "[::]:8001", "[::]:9001"},
+		{"::1", "[::1]:8001", "[::1]:9001"},
+		{"[::1]", "[::1]:8001", "[::1]:9001"},
+		{"[::1]:", "[::1]:8001", "[::1]:9001"},
+	}
+
+	for _, tt := range tests {
+		l := ListenConfig{Address: tt.address, Port: 8001, GRPCPort: 9001}
+
+		if rest, grpc := l.RESTAddress(), l.GRPCAddress(); rest != tt.rest || grpc != tt.grpc {
+			t.Errorf("%q: got %s and %s, want %s and %s", tt.address, rest, grpc, tt.rest, tt.grpc)
+		}
+	}
+
+	if addr := (ListenConfig{Port: 8001}).GRPCAddress(); addr != "" {
+		t.Errorf("got gRPC address %q without a gRPC port, want none", addr)
+	}
+}
+
+func TestPostgresDSN(t *testing.T) {
+	d := Default().Database
+	if dsn := d.PostgresDSN(); dsn != "" {
+		t.Errorf("got DSN %q without Postgres settings, want none", dsn)
+	}
+
+	d.Host, d.Name, d.User, d.Password = "db", "koodnet", "api", "secret"
+	if dsn, want := d.PostgresDSN(), "host=db user=api password=secret dbname=koodnet port=5432 sslmode=disable"; dsn != want {
+		t.Errorf("got DSN %q, want %q", dsn, want)
+	}
+
+	d.URL = "postgres://db/koodnet"
+	if dsn := d.PostgresDSN(); dsn != d.URL {
+		t.Errorf("got DSN %q, want the URL", dsn)
+	}
+}
+
+func TestEnvironmentDefaults(t *testing.T) {
+	on, off := true, false
+
+	tests := []struct {
+		env         string
+		headers     *bool
+		level       string
+		wantHeaders bool
+		wantLevel   logrus.Level
+	}{
+		{EnvDevelopment, nil, "", false, logrus.DebugLevel},
+		{EnvProduction, nil, "", true, logrus.InfoLevel},
+		{EnvDevelopment, &on, "warn", true, logrus.WarnLevel},
+		{EnvProduction, &off, "error", false, logrus.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		c := Default()
+		c.Env, c.Security.Headers, c.Log.Level = tt.env, tt.headers, tt.level
+
+		if got := c.SecurityHeaders(); got != tt.wantHeaders {
+			t.Errorf("%s with headers %v: SecurityHeaders() = %v, want %v", tt.env, tt.headers, got, tt.wantHeaders)
+		}
+
+		if got := c.LogLevel(); got != tt.wantLevel {
+			t.Errorf("%s with level %q: LogLevel() = %v, want %v", tt.env, tt.level, got, tt.wantLevel)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// setting is a value of the configuration that can be set from the environment and a flag.
+type setting struct {
+	key   string            // Key in the YAML file, which the flag is named after.
+	env   string            // Environment variable.
+	usage string            // Description of the flag.
+	field func(*Config) any // Pointer to the field in the configuration.
+}
+
+// flagName is the name of the flag of the setting, e.g. -listen-grpc-port for listen.grpc_port.
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+var settings = []setting{
+	{"env", "KOODNET_ENV", "Environment: development or production", func(c *Config) any { return &c.Env }},
+
+	{"listen.address", "KOODNET_LISTEN_ADDRESS", "Interface to listen on, empty for all", func(c *Config) any { return &c.Listen.Address }},
+	{"listen.port", "KOODNET_LISTEN_PORT", "Port of the REST API", func(c *Config) any { return &c.Listen.Port }},
+	{"listen.grpc_port", "KOODNET_GRPC_LISTEN_PORT", "Port of the gRPC API, 0 to not serve it", func(c *Config) any { return &c.Listen.GRPCPort }},
+	{"listen.grpc_tls_cert", "KOODNET_GRPC_TLS_CERT", "PEM certificate file of the gRPC API, served without TLS when unset", func(c *Config) any { return &c.Listen.GRPCTLSCert }},
+	{"listen.grpc_tls_key", "KOODNET_GRPC_TLS_KEY", "PEM private key file of the gRPC API", func(c *Config) any { return &c.Listen.GRPCTLSKey }},
+
+	{"database.url", "POSTGRES_URL", "Postgres URL or DSN", func(c *Config) any { return &c.Database.URL }},
+	{"database.host", "POSTGRES_HOST", "Postgres host", func(c *Config) any { return &c.Database.Host }},
+	{"database.port", "POSTGRES_PORT", "Postgres port", func(c *Config) any { return &c.Database.Port }},
+	{"database.name", "POSTGRES_DB", "Postgres database name", func(c *Config) any { return &c.Database.Name }},
+	{"database.user", "POSTGRES_USER", "Postgres user", func(c *Config) any { return &c.Database.User }},
+	{"database.password", "POSTGRES_PASSWORD", "Postgres password", func(c *Config) any { return &c.Database.Password }},
+	{"database.sslmode", "POSTGRES_SSLMODE", "Postgres SSL mode", func(c *Config) any { return &c.Database.SSLMode }},
+	{"database.sqlite_path", "KOODNET_SQLITE_PATH", "SQLite database file, used when Postgres is not configured", func(c *Config) any { return &c.Database.SQLitePath }},
+
+	{"cors.allowed_origins", "KOODNET_CORS_ALLOWED_ORIGINS", "Comma-separated origins allowed by CORS, or *", func(c *Config) any { return &c.CORS.AllowedOrigins }},
+	{"cors.allow_credentials", "KOODNET_CORS_ALLOW_CREDENTIALS", "Allow CORS requests with credentials", func(c *Config) any { return &c.CORS.AllowCredentials }},
+
+	{"rate_limits.policies", "KOODNET_RATE_LIMITS", "Rate limits per class, e.g. enroll=30/1m,config=off", func(c *Config) any { return &c.RateLimits.Policies }},
+	{"rate_limits.store", "KOODNET_RATE_LIMIT_STORE", "Where rate limits are counted: memory or database", func(c *Config) any { return &c.RateLimits.Store }},
+
+	{"security.headers", "KOODNET_SECURITY_HEADERS", "Send security headers, by default in production only", func(c *Config) any { return &c.Security.Headers }},
+	{"security.allowed_hosts", "KOODNET_ALLOWED_HOSTS", "Comma-separated host names requests must be made to", func(c *Config) any { return &c.Security.AllowedHosts }},
+	{"security.ssl_redirect", "KOODNET_SSL_REDIRECT", "Redirect HTTP requests to HTTPS", func(c *Config) any { return &c.Security.SSLRedirect }},
+	{"security.sts_seconds", "KOODNET_STS_SECONDS", "max-age of the Strict-Transport-Security header, 0 to leave it out", func(c *Config) any { return &c.Security.STSSeconds }},
+	{"security.content_security_policy", "KOODNET_CONTENT_SECURITY_POLICY", "Content-Security-Policy header", func(c *Config) any { return &c.Security.ContentSecurityPolicy }},
+	{"security.trusted_proxies", "KOODNET_TRUSTED_PROXIES", "Comma-separated IPs or CIDRs of the proxies whose X-Forwarded-For is trusted", func(c *Config) any { return &c.Security.TrustedProxies }},
+
+	{"log.level", "KOODNET_LOG_LEVEL", "Log level: debug, info, warn or error", func(c *Config) any { return &c.Log.Level }},
+	{"log.format", "KOODNET_LOG_FORMAT", "Log format: text or json", func(c *Config) any { return &c.Log.Format }},
+
+	{"deleted.retention", "KOODNET_DELETED_RETENTION", "How long deleted networks and hosts can be restored, e.g. 720h", func(c *Config) any { return &c.Deleted.Retention }},
+	{"idempotency.ttl", "KOODNET_IDEMPOTENCY_TTL", "How long responses are replayed to retries with the same Idempotency-Key", func(c *Config) any { return &c.Idempotency.TTL }},
+	{"config_watch.interval", "KOODNET_CONFIG_WATCH_INTERVAL", "How often the configs that agents watch are checked for changes", func(c *Config) any { return &c.ConfigWatch.Interval }},
+	{"webhooks.poll_interval", "KOODNET_WEBHOOK_POLL_INTERVAL", "How often queued webhook events are sent", func(c *Config) any { return &c.Webhooks.PollInterval }},
+	{"webhooks.expiry_window", "KOODNET_WEBHOOK_EXPIRY_WINDOW", "How long before expiry certificate.expiring is sent", func(c *Config) any { return &c.Webhooks.ExpiryWindow }},
+}
+
+// Flags are the command line flags of the configuration: -config for the YAML file, and one flag per setting,
+// named after its key in the file, e.g. -listen-port for listen.port.
+type Flags struct {
+	fs     *flag.FlagSet
+	path   *string
+	values map[string]*string
+}
+
+// NewFlags defines the flags of the configuration on fs.
+func NewFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs, values: map[string]*string{}}
+
+	f.path = fs.String("config", "", "YAML configuration file (env KOODNET_CONFIG)")
+	for _, s := range settings {
+		f.values[s.key] = fs.String(s.flagName(), "", s.usage+" (env "+s.env+")")
+	}
+
+	return f
+}
+
+// Load returns the configuration once the flags are parsed. The defaults are overridden by the YAML file
+// of -config or KOODNET_CONFIG, then by the environment variables that are not empty, then by the flags that
+// are set. Lists are replaced as a whole, and rate limit policies per class. The configuration is validated.
+func (f *Flags) Load() (*Config, error) {
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+
+	c := Default()
+
+	path := os.Getenv("KOODNET_CONFIG")
+	if set["config"] {
+		path = *f.path
+	}
+
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := setValue(s.field(c), value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if set[s.flagName()] {
+			if err := setValue(s.field(c), *f.values[s.key]); err != nil {
+				return nil, fmt.Errorf("-%s: %w", s.flagName(), err)
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return c, nil
+}
+
+// loadFile overrides the configuration with the settings of a YAML file. Unknown keys are reported,
+// so that misspelled settings are not silently ignored.
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := yaml.NewDecoder(file)
+	dec.SetStrict(true)
+
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// setValue parses value into the field of a setting.
+func setValue(field any, value string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*f = n
+	case *int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*f = n
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration, e.g. 30s or 24h", value)
+		}
+		*f = d
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*f = b
+	case **bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*f = &b
+	case *[]string:
+		*f = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*f = append(*f, item)
+			}
+		}
+	case *map[string]string:
+		if *f == nil {
+			*f = map[string]string{}
+		}
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+
+			key, val, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value entry", entry)
+			}
+			(*f)[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %T", field)
+	}
+
+	return nil
+}
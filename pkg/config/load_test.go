@@ -0,0 +1,128 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTest loads the configuration of a YAML file, the environment and the arguments.
+func loadTest(t *testing.T, yaml string, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+
+	// Empty variables are ignored, which keeps the environment of the test run out
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+	t.Setenv("KOODNET_CONFIG", "")
+
+	if yaml != "" {
+		path := filepath.Join(t.TempDir(), "koodnet.yaml")
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("KOODNET_CONFIG", path)
+	}
+
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	fs := flag.NewFlagSet("koodnet-api", flag.ContinueOnError)
+	flags := NewFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return flags.Load()
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yaml := `
+listen:
+  port: 8100
+  grpc_port: 9100
+log:
+  level: warn
+cors:
+  allowed_origins: ["https://a.example.com", "https://b.example.com"]
+rate_limits:
+  policies:
+    enroll: 10/1m
+    admin: 20/1m
+idempotency:
+  ttl: 1h
+`
+	env := map[string]string{
+		"KOODNET_GRPC_LISTEN_PORT":     "9200",
+		"KOODNET_LOG_LEVEL":            "error",
+		"KOODNET_CORS_ALLOWED_ORIGINS": "https://c.example.com, ",
+		"KOODNET_RATE_LIMITS":          "admin=off",
+	}
+
+	c, err := loadTest(t, yaml, env, "-log-level", "debug", "-listen-address", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key       string
+		got, want any
+	}{
+		{"listen.port from the file", c.Listen.Port, 8100},
+		{"listen.grpc_port from the environment", c.Listen.GRPCPort, 9200},
+		{"log.level from the flag", c.Log.Level, "debug"},
+		{"listen.address from the flag", c.Listen.Address, "127.0.0.1"},
+		{"idempotency.ttl from the file", c.Idempotency.TTL, time.Hour},
+		{"webhooks.poll_interval by default", c.Webhooks.PollInterval, 5 * time.Second},
+		{"cors.allowed_origins replaced as a whole", strings.Join(c.CORS.AllowedOrigins, " "), "https://c.example.com"},
+		{"rate_limits.policies.enroll kept", c.RateLimits.Policies["enroll"], "10/1m"},
+		{"rate_limits.policies.admin overridden", c.RateLimits.Policies["admin"], "off"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadFlagsOverrideEmptyValues(t *testing.T) {
+	// Flags that are set apply even when empty, unlike environment variables
+	c, err := loadTest(t, "listen:\n  address: 10.0.0.1\n", map[string]string{"KOODNET_LISTEN_ADDRESS": ""}, "-listen-address=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Listen.Address != "" {
+		t.Errorf("listen.address is %q, want the empty address of the flag", c.Listen.Address)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		args    []string
+		inError string
+	}{
+		{name: "unknown key", yaml: "listen:\n  prot: 80\n", inError: "prot"},
+		{name: "invalid environment variable", env: map[string]string{"KOODNET_LISTEN_PORT": "http"}, inError: "KOODNET_LISTEN_PORT"},
+		{name: "invalid flag", args: []string{"-idempotency-ttl", "a day"}, inError: "-idempotency-ttl"},
+		{name: "invalid map entry", env: map[string]string{"KOODNET_RATE_LIMITS": "enroll"}, inError: "key=value"},
+		{name: "invalid configuration", args: []string{"-env", "staging"}, inError: "env:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTest(t, tt.yaml, tt.env, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.inError) {
+				t.Errorf("got %v, want an error about %s", err, tt.inError)
+			}
+		})
+	}
+}
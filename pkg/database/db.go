@@ -1,54 +1,31 @@
 package database
 
 import (
-	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"github.com/koodeyo/koodnet/pkg/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func getPostgresURL() string {
-	db_url := os.Getenv("POSTGRES_URL")
-	if db_url != "" {
-		return db_url
-	}
-
-	db_hostname := os.Getenv("POSTGRES_HOST")
-	db_name := os.Getenv("POSTGRES_DB")
-	db_pass := os.Getenv("POSTGRES_PASSWORD")
-	db_user := os.Getenv("POSTGRES_USER")
-	db_port := os.Getenv("POSTGRES_PORT")
-
-	if db_port == "" {
-		db_port = "5432"
-	}
-
-	if db_hostname != "" && db_name != "" && db_user != "" {
-		return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-			db_hostname,
-			db_user,
-			db_pass,
-			db_name,
-			db_port,
-		)
-	}
+var Conn *gorm.DB
 
-	return ""
+// gormConfig translates driver errors, such as unique violations, into the errors of gorm,
+// so that they are reported the same way on SQLite and Postgres.
+func gormConfig() *gorm.Config {
+	return &gorm.Config{TranslateError: true}
 }
 
-var Conn *gorm.DB
-
-func Connect() {
+// Connect connects to Postgres when it is configured, and to SQLite otherwise.
+func Connect(cfg config.DatabaseConfig) {
 	var err error
 
-	dbURL := getPostgresURL()
+	dbURL := cfg.PostgresDSN()
 	if dbURL != "" {
 		for i := 1; i <= 3; i++ {
-			Conn, err = gorm.Open(postgres.Open(dbURL), &gorm.Config{})
+			Conn, err = gorm.Open(postgres.Open(dbURL), gormConfig())
 			if err == nil {
 				break
 			} else {
@@ -60,8 +37,8 @@ func Connect() {
 			log.Fatalf("Failed to connect to PostgreSQL after 3 attempts: %v", err)
 		}
 	} else {
-		log.Println("PostgreSQL environment variables not defined. Falling back to SQLite.")
-		Conn, err = gorm.Open(sqlite.Open("koodnet.db"), &gorm.Config{})
+		log.Printf("PostgreSQL is not configured. Falling back to SQLite at %s.", cfg.SQLitePath)
+		Conn, err = gorm.Open(sqlite.Open(cfg.SQLitePath), gormConfig())
 		if err != nil {
 			log.Fatalf("Failed to initialize SQLite database: %v", err)
 		}
@@ -1,10 +1,45 @@
 package database
 
-import "github.com/koodeyo/koodnet/pkg/models"
+import (
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
 
 func Migrate() {
+	Conn.AutoMigrate(&models.Organization{})
 	Conn.AutoMigrate(&models.Network{})
 	Conn.AutoMigrate(&models.Certificate{})
 	Conn.AutoMigrate(&models.Host{})
 	Conn.AutoMigrate(&models.Configuration{})
+	Conn.AutoMigrate(&models.APIToken{})
+	Conn.AutoMigrate(&models.RoleBinding{})
+	Conn.AutoMigrate(&models.AuditEvent{})
+	Conn.AutoMigrate(&models.IdempotencyKey{})
+	Conn.AutoMigrate(&models.Webhook{})
+	Conn.AutoMigrate(&models.WebhookDelivery{})
+	Conn.AutoMigrate(&models.RateLimitWindow{})
+
+	dropOutdatedUniqueIndexes()
+	models.BackfillHostIPNumbers(Conn)
+}
+
+// Network names used to be unique across all networks, and host names and IPs too. They are now
+// unique per organization and network, among the networks and hosts that are not deleted.
+// The indexes replacing them have other names, so the old ones are dropped.
+func dropOutdatedUniqueIndexes() {
+	// The sqlite migrator logs these queries in debug mode, which would end up in the output of bootstrap-token
+	migrator := Conn.Session(&gorm.Session{Logger: logger.Discard}).Migrator()
+
+	for _, name := range []string{"idx_name_cidr", "idx_org_name"} {
+		if migrator.HasIndex(&models.Network{}, name) {
+			migrator.DropIndex(&models.Network{}, name)
+		}
+	}
+
+	for _, name := range []string{"idx_name_network", "idx_ip_network"} {
+		if migrator.HasIndex(&models.Host{}, name) {
+			migrator.DropIndex(&models.Host{}, name)
+		}
+	}
 }
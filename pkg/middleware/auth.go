@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+const apiTokenKey = "apiToken"
+
+// TokenVerifier resolves a plaintext API token to the stored token.
+type TokenVerifier func(token string) (*models.APIToken, error)
+
+// Auth authenticates requests with an API token sent as "Authorization: Bearer <token>".
+func Auth(verify TokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || strings.TrimSpace(raw) == "" {
+			abortWithError(c, http.StatusUnauthorized, "ERR_UNAUTHORIZED", "An API token is required.")
+			return
+		}
+
+		token, err := verify(strings.TrimSpace(raw))
+		if err != nil {
+			abortWithError(c, http.StatusUnauthorized, "ERR_UNAUTHORIZED", err.Error())
+			return
+		}
+
+		c.Set(apiTokenKey, token)
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose API token does not grant every one of the scopes.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, scope := range scopes {
+			if !HasScope(c, scope) {
+				abortWithError(c, http.StatusForbidden, "ERR_FORBIDDEN", "The API token is missing the "+scope+" scope.")
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// CurrentToken returns the API token the request was authenticated with, if any.
+func CurrentToken(c *gin.Context) *models.APIToken {
+	if v, ok := c.Get(apiTokenKey); ok {
+		if token, ok := v.(*models.APIToken); ok {
+			return token
+		}
+	}
+
+	return nil
+}
+
+// HasScope reports whether the request's API token grants scope.
+func HasScope(c *gin.Context, scope string) bool {
+	token := CurrentToken(c)
+	return token != nil && token.HasScope(scope)
+}
+
+// abortWithError responds with the same error body as the API handlers.
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"errors": []gin.H{
+			{
+				"code":    code,
+				"message": message,
+			},
+		},
+	})
+}
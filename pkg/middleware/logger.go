@@ -26,6 +26,7 @@ func Logger(logger *logrus.Logger) gin.HandlerFunc {
 			"duration":   duration,
 			"ip":         c.ClientIP(),
 			"user-agent": c.Request.UserAgent(),
+			"request-id": CurrentRequestID(c),
 		}
 
 		// Include errors if they exist
@@ -1,21 +1,262 @@
 package middleware
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
 )
 
-func RateLimiter(r rate.Limit, b int) gin.HandlerFunc {
-	limiter := rate.NewLimiter(r, b)
+// Rate limit classes
+const (
+	RateClassIP      = "ip"      // Every request, per client IP, before authentication.
+	RateClassDefault = "default" // Authenticated requests that are in no other class.
+	RateClassEnroll  = "enroll"  // Host creation.
+	RateClassConfig  = "config"  // Config downloads and watches by agents.
+	RateClassAdmin   = "admin"   // Tokens, organizations, webhooks and the audit log.
+)
+
+// RatePolicy allows Limit requests per Period. A Limit of 0 means unlimited.
+type RatePolicy struct {
+	Limit  int
+	Period time.Duration
+}
+
+func (p RatePolicy) String() string {
+	if p.Limit == 0 {
+		return "off"
+	}
+
+	return strconv.Itoa(p.Limit) + "/" + p.Period.String()
+}
+
+// DefaultRatePolicies are the policies of each class when not configured otherwise.
+var DefaultRatePolicies = map[string]RatePolicy{
+	RateClassIP:      {Limit: 600, Period: time.Minute},
+	RateClassDefault: {Limit: 300, Period: time.Minute},
+	RateClassEnroll:  {Limit: 60, Period: time.Minute},
+	RateClassConfig:  {Limit: 120, Period: time.Minute},
+	RateClassAdmin:   {Limit: 60, Period: time.Minute},
+}
+
+// ParseRatePolicies overrides the defaults with the policies of the classes, as "limit/period" or "off",
+// e.g. {"enroll": "30/1m", "config": "off"}.
+func ParseRatePolicies(overrides map[string]string) (map[string]RatePolicy, error) {
+	policies := make(map[string]RatePolicy, len(DefaultRatePolicies))
+	for class, p := range DefaultRatePolicies {
+		policies[class] = p
+	}
+
+	classes := make([]string, 0, len(overrides))
+	for class := range overrides {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	for _, class := range classes {
+		if _, known := DefaultRatePolicies[class]; !known {
+			return nil, fmt.Errorf("unknown rate limit class %q", class)
+		}
+
+		p, err := parseRatePolicy(overrides[class])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", class, err)
+		}
+
+		policies[class] = p
+	}
+
+	return policies, nil
+}
+
+func parseRatePolicy(s string) (RatePolicy, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return RatePolicy{}, nil
+	}
+
+	limit, period, ok := strings.Cut(s, "/")
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if !ok || err != nil || n < 0 {
+		return RatePolicy{}, fmt.Errorf("invalid rate limit %q, expected limit/period or off", s)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return RatePolicy{}, fmt.Errorf("invalid rate limit period in %q", s)
+	}
+
+	return RatePolicy{Limit: n, Period: d}, nil
+}
+
+// RateLimitStore counts the requests of each bucket in fixed windows of a policy's period.
+type RateLimitStore interface {
+	// Hit counts a request in the current window of the bucket, and returns the number
+	// of requests in the window so far, including this one, and when the window ends.
+	Hit(bucket string, period time.Duration) (count int, reset time.Time, err error)
+}
+
+// windowStart returns the start of the fixed window of period that now falls in.
+func windowStart(now time.Time, period time.Duration) time.Time {
+	return now.Truncate(period)
+}
+
+// MemoryRateLimitStore keeps the windows in memory. It suits a single API server.
+// Ended windows are evicted as new requests come in.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	end   time.Time
+	count int
+}
+
+const rateWindowSweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{windows: map[string]*rateWindow{}}
+}
+
+func (s *MemoryRateLimitStore) Hit(bucket string, period time.Duration) (int, time.Time, error) {
+	now := time.Now()
+	start := windowStart(now, period)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= rateWindowSweepInterval {
+		for key, w := range s.windows {
+			if !w.end.After(now) {
+				delete(s.windows, key)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	w, ok := s.windows[bucket]
+	if !ok || !w.start.Equal(start) {
+		w = &rateWindow{start: start, end: start.Add(period)}
+		s.windows[bucket] = w
+	}
+	w.count++
+
+	return w.count, w.end, nil
+}
+
+// DatabaseRateLimitStore keeps the windows in the database, so that API servers
+// sharing it share their rate limits. Ended windows are purged once a minute.
+type DatabaseRateLimitStore struct {
+	DB *gorm.DB
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+func (s *DatabaseRateLimitStore) Hit(bucket string, period time.Duration) (int, time.Time, error) {
+	now := time.Now()
+	start := windowStart(now, period)
+	end := start.Add(period)
+
+	s.mu.Lock()
+	purge := now.Sub(s.lastPurge) >= rateWindowSweepInterval
+	if purge {
+		s.lastPurge = now
+	}
+	s.mu.Unlock()
+
+	if purge {
+		if err := models.PurgeRateLimitWindows(s.DB, now); err != nil {
+			return 0, end, err
+		}
+	}
+
+	count, err := models.HitRateLimitWindow(s.DB, bucket, start, end)
+	return count, end, err
+}
+
+// RateLimiter applies a policy per route class to buckets of API tokens or client IPs.
+type RateLimiter struct {
+	Store    RateLimitStore
+	Policies map[string]RatePolicy
+}
+
+// Hit counts a request of the caller key in the bucket of class, and returns the policy of the class,
+// the number of requests in the window so far and when the window ends. Classes that are off count nothing.
+func (l *RateLimiter) Hit(class, key string) (p RatePolicy, count int, reset time.Time, err error) {
+	p = l.Policies[class]
+	if p.Limit == 0 {
+		return p, 0, time.Time{}, nil
+	}
+
+	count, reset, err = l.Store.Hit(class+":"+key, p.Period)
+	return p, count, reset, err
+}
+
+// RateLimitKey is the key of the buckets of a caller: its API token once authenticated, and its IP before.
+func RateLimitKey(token *models.APIToken, ip string) string {
+	if token != nil {
+		return "token:" + token.ID.String()
+	}
+
+	return "ip:" + ip
+}
+
+// RetryAfter returns the whole seconds until reset, and at least one.
+func RetryAfter(reset time.Time) int {
+	return max(int(math.Ceil(time.Until(reset).Seconds())), 1)
+}
+
+// Limit rate limits requests by the policy of the class that classify sorts them into.
+// Requests are counted per API token once authenticated, and per client IP before. Responses carry
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset, and 429s carry Retry-After.
+// When the store fails, requests are let through.
+func (l *RateLimiter) Limit(classify func(c *gin.Context) string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !limiter.AllowN(time.Now(), 1) {
-			c.String(http.StatusTooManyRequests, "Rate limit exceeded")
-			c.Abort()
+		class := classify(c)
+
+		p, count, reset, err := l.Hit(class, RateLimitKey(CurrentToken(c), c.ClientIP()))
+		if p.Limit == 0 {
+			c.Next()
 			return
 		}
+
+		if err != nil {
+			c.Error(err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(p.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(max(p.Limit-count, 0)))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if count > p.Limit {
+			retryAfter := RetryAfter(reset)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			abortWithError(c, http.StatusTooManyRequests, "ERR_RATE_LIMITED",
+				fmt.Sprintf("Rate limit of %s for %s requests exceeded, retry in %d seconds", p, class, retryAfter))
+			return
+		}
+
 		c.Next()
 	}
 }
+
+// RateClass classifies every request as class.
+func RateClass(class string) func(c *gin.Context) string {
+	return func(*gin.Context) string {
+		return class
+	}
+}
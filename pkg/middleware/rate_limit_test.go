@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseRatePolicies(t *testing.T) {
+	policies, err := ParseRatePolicies(map[string]string{"enroll": " 30 / 1m ", "config": "off"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]RatePolicy{
+		RateClassIP:      DefaultRatePolicies[RateClassIP],
+		RateClassDefault: DefaultRatePolicies[RateClassDefault],
+		RateClassEnroll:  {Limit: 30, Period: time.Minute},
+		RateClassConfig:  {},
+		RateClassAdmin:   DefaultRatePolicies[RateClassAdmin],
+	}
+
+	if len(policies) != len(want) {
+		t.Fatalf("got %v, want %v", policies, want)
+	}
+	for class, p := range want {
+		if policies[class] != p {
+			t.Errorf("%s: got %v, want %v", class, policies[class], p)
+		}
+	}
+
+	// The defaults are left alone
+	if DefaultRatePolicies[RateClassEnroll].Limit != 60 {
+		t.Errorf("the default enroll policy changed to %v", DefaultRatePolicies[RateClassEnroll])
+	}
+
+	for _, invalid := range []map[string]string{
+		{"unknown": "1/1s"},
+		{"admin": "10"},
+		{"admin": "-1/1m"},
+		{"admin": "ten/1m"},
+		{"admin": "10/0s"},
+		{"admin": "10/soon"},
+	} {
+		if _, err := ParseRatePolicies(invalid); err == nil {
+			t.Errorf("ParseRatePolicies(%v) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+
+	for i := 1; i <= 3; i++ {
+		count, reset, err := s.Hit("a", time.Hour)
+		if err != nil || count != i {
+			t.Fatalf("hit %d of bucket a = %d, %v, want %d", i, count, err, i)
+		}
+
+		if want := windowStart(time.Now(), time.Hour).Add(time.Hour); !reset.Equal(want) {
+			t.Errorf("reset at %v, want the end of the window at %v", reset, want)
+		}
+	}
+
+	if count, _, _ := s.Hit("b", time.Hour); count != 1 {
+		t.Errorf("first hit of bucket b counted %d, want 1", count)
+	}
+
+	// Every window starts counting anew
+	s.windows["a"].start = s.windows["a"].start.Add(-time.Hour)
+	if count, _, _ := s.Hit("a", time.Hour); count != 1 {
+		t.Errorf("first hit of a new window counted %d, want 1", count)
+	}
+}
+
+func TestDatabaseRateLimitStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.RateLimitWindow{}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &DatabaseRateLimitStore{DB: db}
+	for i := 1; i <= 3; i++ {
+		if count, _, err := s.Hit("a", time.Hour); err != nil || count != i {
+			t.Fatalf("hit %d of bucket a = %d, %v, want %d", i, count, err, i)
+		}
+	}
+
+	if count, _, err := s.Hit("b", time.Hour); err != nil || count != 1 {
+		t.Errorf("first hit of bucket b = %d, %v, want 1", count, err)
+	}
+}
+
+// failingStore fails every hit.
+type failingStore struct{}
+
+func (failingStore) Hit(string, time.Duration) (int, time.Time, error) {
+	return 0, time.Time{}, errors.New("store down")
+}
+
+// newRateLimitedRouter limits GET / to limit requests per hour, authenticating requests that have a bearer token.
+func newRateLimitedRouter(store RateLimitStore, limit int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	tokens := map[string]*models.APIToken{}
+	auth := func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			return
+		}
+
+		Auth(func(raw string) (*models.APIToken, error) {
+			if tokens[raw] == nil {
+				tokens[raw] = &models.APIToken{ID: uuid.New()}
+			}
+			return tokens[raw], nil
+		})(c)
+	}
+
+	limiter := &RateLimiter{
+		Store:    store,
+		Policies: map[string]RatePolicy{RateClassDefault: {Limit: limit, Period: time.Hour}},
+	}
+
+	r := gin.New()
+	r.GET("/", auth, limiter.Limit(RateClass(RateClassDefault)), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	return r
+}
+
+func getRateLimited(r http.Handler, ip, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":1234"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter(t *testing.T) {
+	r := newRateLimitedRouter(NewMemoryRateLimitStore(), 2)
+
+	for i := 1; i <= 2; i++ {
+		w := getRateLimited(r, "192.0.2.1", "")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("request %d got %d, want 204", i, w.Code)
+		}
+
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(2-i) {
+			t.Errorf("request %d has %s remaining, want %d", i, got, 2-i)
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Reset") == "" {
+			t.Errorf("request %d is missing the limit or reset header: %v", i, w.Header())
+		}
+	}
+
+	w := getRateLimited(r, "192.0.2.1", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request got %d, want 429", w.Code)
+	}
+	if retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 3600 {
+		t.Errorf("got Retry-After %q, want the seconds until the window ends", w.Header().Get("Retry-After"))
+	}
+
+	// Other IPs and API tokens have buckets of their own, also from the same IP
+	if w := getRateLimited(r, "192.0.2.2", ""); w.Code != http.StatusNoContent {
+		t.Errorf("request of another IP got %d, want 204", w.Code)
+	}
+	for i := 1; i <= 2; i++ {
+		if w := getRateLimited(r, "192.0.2.1", "alice"); w.Code != http.StatusNoContent {
+			t.Errorf("request %d of a token got %d, want 204", i, w.Code)
+		}
+	}
+	if w := getRateLimited(r, "192.0.2.1", "alice"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third request of a token got %d, want 429", w.Code)
+	}
+	if w := getRateLimited(r, "192.0.2.1", "bob"); w.Code != http.StatusNoContent {
+		t.Errorf("request of another token got %d, want 204", w.Code)
+	}
+}
+
+func TestRateLimiterLetsRequestsThrough(t *testing.T) {
+	// Classes that are off
+	r := newRateLimitedRouter(NewMemoryRateLimitStore(), 0)
+	for i := 0; i < 5; i++ {
+		if w := getRateLimited(r, "192.0.2.1", ""); w.Code != http.StatusNoContent || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("request %d got %d with headers %v, want 204 without rate limit headers", i, w.Code, w.Header())
+		}
+	}
+
+	// Stores that fail
+	r = newRateLimitedRouter(failingStore{}, 1)
+	for i := 0; i < 2; i++ {
+		if w := getRateLimited(r, "192.0.2.1", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d got %d, want 204", i, w.Code)
+		}
+	}
+}
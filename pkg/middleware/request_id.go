@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeader     = "X-Request-ID"
+	requestIDContextKey = "requestId"
+)
+
+// RequestID tags every request with an ID, taken from the X-Request-ID header when the
+// client sends one, and echoes it in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDContextKey, id)
+		c.Header(requestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// CurrentRequestID returns the ID of the request.
+func CurrentRequestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
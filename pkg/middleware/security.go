@@ -5,17 +5,24 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Security() gin.HandlerFunc {
+// SecurityOptions are the security header settings that differ between deployments.
+type SecurityOptions struct {
+	AllowedHosts          []string // Host names requests must be made to. Empty allows any.
+	SSLRedirect           bool     // Redirect HTTP requests to HTTPS.
+	STSSeconds            int64    // max-age of the Strict-Transport-Security header. 0 leaves it out.
+	ContentSecurityPolicy string
+}
+
+func Security(opts SecurityOptions) gin.HandlerFunc {
 	return secure.New(secure.Config{
-		//AllowedHosts:          []string{"example.com", "ssl.example.com"},
-		//SSLRedirect:           true,
-		//SSLHost:               "ssl.example.com",
-		STSSeconds:            315360000,
+		AllowedHosts:          opts.AllowedHosts,
+		SSLRedirect:           opts.SSLRedirect,
+		STSSeconds:            opts.STSSeconds,
 		STSIncludeSubdomains:  true,
 		FrameDeny:             true,
 		ContentTypeNosniff:    true,
 		BrowserXssFilter:      true,
-		ContentSecurityPolicy: "default-src 'self'",
+		ContentSecurityPolicy: opts.ContentSecurityPolicy,
 		IENoOpen:              true,
 		ReferrerPolicy:        "strict-origin-when-cross-origin",
 		SSLProxyHeaders:       map[string]string{"X-Forwarded-Proto": "https"},
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// AccessibleBy restricts a query to the rows of networks on which the token may perform action.
+// column is the network ID column of the queried table, e.g. "networks.id" or "hosts.network_id".
+// Platform-wide tokens with the admin scope see everything.
+func AccessibleBy(t *APIToken, column, action string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if t == nil {
+			return db.Where("1 = 0")
+		}
+
+		if t.IsAdmin() && t.OrganizationID == nil {
+			return db
+		}
+
+		return db.Where(column+" IN (?)", NetworksAllowing(db, t, action))
+	}
+}
+
+// CertificatesAccessibleBy restricts a certificates query to the CAs of networks and the
+// certificates of hosts on which the token may perform action.
+func CertificatesAccessibleBy(t *APIToken, action string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if t == nil {
+			return db.Where("1 = 0")
+		}
+
+		if t.IsAdmin() && t.OrganizationID == nil {
+			return db
+		}
+
+		networks := NetworksAllowing(db, t, action)
+		hosts := db.Session(&gorm.Session{NewDB: true}).Model(&Host{}).Select("id").Where("network_id IN (?)", networks)
+
+		return db.Where("certificates.owner_id IN (?) OR certificates.owner_id IN (?)", networks, hosts)
+	}
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// API token scopes
+const (
+	ScopeAdmin            = "admin" // Grants every scope, including managing API tokens.
+	ScopeNetworksRead     = "networks:read"
+	ScopeNetworksWrite    = "networks:write"
+	ScopeHostsRead        = "hosts:read"
+	ScopeHostsWrite       = "hosts:write"
+	ScopeCertificatesRead = "certificates:read"
+	ScopeKeysRead         = "keys:read" // Private keys, e.g. host configs and CA keys.
+)
+
+const (
+	apiTokenPrefix      = "knt_"
+	apiTokenRandomBytes = 32
+	apiTokenUseInterval = time.Minute // How stale LastUsedAt gets before a request refreshes it.
+)
+
+var Scopes = []string{
+	ScopeAdmin,
+	ScopeNetworksRead,
+	ScopeNetworksWrite,
+	ScopeHostsRead,
+	ScopeHostsWrite,
+	ScopeCertificatesRead,
+	ScopeKeysRead,
+}
+
+var ErrInvalidAPIToken = errors.New("invalid or expired API token")
+
+type APIToken struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	Name           string     `json:"name" gorm:"size:255;not null"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty" gorm:"type:uuid;index"` // Organization the token is confined to. Tokens without one are platform-wide.
+	Prefix         string     `json:"prefix" gorm:"size:16"`                           // First characters of the token, to recognize it without storing it.
+	Hash           string     `json:"-" gorm:"size:64;not null;uniqueIndex"`           // SHA-256 of the token. The token itself is never stored.
+	Scopes         []string   `json:"scopes" gorm:"serializer:json;default:'[]'"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// DTO for create operations
+type APITokenDto struct {
+	Name           string     `json:"name" binding:"required" example:"ci"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty" example:"c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"`
+	Scopes         []string   `json:"scopes" binding:"required" example:"networks:read,hosts:write"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty" example:"2030-01-01T00:00:00Z"`
+}
+
+// NewAPIToken creates a token model and returns it together with the plaintext token,
+// which is only available at creation time.
+func NewAPIToken(name string, scopes []string, expiresAt *time.Time) (*APIToken, string, error) {
+	buf := make([]byte, apiTokenRandomBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+
+	raw := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	return &APIToken{
+		Name:      name,
+		Prefix:    raw[:len(apiTokenPrefix)+6],
+		Hash:      hashAPIToken(raw),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}, raw, nil
+}
+
+func hashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAPIToken looks up an active token by its plaintext value and records its use.
+// The use is only written when the recorded one is older than apiTokenUseInterval,
+// rather than on every request.
+func VerifyAPIToken(db *gorm.DB, raw string) (*APIToken, error) {
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	var t APIToken
+	if err := db.First(&t, "hash = ?", hashAPIToken(raw)).Error; err != nil {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if !t.Active() {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if t.LastUsedAt == nil || time.Since(*t.LastUsedAt) >= apiTokenUseInterval {
+		now := time.Now()
+		if err := db.Model(&t).UpdateColumn("last_used_at", now).Error; err != nil {
+			db.Logger.Error(db.Statement.Context, "failed to record the use of API token %s: %v", t.ID, err)
+		} else {
+			t.LastUsedAt = &now
+		}
+	}
+
+	return &t, nil
+}
+
+// Active reports whether the token is neither revoked nor expired.
+func (t *APIToken) Active() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+
+	return t.ExpiresAt == nil || t.ExpiresAt.After(time.Now())
+}
+
+// IsAdmin reports whether the token has the admin scope.
+func (t *APIToken) IsAdmin() bool {
+	return slices.Contains(t.Scopes, ScopeAdmin)
+}
+
+// InOrganization reports whether the token may act within the organization.
+// Platform-wide tokens may act within every organization.
+func (t *APIToken) InOrganization(orgID *uuid.UUID) bool {
+	if t.OrganizationID == nil {
+		return true
+	}
+
+	return orgID != nil && *orgID == *t.OrganizationID
+}
+
+// HasScope reports whether the token grants scope. The admin scope grants every scope.
+func (t *APIToken) HasScope(scope string) bool {
+	return t.IsAdmin() || slices.Contains(t.Scopes, scope)
+}
+
+// Validators
+func (t *APIToken) validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return NewFieldError("name", "name cannot be empty")
+	}
+
+	if len(t.Scopes) == 0 {
+		return NewFieldError("scopes", "at least one scope is required")
+	}
+
+	for i, scope := range t.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return NewFieldError(fmt.Sprintf("scopes[%d]", i), "invalid scope: "+scope+"; valid options are "+strings.Join(Scopes, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Hooks
+func (t *APIToken) BeforeCreate(tx *gorm.DB) error {
+	t.ID = uuid.New()
+
+	return nil
+}
+
+func (t *APIToken) BeforeSave(tx *gorm.DB) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
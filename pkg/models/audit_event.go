@@ -0,0 +1,302 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audited actions
+const (
+	AuditActionCreate   = "create"
+	AuditActionUpdate   = "update"
+	AuditActionDelete   = "delete"
+	AuditActionDownload = "download" // Config or key material was handed out.
+	AuditActionRestore  = "restore"  // A deleted network or host was restored.
+)
+
+// Audited resource types
+const (
+	AuditResourceNetwork     = "network"
+	AuditResourceHost        = "host"
+	AuditResourceCertificate = "certificate"
+)
+
+// auditLockKey serializes appends to the audit chain across API servers sharing a postgres database.
+const auditLockKey = 0x6b6e7461756474
+
+// Fields whose values never end up in the audit log. Changes to them are recorded as redacted.
+var auditRedactedFields = []string{"key", "passphrase", "hostKey"}
+
+const auditRedacted = "[REDACTED]"
+
+var ErrAuditEventImmutable = errors.New("audit events cannot be changed or deleted")
+
+// AuditChange is a changed field of an audited resource, by its JSON path.
+type AuditChange struct {
+	Field  string `json:"field" example:"configuration.listen.port"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// AuditEvent is an entry of the append-only audit log. Every event stores the hash of the
+// previous one, so that changing or removing an event breaks the chain from there on.
+type AuditEvent struct {
+	ID             uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;"`
+	Sequence       uint64        `json:"sequence" gorm:"not null;uniqueIndex"`
+	Action         string        `json:"action" gorm:"size:32;not null;index"`
+	ResourceType   string        `json:"resourceType" gorm:"size:32;not null;index"`
+	ResourceID     uuid.UUID     `json:"resourceId" gorm:"type:uuid;not null;index"`
+	NetworkID      *uuid.UUID    `json:"networkId,omitempty" gorm:"type:uuid;index"`
+	OrganizationID *uuid.UUID    `json:"organizationId,omitempty" gorm:"type:uuid;index"`
+	ActorID        *uuid.UUID    `json:"actorId,omitempty" gorm:"type:uuid;index"` // API token that made the request.
+	ActorName      string        `json:"actorName" gorm:"size:255"`
+	RequestID      string        `json:"requestId" gorm:"size:64;index"`
+	SourceIP       string        `json:"sourceIp" gorm:"size:64"`
+	Changes        []AuditChange `json:"changes" gorm:"serializer:json;default:'[]'"`
+	PrevHash       string        `json:"prevHash" gorm:"size:64"`
+	Hash           string        `json:"hash" gorm:"size:64;not null"`
+	CreatedAt      time.Time     `json:"createdAt"`
+}
+
+// AuditChainStatus is the result of verifying the audit chain.
+type AuditChainStatus struct {
+	Valid    bool    `json:"valid"`
+	Events   int     `json:"events"`
+	BrokenAt *uint64 `json:"brokenAt,omitempty"` // Sequence of the first event that does not match the chain.
+}
+
+// NewAuditEvent creates an event for action on a resource, recording the fields that differ
+// between before and after. Either may be nil, e.g. for creates and deletes.
+func NewAuditEvent(action, resourceType string, resourceID uuid.UUID, networkID *uuid.UUID, before, after any) (AuditEvent, error) {
+	changes, err := diffAuditFields(before, after)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+
+	return AuditEvent{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		NetworkID:    networkID,
+		Changes:      changes,
+	}, nil
+}
+
+// RecordAuditEvent appends the event to the audit chain. It should run in the
+// transaction of the change it records, so that neither is saved without the other.
+func RecordAuditEvent(tx *gorm.DB, e *AuditEvent) error {
+	if tx.Dialector.Name() == "postgres" {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error; err != nil {
+			return err
+		}
+	}
+
+	if e.OrganizationID == nil && e.NetworkID != nil {
+		var n Network
+		if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Select("id", "organization_id").Find(&n, "id = ?", *e.NetworkID).Error; err != nil {
+			return err
+		}
+		e.OrganizationID = n.OrganizationID
+	}
+
+	var last AuditEvent
+	if err := tx.Session(&gorm.Session{NewDB: true}).Order("sequence DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+
+	e.Sequence = last.Sequence + 1
+	e.PrevHash = last.Hash
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	e.Hash = e.computeHash()
+
+	return tx.Create(e).Error
+}
+
+// VerifyAuditChain recomputes the hash of every event in order and reports the first one that does not match.
+func VerifyAuditChain(db *gorm.DB) (AuditChainStatus, error) {
+	status := AuditChainStatus{Valid: true}
+	prev := AuditEvent{}
+
+	for {
+		var events []AuditEvent
+		if err := db.Where("sequence > ?", prev.Sequence).Order("sequence").Limit(500).Find(&events).Error; err != nil {
+			return status, err
+		}
+
+		if len(events) == 0 {
+			break
+		}
+
+		for _, e := range events {
+			status.Events++
+			if status.Valid && (e.Sequence != prev.Sequence+1 || e.PrevHash != prev.Hash || e.Hash != e.computeHash()) {
+				seq := e.Sequence
+				status.Valid = false
+				status.BrokenAt = &seq
+			}
+
+			prev = e
+		}
+	}
+
+	return status, nil
+}
+
+// computeHash hashes the event's content together with the hash of the previous event.
+func (e *AuditEvent) computeHash() string {
+	payload, _ := json.Marshal(struct {
+		Sequence       uint64        `json:"sequence"`
+		Action         string        `json:"action"`
+		ResourceType   string        `json:"resourceType"`
+		ResourceID     uuid.UUID     `json:"resourceId"`
+		NetworkID      *uuid.UUID    `json:"networkId"`
+		OrganizationID *uuid.UUID    `json:"organizationId"`
+		ActorID        *uuid.UUID    `json:"actorId"`
+		ActorName      string        `json:"actorName"`
+		RequestID      string        `json:"requestId"`
+		SourceIP       string        `json:"sourceIp"`
+		Changes        []AuditChange `json:"changes"`
+		PrevHash       string        `json:"prevHash"`
+		CreatedAt      string        `json:"createdAt"`
+	}{
+		e.Sequence, e.Action, e.ResourceType, e.ResourceID, e.NetworkID, e.OrganizationID,
+		e.ActorID, e.ActorName, e.RequestID, e.SourceIP, e.Changes, e.PrevHash,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// diffAuditFields compares the JSON representations of before and after field by field.
+// Timestamps are skipped and key material is redacted.
+func diffAuditFields(before, after any) ([]AuditChange, error) {
+	b, err := flattenAuditFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := flattenAuditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]struct{}{}
+	for f := range b {
+		fields[f] = struct{}{}
+	}
+	for f := range a {
+		fields[f] = struct{}{}
+	}
+
+	changes := []AuditChange{}
+	for f := range fields {
+		bv, bok := b[f]
+		av, aok := a[f]
+		if bok == aok && reflect.DeepEqual(bv, av) {
+			continue
+		}
+
+		name := f[strings.LastIndex(f, ".")+1:]
+		if name == "createdAt" || name == "updatedAt" {
+			continue
+		}
+
+		changes = append(changes, AuditChange{
+			Field:  f,
+			Before: redactAuditValue(name, bv),
+			After:  redactAuditValue(name, av),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes, nil
+}
+
+// redactAuditValue replaces key material in the value of the named field, including nested in arrays.
+func redactAuditValue(name string, v any) any {
+	if slices.Contains(auditRedactedFields, name) && v != nil && v != "" {
+		return auditRedacted
+	}
+
+	switch node := v.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(node))
+		for k, child := range node {
+			redacted[k] = redactAuditValue(k, child)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(node))
+		for i, child := range node {
+			redacted[i] = redactAuditValue("", child)
+		}
+		return redacted
+	}
+
+	return v
+}
+
+// flattenAuditFields maps the dotted JSON path of every leaf of v to its value. Arrays are leaves.
+func flattenAuditFields(v any) (map[string]any, error) {
+	fields := map[string]any{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree any
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return nil, err
+	}
+
+	var walk func(prefix string, node any)
+	walk = func(prefix string, node any) {
+		obj, ok := node.(map[string]any)
+		if !ok {
+			fields[prefix] = node
+			return
+		}
+
+		for k, child := range obj {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			walk(k, child)
+		}
+	}
+	walk("", tree)
+
+	return fields, nil
+}
+
+// Hooks
+func (e *AuditEvent) BeforeCreate(tx *gorm.DB) error {
+	e.ID = uuid.New()
+
+	return nil
+}
+
+// Audit events are append-only
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
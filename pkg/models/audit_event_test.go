@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func recordTestAuditEvents(t *testing.T, db *gorm.DB, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		e, err := NewAuditEvent(AuditActionCreate, AuditResourceHost, uuid.New(), nil, nil, map[string]any{"name": "host"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := RecordAuditEvent(db, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditChain(t *testing.T) {
+	db := newTestDB(t, &Network{}, &AuditEvent{})
+	recordTestAuditEvents(t, db, 3)
+
+	var events []AuditEvent
+	if err := db.Order("sequence").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	prev := ""
+	for i, e := range events {
+		if e.Sequence != uint64(i+1) || e.PrevHash != prev || e.Hash == "" {
+			t.Errorf("event %d has sequence %d and prevHash %q, want %d and %q", i, e.Sequence, e.PrevHash, i+1, prev)
+		}
+		prev = e.Hash
+	}
+
+	status, err := VerifyAuditChain(db)
+	if err != nil || !status.Valid || status.Events != 3 || status.BrokenAt != nil {
+		t.Errorf("VerifyAuditChain() = %+v, %v, want a valid chain of 3 events", status, err)
+	}
+}
+
+func TestAuditEventsAreImmutable(t *testing.T) {
+	db := newTestDB(t, &Network{}, &AuditEvent{})
+	recordTestAuditEvents(t, db, 1)
+
+	var e AuditEvent
+	if err := db.First(&e).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Model(&e).Update("action", AuditActionDelete).Error; !errors.Is(err, ErrAuditEventImmutable) {
+		t.Errorf("update returned %v, want ErrAuditEventImmutable", err)
+	}
+
+	if err := db.Delete(&e).Error; !errors.Is(err, ErrAuditEventImmutable) {
+		t.Errorf("delete returned %v, want ErrAuditEventImmutable", err)
+	}
+}
+
+func TestVerifyAuditChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+	}{
+		{"changed", "UPDATE audit_events SET actor_name = 'someone else' WHERE sequence = 2"},
+		{"removed", "DELETE FROM audit_events WHERE sequence = 2"},
+		{"unlinked", "UPDATE audit_events SET prev_hash = '' WHERE sequence = 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &Network{}, &AuditEvent{})
+			recordTestAuditEvents(t, db, 4)
+
+			// Raw SQL bypasses the hooks that keep the events immutable
+			if err := db.Exec(tt.tamper).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := VerifyAuditChain(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if status.Valid || status.BrokenAt == nil || *status.BrokenAt > 3 {
+				t.Errorf("VerifyAuditChain() = %+v, want the chain broken at event 2 or 3", status)
+			}
+		})
+	}
+}
+
+func TestAuditChangesRedactKeys(t *testing.T) {
+	before := map[string]any{"name": "ca", "key": "secret", "updatedAt": "then"}
+	after := map[string]any{"name": "ca-2", "key": "rotated", "updatedAt": "now", "cert": map[string]any{"hostKey": "k"}}
+
+	changes, err := diffAuditFields(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AuditChange{
+		{Field: "cert.hostKey", After: auditRedacted},
+		{Field: "key", Before: auditRedacted, After: auditRedacted},
+		{Field: "name", Before: "ca", After: "ca-2"},
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("got changes %+v, want %+v", changes, want)
+	}
+
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d is %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
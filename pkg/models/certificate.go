@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Certificate struct {
@@ -19,9 +20,61 @@ type Certificate struct {
 	IsCA       bool      `json:"isCa" gorm:"default:false"`
 	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
 	UpdatedAt  time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	ExpiryNotifiedAt *time.Time `json:"-"` // When the certificate.expiring webhook event was sent.
 }
 
 func (c Certificate) Expired() bool {
 	t := time.Now()
 	return c.NotBefore.After(t) || c.NotAfter.Before(t)
 }
+
+// RedactKeys clears the private key material of the certificate.
+func (c *Certificate) RedactKeys() {
+	c.Key = nil
+	c.Passphrase = ""
+}
+
+// NetworkID returns the network the certificate belongs to, as its CA or through its host.
+func (c *Certificate) NetworkID(tx *gorm.DB) (uuid.UUID, error) {
+	if c.OwnerType != "hosts" {
+		return c.OwnerID, nil
+	}
+
+	var h Host
+	if err := tx.Session(&gorm.Session{NewDB: true}).Select("id", "network_id").First(&h, "id = ?", c.OwnerID).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	return h.NetworkID, nil
+}
+
+// ExpiringCertificates returns the certificates that expire within window and were not notified about yet.
+func ExpiringCertificates(db *gorm.DB, window time.Duration) ([]Certificate, error) {
+	now := time.Now()
+
+	var certs []Certificate
+	err := db.Scopes(WithoutDeletedOwners).
+		Where("expiry_notified_at IS NULL AND not_after > ? AND not_after <= ?", now, now.Add(window)).
+		Order("not_after").
+		Find(&certs).Error
+
+	return certs, err
+}
+
+// MarkExpiryNotified records that the certificate.expiring event of the certificate was sent.
+// It reports false when another API server did so first.
+func (c *Certificate) MarkExpiryNotified(tx *gorm.DB) (bool, error) {
+	now := time.Now()
+
+	res := tx.Model(&Certificate{}).
+		Where("id = ? AND expiry_notified_at IS NULL", c.ID).
+		UpdateColumn("expiry_notified_at", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	c.ExpiryNotifiedAt = &now
+
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ConfigRevision identifies a rendered host config. Agents pass it back to only hear about newer configs.
+func ConfigRevision(yml string) string {
+	sum := sha256.Sum256([]byte(yml))
+	return hex.EncodeToString(sum[:16])
+}
+
+// StoreConfigRevision stores the revision of the rendered config of a host.
+func StoreConfigRevision(tx *gorm.DB, hostID uuid.UUID, revision string) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Host{}).
+		Where("id = ?", hostID).
+		UpdateColumn("config_revision", revision).Error
+}
+
+// TouchNetworkConfig records that the config of hosts in the network may have changed,
+// which wakes the agents watching them.
+func TouchNetworkConfig(tx *gorm.DB, networkID uuid.UUID) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Model(&Network{}).
+		Where("id = ?", networkID).
+		UpdateColumn("config_revision", gorm.Expr("config_revision + 1")).Error
+}
+
+// NetworkConfigRevisions returns the config revision counter of each of the networks that exist.
+func NetworkConfigRevisions(db *gorm.DB, networkIDs []uuid.UUID) (map[uuid.UUID]uint64, error) {
+	var networks []Network
+	if err := db.Select("id", "config_revision").Where("id IN ?", networkIDs).Find(&networks).Error; err != nil {
+		return nil, err
+	}
+
+	revisions := make(map[uuid.UUID]uint64, len(networks))
+	for _, n := range networks {
+		revisions[n.ID] = n.ConfigRevision
+	}
+
+	return revisions, nil
+}
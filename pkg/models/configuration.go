@@ -67,6 +67,7 @@ type Configuration struct {
 	ID        uuid.UUID `yaml:"-" json:"id" gorm:"type:uuid;primary_key;" swaggerignore:"true"`
 	HostID    uuid.UUID `yaml:"-" json:"-" gorm:"type:uuid"`
 	Host      *Host     `yaml:"-" json:"-"`
+	Version   uint64    `yaml:"-" json:"version" gorm:"not null;default:1" swaggerignore:"true"` // Incremented on every update, for optimistic concurrency.
 	CreatedAt time.Time `yaml:"-" json:"createdAt,omitempty" gorm:"autoCreateTime" swaggerignore:"true"`
 	UpdatedAt time.Time `yaml:"-" json:"updatedAt,omitempty" gorm:"autoUpdateTime" swaggerignore:"true"`
 }
@@ -0,0 +1,259 @@
+package models
+
+import (
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListFilter narrows a list query by the value of a query parameter.
+type ListFilter func(db *gorm.DB, value string) (*gorm.DB, error)
+
+// ListQuery is the allow-list of query parameters a list endpoint can be filtered and sorted by.
+// Only the parameters and sort fields listed here ever reach the database.
+type ListQuery struct {
+	Filters     map[string]ListFilter // Filters by query parameter. "name~" handles "?name~=value".
+	Sorts       map[string]string     // Sortable fields and the column they sort by.
+	DefaultSort string                // Sort used without ?sort, e.g. "-createdAt".
+}
+
+// HostQuery filters and sorts hosts.
+var HostQuery = ListQuery{
+	Filters: map[string]ListFilter{
+		"networkId":  uuidFilter("hosts.network_id"),
+		"name":       equalFilter("hosts.name"),
+		"name~":      containsFilter("hosts.name"),
+		"site":       equalFilter("hosts.site"),
+		"group":      jsonContainsFilter("hosts.groups"),
+		"ip":         hostIPFilter,
+		"lighthouse": configurationFilter("lighthouse_am_lighthouse"),
+		"relay":      configurationFilter("relay_am_relay"),
+		"deleted":    deletedFilter("hosts.deleted_at"),
+	},
+	Sorts: map[string]string{
+		"name":      "hosts.name",
+		"ip":        "hosts.ip",
+		"site":      "hosts.site",
+		"createdAt": "hosts.created_at",
+		"updatedAt": "hosts.updated_at",
+	},
+	DefaultSort: "createdAt",
+}
+
+// NetworkQuery filters and sorts networks.
+var NetworkQuery = ListQuery{
+	Filters: map[string]ListFilter{
+		"organizationId": uuidFilter("networks.organization_id"),
+		"name":           equalFilter("networks.name"),
+		"name~":          containsFilter("networks.name"),
+		"group":          jsonContainsFilter("networks.groups"),
+		"curve":          equalFilter("networks.curve"),
+		"deleted":        deletedFilter("networks.deleted_at"),
+	},
+	Sorts: map[string]string{
+		"name":      "networks.name",
+		"createdAt": "networks.created_at",
+		"updatedAt": "networks.updated_at",
+	},
+	DefaultSort: "createdAt",
+}
+
+// CertificateQuery filters and sorts certificates.
+var CertificateQuery = ListQuery{
+	Filters: map[string]ListFilter{
+		"ownerId":       uuidFilter("certificates.owner_id"),
+		"ownerType":     equalFilter("certificates.owner_type"),
+		"isCa":          boolFilter("certificates.is_ca"),
+		"expired":       expiredFilter,
+		"expiresBefore": timeFilter("certificates.not_after <= ?"),
+	},
+	Sorts: map[string]string{
+		"notBefore": "certificates.not_before",
+		"notAfter":  "certificates.not_after",
+		"createdAt": "certificates.created_at",
+	},
+	DefaultSort: "createdAt",
+}
+
+// Filter applies the filters of q whose query parameters are set. Invalid values fail the query with a ValidationError.
+func Filter(c ListParams, q ListQuery) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for param, filter := range q.Filters {
+			value, ok := c.GetQuery(param)
+			if !ok {
+				continue
+			}
+
+			filtered, err := filter(db, value)
+			if err != nil {
+				db.AddError(NewValidationError("invalid " + param + " filter: " + err.Error()))
+				return db
+			}
+			db = filtered
+		}
+
+		return db
+	}
+}
+
+// Sort orders the query by ?sort, a comma-separated list of fields of q. A leading "-" sorts descending.
+func Sort(c ListParams, q ListQuery) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		sort := defaultQuery(c, "sort", q.DefaultSort)
+		if sort == "" {
+			return db
+		}
+
+		for _, field := range strings.Split(sort, ",") {
+			field = strings.TrimSpace(field)
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+
+			column, ok := q.Sorts[field]
+			if !ok {
+				db.AddError(NewValidationError("invalid sort field: " + field))
+				return db
+			}
+
+			if desc {
+				column += " DESC"
+			}
+			db = db.Order(column)
+		}
+
+		return db
+	}
+}
+
+func equalFilter(column string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		return db.Where(column+" = ?", value), nil
+	}
+}
+
+func containsFilter(column string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		return db.Where("LOWER("+column+") LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(value))+"%"), nil
+	}
+}
+
+// jsonContainsFilter matches JSON string arrays stored as text that contain the value.
+func jsonContainsFilter(column string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		quoted, _ := json.Marshal(value)
+		return db.Where(column+" LIKE ? ESCAPE '\\'", "%"+escapeLike(string(quoted))+"%"), nil
+	}
+}
+
+func uuidFilter(column string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return db.Where(column+" = ?", id), nil
+	}
+}
+
+func boolFilter(column string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return db.Where(column+" = ?", b), nil
+	}
+}
+
+func timeFilter(cond string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return db.Where(cond, t), nil
+	}
+}
+
+// deletedFilter lists the deleted rows instead of the others when true.
+func deletedFilter(column string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		deleted, err := strconv.ParseBool(value)
+		if err != nil || !deleted {
+			return db, err
+		}
+
+		return db.Unscoped().Where(column + " IS NOT NULL"), nil
+	}
+}
+
+// configurationFilter matches hosts by a boolean column of their configuration.
+func configurationFilter(column string) ListFilter {
+	return func(db *gorm.DB, value string) (*gorm.DB, error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+
+		configs := db.Session(&gorm.Session{NewDB: true}).Model(&Configuration{}).Select("id").Where(column+" = ?", b)
+		return db.Where("hosts.configuration_id IN (?)", configs), nil
+	}
+}
+
+func expiredFilter(db *gorm.DB, value string) (*gorm.DB, error) {
+	expired, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if expired {
+		return db.Where("certificates.not_after < ?", time.Now()), nil
+	}
+
+	return db.Where("certificates.not_after >= ?", time.Now()), nil
+}
+
+// hostIPFilter matches hosts by overlay IP. A CIDR matches every host IP inside it.
+func hostIPFilter(db *gorm.DB, value string) (*gorm.DB, error) {
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return db.Where("hosts.ip = ? OR hosts.ip LIKE ?", addr.String(), addr.String()+"/%"), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Host IPs are IPv4, and stored as numbers too, so a prefix is a range of them
+	if !prefix.Addr().Is4() {
+		return db.Where("1 = 0"), nil
+	}
+
+	first := ipv4Number(prefix.Masked().Addr())
+	last := first + int64(1)<<(32-prefix.Bits()) - 1
+
+	return db.Where("hosts.ip_number BETWEEN ? AND ?", first, last), nil
+}
+
+// ipv4Number returns addr as a number.
+func ipv4Number(addr netip.Addr) int64 {
+	b := addr.As4()
+	return int64(b[0])<<24 | int64(b[1])<<16 | int64(b[2])<<8 | int64(b[3])
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
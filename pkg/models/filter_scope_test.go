@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// testParams are the query parameters of a list request, and the page metadata set by the scopes.
+type testParams struct {
+	query map[string]string
+	meta  map[string]any
+}
+
+func newTestParams(query map[string]string) *testParams {
+	return &testParams{query: query, meta: map[string]any{}}
+}
+
+func (p *testParams) GetQuery(key string) (string, bool) {
+	value, ok := p.query[key]
+	return value, ok
+}
+
+func (p *testParams) Set(key string, value any) {
+	p.meta[key] = value
+}
+
+// createTestHosts inserts hosts without their hooks, which would sign certificates with the CA of their network.
+func createTestHosts(t *testing.T, db *gorm.DB, hosts ...Host) {
+	t.Helper()
+
+	for i := range hosts {
+		if hosts[i].ID == uuid.Nil {
+			hosts[i].ID = uuid.New()
+		}
+		hosts[i].IPNumber = hostIPNumber(hosts[i].IP)
+
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Omit("Configuration", "Certificate").Create(&hosts[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func hostNames(hosts []Host) []string {
+	names := make([]string, len(hosts))
+	for i, h := range hosts {
+		names[i] = h.Name
+	}
+
+	return names
+}
+
+func TestFilterAndSortHosts(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+	networkID := uuid.New()
+
+	createTestHosts(t, db,
+		Host{NetworkID: networkID, Name: "web-1", IP: "100.100.0.1/16", Site: "eu-west", Groups: []string{"web", "ssh"}},
+		Host{NetworkID: networkID, Name: "web-2", IP: "100.100.1.1/16", Site: "us-east", Groups: []string{"web"}},
+		Host{NetworkID: networkID, Name: "DB_1", IP: "100.100.0.2/16", Site: "eu-west", Groups: []string{"webdb"}},
+		Host{NetworkID: uuid.New(), Name: "other", IP: "100.100.0.3/16"},
+	)
+
+	tests := []struct {
+		query map[string]string
+		want  string
+	}{
+		{map[string]string{"networkId": networkID.String(), "sort": "name"}, "[DB_1 web-1 web-2]"},
+		{map[string]string{"name": "web-1"}, "[web-1]"},
+		{map[string]string{"name~": "WEB", "sort": "-name"}, "[web-2 web-1]"},
+		{map[string]string{"name~": "_"}, "[DB_1]"},
+		{map[string]string{"group": "web", "sort": "name"}, "[web-1 web-2]"},
+		{map[string]string{"site": "eu-west", "sort": "-site,name"}, "[DB_1 web-1]"},
+		{map[string]string{"ip": "100.100.0.2"}, "[DB_1]"},
+		{map[string]string{"ip": "100.100.0.0/24", "networkId": networkID.String(), "sort": "ip"}, "[web-1 DB_1]"},
+		{map[string]string{"ip": "10.0.0.0/8"}, "[]"},
+		{map[string]string{"ip": "100.100.1.0/32"}, "[]"},
+		{map[string]string{"ip": "100.100.0.3/31", "sort": "name"}, "[DB_1 other]"},
+		{map[string]string{"ip": "100.100.0.0/16", "networkId": networkID.String(), "sort": "-name"}, "[web-2 web-1 DB_1]"},
+		{map[string]string{"ip": "::/0"}, "[]"},
+		{map[string]string{"unknown": "ignored", "name": "other"}, "[other]"},
+	}
+
+	for _, tt := range tests {
+		params := newTestParams(tt.query)
+
+		var hosts []Host
+		err := db.Model(&Host{}).Scopes(Filter(params, HostQuery), Sort(params, HostQuery)).Find(&hosts).Error
+		if err != nil {
+			t.Errorf("%v: %v", tt.query, err)
+			continue
+		}
+
+		if got := fmt.Sprint(hostNames(hosts)); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFilterAndSortRejectInvalidValues(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+
+	tests := []map[string]string{
+		{"networkId": "not-a-uuid"},
+		{"ip": "100.100.0.0/33"},
+		{"lighthouse": "maybe"},
+		{"deleted": "yes please"},
+		{"sort": "passphrase"},
+		{"sort": "name,-ssh_host_key"},
+	}
+
+	for _, query := range tests {
+		params := newTestParams(query)
+
+		var hosts []Host
+		err := db.Model(&Host{}).Scopes(Filter(params, HostQuery), Sort(params, HostQuery)).Find(&hosts).Error
+
+		var invalid ValidationError
+		if !errors.As(err, &invalid) {
+			t.Errorf("%v: got %v, want a ValidationError", query, err)
+		}
+	}
+}
+
+func TestDeletedFilter(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+
+	createTestHosts(t, db, Host{Name: "kept", IP: "100.100.0.1/16"}, Host{Name: "deleted", IP: "100.100.0.2/16"})
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Delete(&Host{}, "name = ?", "deleted").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, deleted := range []bool{false, true} {
+		params := newTestParams(map[string]string{"deleted": strconv.FormatBool(deleted), "ip": "100.100.0.0/30"})
+
+		var hosts []Host
+		if err := db.Model(&Host{}).Scopes(Filter(params, HostQuery)).Find(&hosts).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		want := "[kept]"
+		if deleted {
+			want = "[deleted]"
+		}
+
+		if got := fmt.Sprint(hostNames(hosts)); got != want {
+			t.Errorf("deleted=%v: got %s, want %s", deleted, got, want)
+		}
+	}
+}
+
+func TestBackfillHostIPNumbers(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+
+	createTestHosts(t, db, Host{Name: "a", IP: "100.100.0.1/16"}, Host{Name: "b", IP: "100.100.1.1/16"})
+	if err := db.Model(&Host{}).Where("1 = 1").UpdateColumn("ip_number", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := BackfillHostIPNumbers(db); err != nil {
+		t.Fatal(err)
+	}
+
+	params := newTestParams(map[string]string{"ip": "100.100.1.0/24"})
+
+	var hosts []Host
+	if err := db.Model(&Host{}).Scopes(Filter(params, HostQuery)).Find(&hosts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(hostNames(hosts)); got != "[b]" {
+		t.Errorf("got %s after the backfill, want [b]", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/netip"
 	"strings"
 	"time"
 
@@ -13,39 +14,77 @@ import (
 )
 
 type Host struct {
-	ID              uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;"`
-	Name            string         `json:"name" gorm:"size:255;not null;uniqueIndex:idx_name_network"`
-	IP              string         `json:"ip" gorm:"size:255;not null;uniqueIndex:idx_ip_network"`
-	StaticAddresses []string       `json:"staticAddresses" gorm:"serializer:json;default:'[]'"`
-	Subnets         []string       `json:"subnets" gorm:"serializer:json;default:'[]'"`
-	Groups          []string       `json:"groups" gorm:"serializer:json;default:'[]'"`
-	InPub           []byte         `json:"inPub,omitempty" swaggertype:"string"`
-	NetworkID       uuid.UUID      `json:"networkId" gorm:"type:uuid"`
-	Network         *Network       `json:"network,omitempty"`
-	ConfigurationID uuid.UUID      `json:"configurationId" gorm:"type:uuid"`
-	Configuration   *Configuration `json:"configuration,omitempty" gorm:"foreignKey:ConfigurationID;constraint:OnDelete:CASCADE"`
-	Certificate     *Certificate   `json:"certificate,omitempty" gorm:"polymorphic:Owner;constraint:OnDelete:CASCADE"`
-	CreatedAt       time.Time      `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	ID              uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;"`
+	Name            string          `json:"name" gorm:"size:255;not null;uniqueIndex:idx_hosts_network_name,where:deleted_at IS NULL"`
+	IP              string          `json:"ip" gorm:"size:255;not null;uniqueIndex:idx_hosts_network_ip,where:deleted_at IS NULL"`
+	IPNumber        *int64          `json:"-" gorm:"index"` // Overlay IPv4 address as a number, for CIDR filters.
+	StaticAddresses []StaticAddress `json:"staticAddresses" gorm:"serializer:json;default:'[]'"`
+	Subnets         []string        `json:"subnets" gorm:"serializer:json;default:'[]'"`
+	Groups          []string        `json:"groups" gorm:"serializer:json;default:'[]'"`
+	Site            string          `json:"site" gorm:"size:255;index"`                      // Site or region the host belongs to, used by "site:<name>" selectors.
+	Lighthouses     []string        `json:"lighthouses" gorm:"serializer:json;default:'[]'"` // Lighthouses this host uses, by host name or "site:<name>". Empty means all.
+	Relays          []string        `json:"relays" gorm:"serializer:json;default:'[]'"`      // Relays peers may use to reach this host, by host name or "site:<name>". Empty means all.
+	InPub           []byte          `json:"inPub,omitempty" swaggertype:"string"`
+	SSHHostKey      []byte          `json:"-"`          // ed25519 private key of the nebula sshd, OpenSSH PEM encoded.
+	SSHHostPub      string          `json:"sshHostPub"` // Public sshd host key in authorized_keys format, for known_hosts.
+	NetworkID       uuid.UUID       `json:"networkId" gorm:"type:uuid;uniqueIndex:idx_hosts_network_name,where:deleted_at IS NULL;uniqueIndex:idx_hosts_network_ip,where:deleted_at IS NULL"`
+	Network         *Network        `json:"network,omitempty"`
+	ConfigurationID uuid.UUID       `json:"configurationId" gorm:"type:uuid"`
+	Configuration   *Configuration  `json:"configuration,omitempty" gorm:"foreignKey:ConfigurationID;constraint:OnDelete:CASCADE"`
+	Certificate     *Certificate    `json:"certificate,omitempty" gorm:"polymorphic:Owner;constraint:OnDelete:CASCADE"`
+	Version         uint64          `json:"version" gorm:"not null;default:1"` // Incremented on every update, for optimistic concurrency.
+	ConfigRevision  string          `json:"-" gorm:"size:32"`                  // Revision of the rendered config, stored whenever it changes, for watching agents.
+	CreatedAt       time.Time       `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time       `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt  `json:"deletedAt,omitempty" gorm:"index" swaggertype:"string"` // Set while the host is deleted, until it is restored or purged.
 }
 
 type HostDto struct {
-	Name            string         `json:"name,omitempty" example:"host-1"`
-	IP              string         `json:"ip,omitempty" example:"100.100.0.1/24"`
-	InPub           string         `json:"inPub,omitempty"`
-	StaticAddresses []string       `json:"staticAddresses,omitempty" example:"109.243.69.39"`
-	Subnets         []string       `json:"subnets,omitempty" example:"192.168.1.0/24"`
-	Groups          []string       `json:"groups,omitempty" example:"laptop,servers,ssh"`
-	NetworkID       uuid.UUID      `json:"networkId,omitempty" example:"c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"`
-	Configuration   *Configuration `json:"configuration,omitempty"`
+	Name            string          `json:"name,omitempty" example:"host-1"`
+	IP              string          `json:"ip,omitempty" example:"100.100.0.1/24"`
+	InPub           string          `json:"inPub,omitempty"`
+	StaticAddresses []StaticAddress `json:"staticAddresses,omitempty"`
+	Subnets         []string        `json:"subnets,omitempty" example:"192.168.1.0/24"`
+	Groups          []string        `json:"groups,omitempty" example:"laptop,servers,ssh"`
+	Site            string          `json:"site,omitempty" example:"eu-west"`
+	Lighthouses     []string        `json:"lighthouses,omitempty" example:"lighthouse-1,site:eu-west"`
+	Relays          []string        `json:"relays,omitempty" example:"relay-1,site:eu-west"`
+	NetworkID       uuid.UUID       `json:"networkId,omitempty" example:"c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"`
+	Configuration   *Configuration  `json:"configuration,omitempty"`
 }
 
 func (h *Host) GetIp() string {
 	return strings.Split(h.IP, "/")[0]
 }
 
+func (h Host) PageCursor() Cursor {
+	return Cursor{CreatedAt: h.CreatedAt, ID: h.ID}
+}
+
+// RedactKeys clears the private key material of the host's certificate.
+// The certificate is copied first, as hosts share it with the caller.
+func (h *Host) RedactKeys() {
+	if h.Certificate != nil {
+		crt := *h.Certificate
+		crt.RedactKeys()
+		h.Certificate = &crt
+	}
+}
+
 func (h *Host) BeforeCreate(db *gorm.DB) error {
-	h.ID = uuid.New()
+	// Imported hosts keep their IDs
+	if !keepIDs(db) || h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+
+	// BeforeSave reports invalid hosts, don't sign a certificate for them
+	if h.validate() != nil {
+		return nil
+	}
+
+	if err := checkHostQuota(db, h.NetworkID); err != nil {
+		return err
+	}
 
 	// Sign host
 	if h.Certificate == nil {
@@ -59,7 +98,56 @@ func (h *Host) BeforeCreate(db *gorm.DB) error {
 		h.Configuration = newConfig()
 	}
 
-	h.Configuration.ID = uuid.New()
+	if !keepIDs(db) || h.Configuration.ID == uuid.Nil {
+		h.Configuration.ID = uuid.New()
+	}
+
+	// Generate the nebula sshd host key
+	if len(h.SSHHostKey) == 0 {
+		if err := h.GenerateSSHHostKey(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Host) BeforeSave(tx *gorm.DB) error {
+	if err := h.validate(); err != nil {
+		return err
+	}
+
+	h.IPNumber = hostIPNumber(h.IP)
+
+	return nil
+}
+
+// hostIPNumber returns the IPv4 address of ip, with or without its prefix length, as a number.
+// It is nil for anything else.
+func hostIPNumber(ip string) *int64 {
+	addr, err := netip.ParseAddr(strings.Split(ip, "/")[0])
+	if err != nil || !addr.Is4() {
+		return nil
+	}
+
+	n := ipv4Number(addr)
+	return &n
+}
+
+// BackfillHostIPNumbers stores the IP number of the hosts that were saved before hosts had one.
+func BackfillHostIPNumbers(db *gorm.DB) error {
+	var hosts []Host
+	if err := db.Unscoped().Select("id", "ip").Where("ip_number IS NULL").Find(&hosts).Error; err != nil {
+		return err
+	}
+
+	for _, h := range hosts {
+		if n := hostIPNumber(h.IP); n != nil {
+			if err := db.Unscoped().Model(&Host{}).Where("id = ?", h.ID).UpdateColumn("ip_number", *n).Error; err != nil {
+				return err
+			}
+		}
+	}
 
 	return nil
 }
@@ -86,17 +174,23 @@ func (h *Host) Marshal(yml bool) (string, error) {
 	cfg.PKI.Cert = string(h.Certificate.Crt)
 	cfg.PKI.Key = string(h.Certificate.Key)
 
+	// SSH debug access
+	cfg.SSHD.AuthorizedUsers = mergeAuthorizedUsers(cfg.SSHD.AuthorizedUsers, h.Network.SSHUsers)
+	if cfg.SSHD.HostKey == "" {
+		cfg.SSHD.HostKey = string(h.SSHHostKey)
+	}
+
 	// Static host-map
-	cfg.StaticHostMap = h.Network.StaticHostMap()
+	cfg.StaticHostMap = h.Network.StaticHostMap(h)
 
 	// config lighthouse hosts
 	if !cfg.Lighthouse.AmLighthouse {
-		cfg.Lighthouse.Hosts = h.Network.Lighthouses()
+		cfg.Lighthouse.Hosts = h.Network.Lighthouses(h)
 	}
 
 	// Config relays
 	if !cfg.Relay.AmRelay && cfg.Relay.UseRelays {
-		cfg.Relay.Relays = h.Network.Relays()
+		cfg.Relay.Relays = h.Network.Relays(h)
 	}
 
 	var (
@@ -120,7 +214,8 @@ func (h *Host) Marshal(yml bool) (string, error) {
 func (h *Host) Sign(db *gorm.DB) error {
 	// Attempt to find the network
 	var n Network
-	if err := db.Preload("Ca").First(&n, "id = ?", h.NetworkID).Error; err != nil {
+	// The newest CA signs, CAs that were rotated out stay trusted until they expire
+	if err := db.Preload("Ca", func(db *gorm.DB) *gorm.DB { return db.Order("not_before") }).First(&n, "id = ?", h.NetworkID).Error; err != nil {
 		return errors.New("host network not found")
 	}
 
@@ -0,0 +1,39 @@
+package models
+
+import "strings"
+
+// siteSelectorPrefix marks a lighthouse/relay selector that matches every host of a site, e.g. "site:eu-west".
+const siteSelectorPrefix = "site:"
+
+// usesLighthouse reports whether the lighthouse peer is assigned to h.
+func (h *Host) usesLighthouse(peer *Host) bool {
+	return matchesSelectors(peer, h.Lighthouses)
+}
+
+// usesRelay reports whether peers may reach h through the relay peer.
+func (h *Host) usesRelay(peer *Host) bool {
+	return matchesSelectors(peer, h.Relays)
+}
+
+// matchesSelectors matches a host against a list of host names and "site:<name>" selectors.
+// An empty list matches every host.
+func matchesSelectors(peer *Host, selectors []string) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+
+	for _, selector := range selectors {
+		if site, ok := strings.CutPrefix(selector, siteSelectorPrefix); ok {
+			if peer.Site != "" && peer.Site == site {
+				return true
+			}
+			continue
+		}
+
+		if selector == peer.Name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package models
+
+import "testing"
+
+func TestMatchesSelectors(t *testing.T) {
+	eu := &Host{Name: "lh-eu", Site: "eu-west"}
+	us := &Host{Name: "lh-us", Site: "us-east"}
+	unsited := &Host{Name: "lh-any"}
+
+	tests := []struct {
+		name      string
+		selectors []string
+		peer      *Host
+		want      bool
+	}{
+		{"empty matches every host", nil, unsited, true},
+		{"name", []string{"lh-us"}, us, true},
+		{"other name", []string{"lh-us"}, eu, false},
+		{"site", []string{"site:eu-west"}, eu, true},
+		{"other site", []string{"site:eu-west"}, us, false},
+		{"site does not match hosts without one", []string{"site:"}, unsited, false},
+		{"site name is not a host name", []string{"eu-west"}, eu, false},
+		{"any of the selectors", []string{"site:us-east", "lh-eu"}, eu, true},
+	}
+
+	for _, tt := range tests {
+		if got := matchesSelectors(tt.peer, tt.selectors); got != tt.want {
+			t.Errorf("%s: matchesSelectors(%s, %v) = %v, want %v", tt.name, tt.peer.Name, tt.selectors, got, tt.want)
+		}
+	}
+}
+
+func TestHostUsesLighthouseAndRelay(t *testing.T) {
+	lighthouse := &Host{Name: "lh-1", Site: "eu-west"}
+	relay := &Host{Name: "relay-1", Site: "us-east"}
+	h := &Host{Name: "web", Lighthouses: []string{"site:eu-west"}, Relays: []string{"relay-2"}}
+
+	if !h.usesLighthouse(lighthouse) {
+		t.Error("lighthouse of the selected site is not used")
+	}
+
+	if h.usesRelay(relay) {
+		t.Error("relay that is not selected is used")
+	}
+}
+
+func TestValidateSelectors(t *testing.T) {
+	tests := []struct {
+		host      Host
+		wantField string
+	}{
+		{host: Host{Lighthouses: []string{"lh-1", "site:eu-west"}, Relays: []string{"site:us-east"}}},
+		{host: Host{Lighthouses: []string{"lh-1", " "}}, wantField: "lighthouses[1]"},
+		{host: Host{Relays: []string{"site:"}}, wantField: "relays[0]"},
+	}
+
+	for _, tt := range tests {
+		err := tt.host.validate()
+		if tt.wantField == "" {
+			if err != nil {
+				t.Errorf("validate() of %v / %v = %v, want nil", tt.host.Lighthouses, tt.host.Relays, err)
+			}
+			continue
+		}
+
+		invalid, ok := err.(ValidationError)
+		if !ok || invalid.Field != tt.wantField {
+			t.Errorf("validate() of %v / %v = %v, want an error of field %s", tt.host.Lighthouses, tt.host.Relays, err, tt.wantField)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -24,3 +25,44 @@ func PreloadHostWithFullDetails(id string) func(db *gorm.DB) *gorm.DB {
 			Where("hosts.id = ?", id)
 	}
 }
+
+// NetworkHostsWithFullDetails returns every host of the network with the details PreloadHostWithFullDetails
+// loads, for rendering all of their configs. The network with its CAs, lighthouses and relays is loaded
+// once for all of them.
+func NetworkHostsWithFullDetails(db *gorm.DB, networkID uuid.UUID) ([]Host, error) {
+	var hosts []Host
+	if err := db.Preload("Configuration").Preload("Certificate").Where("network_id = ?", networkID).Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return hosts, nil
+	}
+
+	var n Network
+	err := db.Preload("Ca").
+		Preload("Hosts", func(db *gorm.DB) *gorm.DB {
+			return db.Preload("Configuration").
+				Joins("JOIN configurations ON configurations.id = hosts.configuration_id").
+				Where("configurations.lighthouse_am_lighthouse = ? OR configurations.relay_am_relay = ?", true, true)
+		}).
+		First(&n, "id = ?", networkID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Each host gets the network without itself among the lighthouses and relays
+	for i := range hosts {
+		network := n
+		network.Hosts = make([]Host, 0, len(n.Hosts))
+		for _, peer := range n.Hosts {
+			if peer.ID != hosts[i].ID {
+				network.Hosts = append(network.Hosts, peer)
+			}
+		}
+
+		hosts[i].Network = &network
+	}
+
+	return hosts, nil
+}
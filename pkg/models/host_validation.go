@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validators
+func (h *Host) validate() error {
+	if err := h.validateStaticAddresses(); err != nil {
+		return err
+	}
+
+	if err := validateSelectors("lighthouse", h.Lighthouses); err != nil {
+		return withinField(err, "lighthouses")
+	}
+
+	if err := validateSelectors("relay", h.Relays); err != nil {
+		return withinField(err, "relays")
+	}
+
+	return nil
+}
+
+func (h *Host) validateStaticAddresses() error {
+	for i := range h.StaticAddresses {
+		if err := h.StaticAddresses[i].validate(); err != nil {
+			return withinField(err, fmt.Sprintf("staticAddresses[%d]", i))
+		}
+	}
+	return nil
+}
+
+func validateSelectors(kind string, selectors []string) error {
+	for i, selector := range selectors {
+		selector = strings.TrimSpace(selector)
+		if selector == "" || selector == siteSelectorPrefix {
+			return NewFieldError(fmt.Sprintf("[%d]", i), kind+" selectors cannot be empty")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyLease is how long a key stays claimed by a request that has not completed. Keys of requests
+// that never complete, e.g. when the API server stops, can be used again once it ends.
+const IdempotencyLease = 2 * time.Minute
+
+var (
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused     = errors.New("the idempotency key was used for a different request")
+)
+
+// IdempotencyKey stores the first response to a request made with an Idempotency-Key header,
+// so that retries of the request get the same response instead of repeating it.
+// Keys are scoped to the API token and route. StatusCode is 0 while the first request runs, and ExpiresAt
+// is the end of its lease until then.
+type IdempotencyKey struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;"`
+	Scope       string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_scope_key"` // API token, method and route of the request.
+	Key         string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_scope_key"`
+	RequestHash string    `gorm:"size:64;not null"` // SHA-256 of the request body, to detect keys reused for other payloads.
+	StatusCode  int
+	ContentType string `gorm:"size:255"`
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+// Completed reports whether the response of the first request was stored.
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
+
+// ClaimIdempotencyKey records that a request with the key has started, for the lease. When the key was used
+// before, the earlier record is returned instead, together with ErrIdempotencyKeyInProgress or
+// ErrIdempotencyKeyReused when it cannot be replayed for this request.
+func ClaimIdempotencyKey(db *gorm.DB, scope, key, requestHash string, lease time.Duration) (*IdempotencyKey, bool, error) {
+	now := time.Now()
+
+	// Expired keys and leases can be reused
+	if err := db.Where("expires_at < ?", now).Delete(&IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	claim := IdempotencyKey{Scope: scope, Key: key, RequestHash: requestHash, ExpiresAt: now.Add(lease)}
+	if err := db.Create(&claim).Error; err == nil {
+		return &claim, true, nil
+	}
+
+	var existing IdempotencyKey
+	if err := db.First(&existing, "scope = ? AND key = ?", scope, key).Error; err != nil {
+		return nil, false, err
+	}
+
+	if existing.RequestHash != requestHash {
+		return &existing, false, ErrIdempotencyKeyReused
+	}
+
+	if !existing.Completed() {
+		return &existing, false, ErrIdempotencyKeyInProgress
+	}
+
+	return &existing, false, nil
+}
+
+// Complete stores the response of the request that claimed the key, to be replayed for the TTL.
+// Private keys and passphrases are left out of JSON responses, see RedactResponseKeys.
+func (k *IdempotencyKey) Complete(db *gorm.DB, statusCode int, contentType string, body []byte, ttl time.Duration) error {
+	return db.Model(k).Updates(IdempotencyKey{
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        RedactResponseKeys(body),
+		ExpiresAt:   time.Now().Add(ttl),
+	}).Error
+}
+
+// RedactResponseKeys clears the private keys and passphrases of the certificates and networks in a JSON
+// response, as their RedactKeys methods do. Certificates are the objects with a "crt" field. Bodies without
+// key material, and bodies that are not JSON, are returned as they are.
+func RedactResponseKeys(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil || !redactResponseValue(v) {
+		return body
+	}
+
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return redacted
+}
+
+// redactResponseValue redacts the key material nested in v, and reports whether there was any.
+func redactResponseValue(v any) bool {
+	redacted := false
+
+	switch node := v.(type) {
+	case map[string]any:
+		if _, isCert := node["crt"]; isCert && node["key"] != nil {
+			node["key"] = nil
+			redacted = true
+		}
+
+		if passphrase, _ := node["passphrase"].(string); passphrase != "" {
+			node["passphrase"] = ""
+			redacted = true
+		}
+
+		for _, child := range node {
+			redacted = redactResponseValue(child) || redacted
+		}
+	case []any:
+		for _, child := range node {
+			redacted = redactResponseValue(child) || redacted
+		}
+	}
+
+	return redacted
+}
+
+// Hooks
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	k.ID = uuid.New()
+
+	return nil
+}
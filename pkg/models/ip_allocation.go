@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrNetworkFull = errors.New("no free IP address left in the network")
+
+// UsedHostIPs returns the overlay IPs of the hosts of a network. The IPs of deleted hosts count as used,
+// so that they are not handed out again while the hosts can still be restored.
+func UsedHostIPs(tx *gorm.DB, networkID uuid.UUID) (map[netip.Addr]bool, error) {
+	var ips []string
+	if err := tx.Unscoped().Model(&Host{}).Where("network_id = ?", networkID).Pluck("ip", &ips).Error; err != nil {
+		return nil, err
+	}
+
+	used := map[netip.Addr]bool{}
+	for _, ip := range ips {
+		if prefix, err := netip.ParsePrefix(ip); err == nil {
+			used[prefix.Addr()] = true
+		} else if addr, err := netip.ParseAddr(ip); err == nil {
+			used[addr] = true
+		}
+	}
+
+	return used, nil
+}
+
+// AllocateIP returns the first address of the network's IP ranges that is not in used, in CIDR
+// notation with the prefix length of its range, and marks it as used. The network and broadcast
+// addresses of each range are skipped.
+func (n *Network) AllocateIP(used map[netip.Addr]bool) (string, error) {
+	for _, r := range n.IPs {
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil || !prefix.Addr().Is4() {
+			continue
+		}
+
+		prefix = prefix.Masked()
+		for addr := prefix.Addr().Next(); prefix.Contains(addr); addr = addr.Next() {
+			if !prefix.Contains(addr.Next()) {
+				break // broadcast
+			}
+
+			if used[addr] {
+				continue
+			}
+
+			used[addr] = true
+			return netip.PrefixFrom(addr, prefix.Bits()).String(), nil
+		}
+	}
+
+	return "", ErrNetworkFull
+}
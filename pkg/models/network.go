@@ -1,7 +1,6 @@
 package models
 
 import (
-	"fmt"
 	"strings"
 	"time"
 
@@ -12,37 +11,45 @@ import (
 
 // Model
 type Network struct {
-	ID               uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;"`                                  // Unique identifier for the network (UUID).
-	Name             string        `json:"name" gorm:"size:255;uniqueIndex:idx_name_cidr"`                    // Name of the network, must be unique in combination with the CIDR.
-	IPs              []string      `json:"ips" gorm:"serializer:json;default:'[]'"`                           // List of IPv4 addresses and networks in CIDR notation. Limits the addresses for subordinate certificates.
-	Subnets          []string      `json:"subnets" gorm:"serializer:json;default:'[]'"`                       // List of IPv4 subnets in CIDR notation. Defines subnets that subordinate certificates can use.
-	Groups           []string      `json:"groups" gorm:"serializer:json;default:'[]'"`                        // List of groups for access control, restricting subordinate certificates' groups.
-	Encrypt          bool          `json:"encrypt" gorm:"default:false"`                                      // Enables passphrase encryption for private keys. Default: true.
-	Passphrase       string        `json:"passphrase" gorm:"size:255"`                                        // Passphrase used for encrypting the private key.
-	ArgonMemory      uint          `json:"argonMemory" gorm:"default:2097152"`                                // Argon2 memory parameter in KiB for encrypted private key passphrase. Default: 2 MiB. (2*1024*1024)
-	ArgonIterations  uint          `json:"argonIterations" gorm:"default:2"`                                  // Number of Argon2 iterations for encrypting private key passphrase. Default: 2.
-	ArgonParallelism uint          `json:"argonParallelism" gorm:"default:4"`                                 // Argon2 parallelism parameter for encrypting private key passphrase. Default: 4.
-	Curve            string        `json:"curve" gorm:"default:25519"`                                        // Cryptographic curve for key generation. Options include "25519" (default) and "P256".
-	Duration         time.Duration `json:"duration" gorm:"default:17531" swaggertype:"number"`                // Certificate validity duration. Default: 2 years (17,531 hours). (time.Duration(time.Hour*8760))
-	Ca               []Certificate `json:"ca,omitempty" gorm:"polymorphic:Owner;constraint:OnDelete:CASCADE"` // Associated Certificate Authorities (CA) for the network.
-	Hosts            []Host        `json:"hosts,omitempty" gorm:"constraint:OnDelete:CASCADE"`                // Associated hosts for the network.
-	CreatedAt        time.Time     `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt        time.Time     `json:"updatedAt" gorm:"autoUpdateTime"`
+	ID               uuid.UUID              `json:"id" gorm:"type:uuid;primary_key;"`                                                                     // Unique identifier for the network (UUID).
+	OrganizationID   *uuid.UUID             `json:"organizationId,omitempty" gorm:"type:uuid;uniqueIndex:idx_networks_org_name,where:deleted_at IS NULL"` // Organization owning the network.
+	Organization     *Organization          `json:"organization,omitempty"`
+	Name             string                 `json:"name" gorm:"size:255;uniqueIndex:idx_networks_org_name,where:deleted_at IS NULL;uniqueIndex:idx_networks_name,where:organization_id IS NULL AND deleted_at IS NULL"` // Name of the network, must be unique within the organization.
+	IPs              []string               `json:"ips" gorm:"serializer:json;default:'[]'"`                                                                                                                            // List of IPv4 addresses and networks in CIDR notation. Limits the addresses for subordinate certificates.
+	Subnets          []string               `json:"subnets" gorm:"serializer:json;default:'[]'"`                                                                                                                        // List of IPv4 subnets in CIDR notation. Defines subnets that subordinate certificates can use.
+	Groups           []string               `json:"groups" gorm:"serializer:json;default:'[]'"`                                                                                                                         // List of groups for access control, restricting subordinate certificates' groups.
+	Encrypt          bool                   `json:"encrypt" gorm:"default:false"`                                                                                                                                       // Enables passphrase encryption for private keys. Default: true.
+	Passphrase       string                 `json:"passphrase" gorm:"size:255"`                                                                                                                                         // Passphrase used for encrypting the private key.
+	ArgonMemory      uint                   `json:"argonMemory" gorm:"default:2097152"`                                                                                                                                 // Argon2 memory parameter in KiB for encrypted private key passphrase. Default: 2 MiB. (2*1024*1024)
+	ArgonIterations  uint                   `json:"argonIterations" gorm:"default:2"`                                                                                                                                   // Number of Argon2 iterations for encrypting private key passphrase. Default: 2.
+	ArgonParallelism uint                   `json:"argonParallelism" gorm:"default:4"`                                                                                                                                  // Argon2 parallelism parameter for encrypting private key passphrase. Default: 4.
+	Curve            string                 `json:"curve" gorm:"default:25519"`                                                                                                                                         // Cryptographic curve for key generation. Options include "25519" (default) and "P256".
+	Duration         time.Duration          `json:"duration" gorm:"default:17531" swaggertype:"number"`                                                                                                                 // Certificate validity duration. Default: 2 years (17,531 hours). (time.Duration(time.Hour*8760))
+	SSHUsers         []configAuthorizedUser `json:"sshUsers" gorm:"serializer:json;default:'[]'"`                                                                                                                       // SSH admin users rendered into the sshd block of every host in the network.
+	Ca               []Certificate          `json:"ca,omitempty" gorm:"polymorphic:Owner;constraint:OnDelete:CASCADE"`                                                                                                  // Associated Certificate Authorities (CA) for the network.
+	Hosts            []Host                 `json:"hosts,omitempty" gorm:"constraint:OnDelete:CASCADE"`                                                                                                                 // Associated hosts for the network.
+	Version          uint64                 `json:"version" gorm:"not null;default:1"`                                                                                                                                  // Incremented on every update, for optimistic concurrency.
+	ConfigRevision   uint64                 `json:"-" gorm:"not null;default:0"`                                                                                                                                        // Incremented whenever the config of any host in the network may have changed.
+	CreatedAt        time.Time              `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time              `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt        gorm.DeletedAt         `json:"deletedAt,omitempty" gorm:"index" swaggertype:"string"` // Set while the network is deleted, until it is restored or purged.
 }
 
 // DTO for create/update operations
 type NetworkDto struct {
-	Name             string        `json:"name,omitempty" example:"my-network"`
-	IPs              []string      `json:"ips,omitempty" example:"100.100.0.0/22"`
-	Subnets          []string      `json:"subnets,omitempty" example:"192.168.1.0/24"`
-	Groups           []string      `json:"groups,omitempty" example:"laptop,ssh,servers"`
-	Duration         time.Duration `json:"duration,omitempty" example:"17531" swaggertype:"number"`
-	Encrypt          bool          `json:"encrypt,omitempty" example:"false"`
-	Passphrase       string        `json:"passphrase" example:"orange-duck-walks-happy-sunset-92"`
-	ArgonMemory      uint          `json:"argonMemory,omitempty" example:"2097152"`
-	ArgonIterations  uint          `json:"argonIterations,omitempty" example:"2"`
-	ArgonParallelism uint          `json:"argonParallelism,omitempty" example:"4"`
-	Curve            string        `json:"curve,omitempty" example:"25519" enums:"25519,X25519,Curve25519,CURVE25519,P256"`
+	OrganizationID   *uuid.UUID             `json:"organizationId,omitempty" example:"c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"`
+	Name             string                 `json:"name,omitempty" example:"my-network"`
+	IPs              []string               `json:"ips,omitempty" example:"100.100.0.0/22"`
+	Subnets          []string               `json:"subnets,omitempty" example:"192.168.1.0/24"`
+	Groups           []string               `json:"groups,omitempty" example:"laptop,ssh,servers"`
+	Duration         time.Duration          `json:"duration,omitempty" example:"17531" swaggertype:"number"`
+	Encrypt          bool                   `json:"encrypt,omitempty" example:"false"`
+	Passphrase       string                 `json:"passphrase" example:"orange-duck-walks-happy-sunset-92"`
+	ArgonMemory      uint                   `json:"argonMemory,omitempty" example:"2097152"`
+	ArgonIterations  uint                   `json:"argonIterations,omitempty" example:"2"`
+	ArgonParallelism uint                   `json:"argonParallelism,omitempty" example:"4"`
+	Curve            string                 `json:"curve,omitempty" example:"25519" enums:"25519,X25519,Curve25519,CURVE25519,P256"`
+	SSHUsers         []configAuthorizedUser `json:"sshUsers,omitempty"`
 }
 
 func (n *Network) ValidCAs() []Certificate {
@@ -58,6 +65,14 @@ func (n *Network) ValidCAs() []Certificate {
 	return validCAs
 }
 
+// RedactKeys clears the CA private keys and passphrase of the network.
+func (n *Network) RedactKeys() {
+	n.Passphrase = ""
+	for i := range n.Ca {
+		n.Ca[i].RedactKeys()
+	}
+}
+
 func (n *Network) CAs() string {
 	var builder strings.Builder
 	for _, ca := range n.Ca {
@@ -67,13 +82,14 @@ func (n *Network) CAs() string {
 	return builder.String()
 }
 
-func (n *Network) StaticHostMap() map[string][]string {
+// StaticHostMap returns the static_host_map for h, limited to the lighthouses and relays assigned to it.
+func (n *Network) StaticHostMap(h *Host) map[string][]string {
 	hostMap := make(map[string][]string)
 
-	for _, host := range n.Hosts {
-		if len(host.StaticAddresses) > 0 && host.Configuration.Lighthouse.AmLighthouse || host.Configuration.Relay.AmRelay {
-			hostMap[host.GetIp()] = internal.MapValues(host.StaticAddresses, func(addr string) string {
-				return fmt.Sprintf("%v:%v", addr, host.Configuration.Listen.Port)
+	for _, host := range append(n.lighthousesFor(h), n.relaysFor(h)...) {
+		if len(host.StaticAddresses) > 0 {
+			hostMap[host.GetIp()] = internal.MapValues(host.StaticAddresses, func(addr StaticAddress) string {
+				return addr.Addr(host.Configuration.Listen.Port)
 			})
 		}
 	}
@@ -81,24 +97,38 @@ func (n *Network) StaticHostMap() map[string][]string {
 	return hostMap
 }
 
-func (n *Network) Lighthouses() []string {
-	var hosts []string
+// Lighthouses returns the nebula IPs of the lighthouses assigned to h.
+func (n *Network) Lighthouses(h *Host) []string {
+	return internal.MapValues(n.lighthousesFor(h), func(host Host) string {
+		return host.GetIp()
+	})
+}
+
+// Relays returns the nebula IPs of the relays assigned to h.
+func (n *Network) Relays(h *Host) []string {
+	return internal.MapValues(n.relaysFor(h), func(host Host) string {
+		return host.GetIp()
+	})
+}
+
+func (n *Network) lighthousesFor(h *Host) []Host {
+	var hosts []Host
 
 	for _, host := range n.Hosts {
-		if host.Configuration.Lighthouse.AmLighthouse {
-			hosts = append(hosts, host.GetIp())
+		if host.Configuration.Lighthouse.AmLighthouse && host.ID != h.ID && h.usesLighthouse(&host) {
+			hosts = append(hosts, host)
 		}
 	}
 
 	return hosts
 }
 
-func (n *Network) Relays() []string {
-	var hosts []string
+func (n *Network) relaysFor(h *Host) []Host {
+	var hosts []Host
 
 	for _, host := range n.Hosts {
-		if host.Configuration.Relay.AmRelay {
-			hosts = append(hosts, host.GetIp())
+		if host.Configuration.Relay.AmRelay && host.ID != h.ID && h.usesRelay(&host) {
+			hosts = append(hosts, host)
 		}
 	}
 
@@ -107,7 +137,21 @@ func (n *Network) Relays() []string {
 
 // Hooks
 func (n *Network) BeforeCreate(tx *gorm.DB) error {
-	n.ID = uuid.New()
+	// Imported networks keep their ID
+	if !keepIDs(tx) || n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+
+	// BeforeSave reports invalid networks, don't sign a CA for them
+	if n.validate() != nil {
+		return nil
+	}
+
+	if n.OrganizationID != nil {
+		if err := checkNetworkQuota(tx, *n.OrganizationID); err != nil {
+			return err
+		}
+	}
 
 	if len(n.Ca) == 0 {
 		ca, err := n.NewCA()
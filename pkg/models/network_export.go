@@ -0,0 +1,309 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+	"gorm.io/gorm"
+)
+
+// Format and version of network exports. The version is raised when the archive changes incompatibly;
+// imports accept every version up to the current one.
+const (
+	NetworkExportFormat  = "koodnet-network"
+	NetworkExportVersion = 1
+)
+
+var (
+	ErrExportPassphraseRequired = errors.New("the export is encrypted, a passphrase is required")
+	ErrExportPassphraseInvalid  = errors.New("the passphrase does not decrypt the export")
+	ErrExportUnsupported        = errors.New("unsupported network export")
+)
+
+// Upper bounds of the Argon2 parameters of imported exports, which come from untrusted uploads.
+// Exports are sealed with 64 MiB and 3 iterations.
+const (
+	maxExportArgonMemory     = 256 * 1024 // KiB
+	maxExportArgonIterations = 8
+)
+
+// keepIDsKey is the gorm setting that makes BeforeCreate hooks keep the IDs of imported records.
+const keepIDsKey = "koodnet:keep_ids"
+
+func keepIDs(tx *gorm.DB) bool {
+	keep, _ := tx.Get(keepIDsKey)
+	return keep == true
+}
+
+// NetworkExport is a versioned archive of a network, for backups and moving networks between koodnet instances.
+// The archive is sealed into Ciphertext when the export is encrypted with a passphrase.
+type NetworkExport struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exportedAt"`
+	Encryption *ExportEncryption `json:"encryption,omitempty"`
+	Archive    *NetworkArchive   `json:"archive,omitempty"`
+	Ciphertext []byte            `json:"ciphertext,omitempty" swaggertype:"string"`
+}
+
+// ExportEncryption describes how the archive of an encrypted export is sealed: with AES-256-GCM,
+// keyed by the Argon2id hash of the passphrase.
+type ExportEncryption struct {
+	Cipher      string `json:"cipher" example:"aes-256-gcm"`
+	KDF         string `json:"kdf" example:"argon2id"`
+	Salt        []byte `json:"salt" swaggertype:"string"`
+	Nonce       []byte `json:"nonce" swaggertype:"string"`
+	Memory      uint32 `json:"memory"` // Argon2 memory in KiB.
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// NetworkArchive is a network with its CAs, and its hosts with their configurations and certificates.
+type NetworkArchive struct {
+	Network Network        `json:"network"`
+	Hosts   []ArchivedHost `json:"hosts"`
+}
+
+// ArchivedHost is a host with its sshd host key, which the API does not show otherwise.
+type ArchivedHost struct {
+	Host
+	SSHHostKey []byte `json:"sshHostKey" swaggertype:"string"`
+}
+
+// ExportNetwork archives the network with its CAs, hosts, configurations and certificates, including private keys.
+func ExportNetwork(db *gorm.DB, id uuid.UUID) (*NetworkArchive, error) {
+	var n Network
+	err := db.Preload("Ca").
+		Preload("Hosts", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Preload("Hosts.Configuration").
+		Preload("Hosts.Certificate").
+		First(&n, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	archive := NetworkArchive{Hosts: make([]ArchivedHost, len(n.Hosts))}
+	for i, h := range n.Hosts {
+		archive.Hosts[i] = ArchivedHost{Host: h, SSHHostKey: h.SSHHostKey}
+	}
+
+	n.Hosts = nil
+	archive.Network = n
+
+	return &archive, nil
+}
+
+// NewNetworkExport wraps the archive in an export, encrypted when passphrase is not empty.
+func NewNetworkExport(archive *NetworkArchive, passphrase string) (*NetworkExport, error) {
+	export := NetworkExport{
+		Format:     NetworkExportFormat,
+		Version:    NetworkExportVersion,
+		ExportedAt: time.Now().UTC(),
+	}
+
+	if passphrase == "" {
+		export.Archive = archive
+		return &export, nil
+	}
+
+	plaintext, err := json.Marshal(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := ExportEncryption{
+		Cipher:      "aes-256-gcm",
+		KDF:         "argon2id",
+		Salt:        make([]byte, 16),
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+	}
+
+	if _, err := rand.Read(enc.Salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := enc.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	enc.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(enc.Nonce); err != nil {
+		return nil, err
+	}
+
+	export.Encryption = &enc
+	export.Ciphertext = aead.Seal(nil, enc.Nonce, plaintext, export.additionalData())
+
+	return &export, nil
+}
+
+// Open returns the archive of the export, decrypting it with passphrase when the export is encrypted.
+func (e *NetworkExport) Open(passphrase string) (*NetworkArchive, error) {
+	if e.Format != NetworkExportFormat || e.Version < 1 || e.Version > NetworkExportVersion {
+		return nil, fmt.Errorf("%w: format %q version %d", ErrExportUnsupported, e.Format, e.Version)
+	}
+
+	archive := e.Archive
+	if e.Encryption != nil {
+		if passphrase == "" {
+			return nil, ErrExportPassphraseRequired
+		}
+
+		if e.Encryption.Cipher != "aes-256-gcm" || e.Encryption.KDF != "argon2id" {
+			return nil, fmt.Errorf("%w: cipher %q with kdf %q", ErrExportUnsupported, e.Encryption.Cipher, e.Encryption.KDF)
+		}
+
+		aead, err := e.Encryption.aead(passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(e.Encryption.Nonce) != aead.NonceSize() {
+			return nil, fmt.Errorf("%w: invalid nonce", ErrExportUnsupported)
+		}
+
+		plaintext, err := aead.Open(nil, e.Encryption.Nonce, e.Ciphertext, e.additionalData())
+		if err != nil {
+			return nil, ErrExportPassphraseInvalid
+		}
+
+		if err := json.Unmarshal(plaintext, &archive); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrExportUnsupported, err)
+		}
+	}
+
+	if archive == nil {
+		return nil, fmt.Errorf("%w: the export holds no network", ErrExportUnsupported)
+	}
+
+	if len(archive.Network.Ca) == 0 {
+		return nil, NewFieldError("network.ca", "the network of the export has no CA")
+	}
+
+	return archive, nil
+}
+
+// additionalData binds the ciphertext to the format and version of the export.
+func (e *NetworkExport) additionalData() []byte {
+	return []byte(fmt.Sprintf("%s/%d", e.Format, e.Version))
+}
+
+func (enc *ExportEncryption) aead(passphrase string) (cipher.AEAD, error) {
+	// Bound the cost of the key derivation, as imports come from untrusted uploads
+	if enc.Memory == 0 || enc.Memory > maxExportArgonMemory || enc.Iterations == 0 || enc.Iterations > maxExportArgonIterations || enc.Parallelism == 0 {
+		return nil, fmt.Errorf("%w: invalid argon2 parameters", ErrExportUnsupported)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), enc.Salt, enc.Iterations, enc.Memory, enc.Parallelism, 32)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// RemapIDs gives the network and everything in the archive new IDs, so that it can be imported
+// next to the network it was exported from.
+func (a *NetworkArchive) RemapIDs() {
+	a.Network.ID = uuid.New()
+	for i := range a.Network.Ca {
+		a.Network.Ca[i].ID = uuid.New()
+	}
+
+	for i := range a.Hosts {
+		h := &a.Hosts[i]
+		h.ID = uuid.New()
+
+		if h.Configuration != nil {
+			h.Configuration.ID = uuid.New()
+		}
+
+		if h.Certificate != nil {
+			h.Certificate.ID = uuid.New()
+		}
+	}
+}
+
+// Import creates the network of the archive in the organization, with its CAs, hosts, configurations and
+// certificates, keeping their IDs. It fails with gorm.ErrDuplicatedKey when any of them exists already,
+// including deleted networks and hosts that were not purged yet.
+func (a *NetworkArchive) Import(tx *gorm.DB, organizationID *uuid.UUID) error {
+	n := &a.Network
+	n.OrganizationID = organizationID
+	n.Organization = nil
+	n.Hosts = nil
+	n.DeletedAt = gorm.DeletedAt{}
+
+	var certIDs, configIDs []uuid.UUID
+	for _, ca := range n.Ca {
+		certIDs = append(certIDs, ca.ID)
+	}
+
+	for i := range a.Hosts {
+		h := &a.Hosts[i]
+		if h.Configuration == nil || h.Certificate == nil {
+			return NewFieldError(fmt.Sprintf("hosts[%d]", i), "the host has no configuration or certificate")
+		}
+
+		h.Network = nil
+		h.DeletedAt = gorm.DeletedAt{}
+		h.Host.SSHHostKey = h.SSHHostKey
+		h.ConfigurationID = h.Configuration.ID
+		certIDs = append(certIDs, h.Certificate.ID)
+		configIDs = append(configIDs, h.Configuration.ID)
+	}
+
+	// Associations are created skipping existing rows, which must not be taken over
+	if err := checkIDsFree(tx, &Certificate{}, certIDs); err != nil {
+		return err
+	}
+
+	if err := checkIDsFree(tx, &Configuration{}, configIDs); err != nil {
+		return err
+	}
+
+	tx = tx.Set(keepIDsKey, true).Session(&gorm.Session{})
+
+	if err := tx.Create(n).Error; err != nil {
+		return err
+	}
+
+	for i := range a.Hosts {
+		a.Hosts[i].NetworkID = n.ID
+		if err := tx.Create(&a.Hosts[i].Host).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkIDsFree fails with gorm.ErrDuplicatedKey when a record of model has any of the IDs.
+func checkIDsFree(tx *gorm.DB, model any, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(model).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return gorm.ErrDuplicatedKey
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func testNetworkArchive() *NetworkArchive {
+	return &NetworkArchive{
+		Network: Network{
+			ID:   uuid.New(),
+			Name: "office",
+			Ca:   []Certificate{{ID: uuid.New(), IsCA: true, Crt: []byte("ca crt"), Key: []byte("ca key")}},
+		},
+		Hosts: []ArchivedHost{{Host: Host{ID: uuid.New(), Name: "web"}, SSHHostKey: []byte("ssh key")}},
+	}
+}
+
+// reopen sends the export through JSON, as it is downloaded and uploaded again.
+func reopen(t *testing.T, export *NetworkExport) *NetworkExport {
+	t.Helper()
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uploaded NetworkExport
+	if err := json.Unmarshal(data, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+
+	return &uploaded
+}
+
+func TestNetworkExportEncryption(t *testing.T) {
+	archive := testNetworkArchive()
+
+	export, err := NewNetworkExport(archive, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if export.Archive != nil || len(export.Ciphertext) == 0 || export.Encryption == nil {
+		t.Fatal("the encrypted export holds the archive in plaintext")
+	}
+
+	uploaded := reopen(t, export)
+
+	opened, err := uploaded.Open("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opened.Network.ID != archive.Network.ID || string(opened.Network.Ca[0].Key) != "ca key" || string(opened.Hosts[0].SSHHostKey) != "ssh key" {
+		t.Errorf("Open() = %+v, want the exported archive", opened)
+	}
+
+	if _, err := uploaded.Open("wrong"); !errors.Is(err, ErrExportPassphraseInvalid) {
+		t.Errorf("Open() with the wrong passphrase got %v, want ErrExportPassphraseInvalid", err)
+	}
+
+	if _, err := uploaded.Open(""); !errors.Is(err, ErrExportPassphraseRequired) {
+		t.Errorf("Open() without a passphrase got %v, want ErrExportPassphraseRequired", err)
+	}
+
+	// The ciphertext is bound to the version of the export
+	uploaded.Version = NetworkExportVersion + 1
+	if _, err := uploaded.Open("correct horse"); !errors.Is(err, ErrExportUnsupported) {
+		t.Errorf("Open() of a newer version got %v, want ErrExportUnsupported", err)
+	}
+}
+
+func TestNetworkExportWithoutPassphrase(t *testing.T) {
+	export, err := NewNetworkExport(testNetworkArchive(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if export.Encryption != nil || export.Archive == nil {
+		t.Fatal("the export without a passphrase is encrypted")
+	}
+
+	if opened, err := reopen(t, export).Open(""); err != nil || opened.Network.Name != "office" {
+		t.Errorf("Open() = %v, %v, want the archive", opened, err)
+	}
+}
+
+func TestNetworkExportBoundsArgonParameters(t *testing.T) {
+	export, err := NewNetworkExport(testNetworkArchive(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func(enc *ExportEncryption){
+		"memory":      func(enc *ExportEncryption) { enc.Memory = maxExportArgonMemory + 1 },
+		"iterations":  func(enc *ExportEncryption) { enc.Iterations = maxExportArgonIterations + 1 },
+		"no memory":   func(enc *ExportEncryption) { enc.Memory = 0 },
+		"parallelism": func(enc *ExportEncryption) { enc.Parallelism = 0 },
+	}
+
+	for name, modify := range tests {
+		uploaded := reopen(t, export)
+		modify(uploaded.Encryption)
+
+		if _, err := uploaded.Open("correct horse"); !errors.Is(err, ErrExportUnsupported) {
+			t.Errorf("%s: got %v, want ErrExportUnsupported", name, err)
+		}
+	}
+}
@@ -0,0 +1,647 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Actions of a plan
+const (
+	PlanCreate  = "create"
+	PlanUpdate  = "update"
+	PlanDelete  = "delete"
+	PlanReissue = "reissue" // The certificate of a host is signed anew, as its IP, groups, subnets or public key changed.
+)
+
+// NetworkSpec is the desired state of a network and its hosts. Applying it creates or updates the network,
+// creates and updates the hosts of the spec, and deletes the hosts of the network that are not in it.
+type NetworkSpec struct {
+	// The network, matched by name within its organization. Only its sshUsers are updated, the other settings
+	// are those of its CA, which apply when the network is created. Its IPs, subnets and groups cannot change after.
+	Network NetworkDto `json:"network"`
+
+	// Config overrides of every host, with the fields of a host configuration. Objects are merged into the default
+	// config, other values replace it.
+	Defaults map[string]any `json:"defaults,omitempty"`
+
+	// Firewall policies by name, which hosts add to their firewall rules.
+	Firewall map[string]FirewallPolicy `json:"firewall,omitempty"`
+
+	Hosts []HostSpec `json:"hosts"`
+}
+
+// FirewallPolicy is a named set of firewall rules.
+type FirewallPolicy struct {
+	Inbound  []configFirewallRule `json:"inbound,omitempty"`
+	Outbound []configFirewallRule `json:"outbound,omitempty"`
+}
+
+// HostSpec is the desired state of a host, matched by name within the network.
+type HostSpec struct {
+	Name            string          `json:"name" example:"host-1"`
+	IP              string          `json:"ip,omitempty" example:"100.100.0.1/24"` // New hosts without an IP get the next free address, existing ones keep theirs.
+	InPub           string          `json:"inPub,omitempty"`
+	StaticAddresses []StaticAddress `json:"staticAddresses,omitempty"`
+	Subnets         []string        `json:"subnets,omitempty" example:"192.168.1.0/24"`
+	Groups          []string        `json:"groups,omitempty" example:"laptop,servers,ssh"`
+	Site            string          `json:"site,omitempty" example:"eu-west"`
+	Lighthouses     []string        `json:"lighthouses,omitempty" example:"lighthouse-1,site:eu-west"`
+	Relays          []string        `json:"relays,omitempty" example:"relay-1,site:eu-west"`
+	Firewall        []string        `json:"firewall,omitempty" example:"ssh,web"` // Names of the firewall policies of the host, whose rules are added in order.
+	Configuration   map[string]any  `json:"configuration,omitempty"`              // Config overrides of the host, merged over the defaults of the spec.
+}
+
+// PlanAction is a change that applying a spec makes.
+type PlanAction struct {
+	Action       string     `json:"action" example:"update" enums:"create,update,delete,reissue"`
+	ResourceType string     `json:"resourceType" example:"host" enums:"network,host,certificate"`
+	ID           *uuid.UUID `json:"id,omitempty"` // Not set for resources that are yet to be created.
+	Name         string     `json:"name" example:"host-1"`
+	Fields       []string   `json:"fields,omitempty" example:"ip,groups"` // Changed fields of an update, or why a certificate is re-issued.
+}
+
+// NetworkPlan is what applying a spec changes.
+type NetworkPlan struct {
+	Current       *Network // The network as it is, nil when it is created.
+	Desired       Network  // The network as specified, with the ID of the current one.
+	NetworkFields []string // Changed fields of the network, which only sshUsers can be.
+	Hosts         []HostChange
+}
+
+// HostChange is the change of a single host.
+type HostChange struct {
+	Action  string   // PlanCreate, PlanUpdate or PlanDelete.
+	Current *Host    // The host as it is, with its configuration and certificate. Nil for creates.
+	Desired *Host    // The host as specified, with its configuration. Nil for deletes.
+	Fields  []string // Changed fields of an update.
+	Reissue bool     // Whether the certificate of an updated host is signed anew.
+}
+
+// hostField is a field of a host that specs set.
+type hostField struct {
+	name    string // JSON name, as shown in plans.
+	column  string // Go field name, for updates.
+	reissue bool   // Whether the field is part of the certificate.
+	equal   func(a, b *Host) bool
+}
+
+var hostFields = []hostField{
+	{"ip", "IP", true, func(a, b *Host) bool { return a.IP == b.IP }},
+	{"inPub", "InPub", true, func(a, b *Host) bool { return bytes.Equal(a.InPub, b.InPub) }},
+	{"staticAddresses", "StaticAddresses", false, func(a, b *Host) bool { return slices.Equal(a.StaticAddresses, b.StaticAddresses) }},
+	{"subnets", "Subnets", true, func(a, b *Host) bool { return slices.Equal(a.Subnets, b.Subnets) }},
+	{"groups", "Groups", true, func(a, b *Host) bool { return slices.Equal(a.Groups, b.Groups) }},
+	{"site", "Site", false, func(a, b *Host) bool { return a.Site == b.Site }},
+	{"lighthouses", "Lighthouses", false, func(a, b *Host) bool { return slices.Equal(a.Lighthouses, b.Lighthouses) }},
+	{"relays", "Relays", false, func(a, b *Host) bool { return slices.Equal(a.Relays, b.Relays) }},
+}
+
+// PlanNetwork compares the spec with the network of the same name in the organization, and returns the changes
+// that applying it makes. Invalid specs fail with a ValidationError.
+func PlanNetwork(tx *gorm.DB, spec *NetworkSpec, organizationID *uuid.UUID) (*NetworkPlan, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	plan := NetworkPlan{}
+
+	var current Network
+	query := tx.Omit(clause.Associations).Where("name = ?", spec.Network.Name)
+	if organizationID == nil {
+		query = query.Where("organization_id IS NULL")
+	} else {
+		query = query.Where("organization_id = ?", *organizationID)
+	}
+
+	err := query.First(&current).Error
+	switch {
+	case err == nil:
+		plan.Current = &current
+	case err != gorm.ErrRecordNotFound:
+		return nil, err
+	}
+
+	if err := plan.planNetwork(spec, organizationID); err != nil {
+		return nil, withinField(err, "network")
+	}
+
+	var hosts []Host
+	if plan.Current != nil {
+		err := tx.Preload("Configuration").Preload("Certificate").
+			Where("network_id = ?", current.ID).
+			Order("name").
+			Find(&hosts).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	used := map[netip.Addr]bool{}
+	if plan.Current != nil {
+		if used, err = UsedHostIPs(tx, current.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range spec.Hosts {
+		if prefix, err := netip.ParsePrefix(h.IP); err == nil {
+			used[prefix.Addr()] = true
+		}
+	}
+
+	existing := map[string]*Host{}
+	for i := range hosts {
+		existing[hosts[i].Name] = &hosts[i]
+	}
+
+	for i := range spec.Hosts {
+		change, err := plan.planHost(spec, &spec.Hosts[i], existing[spec.Hosts[i].Name], used)
+		if err != nil {
+			return nil, withinField(err, fmt.Sprintf("hosts[%d]", i))
+		}
+
+		delete(existing, spec.Hosts[i].Name)
+		if change != nil {
+			plan.Hosts = append(plan.Hosts, *change)
+		}
+	}
+
+	for i := range hosts {
+		if _, gone := existing[hosts[i].Name]; gone {
+			plan.Hosts = append(plan.Hosts, HostChange{Action: PlanDelete, Current: &hosts[i]})
+		}
+	}
+
+	// Deletes go first, freeing names and IPs for the hosts that follow
+	slices.SortStableFunc(plan.Hosts, func(a, b HostChange) int {
+		return planOrder(a.Action) - planOrder(b.Action)
+	})
+
+	return &plan, nil
+}
+
+func planOrder(action string) int {
+	return slices.Index([]string{PlanDelete, PlanUpdate, PlanCreate}, action)
+}
+
+func (p *NetworkPlan) planNetwork(spec *NetworkSpec, organizationID *uuid.UUID) error {
+	dto := spec.Network
+	desired := Network{
+		Name:             dto.Name,
+		OrganizationID:   organizationID,
+		IPs:              nonNil(dto.IPs),
+		Subnets:          nonNil(dto.Subnets),
+		Groups:           nonNil(dto.Groups),
+		Duration:         dto.Duration,
+		Encrypt:          dto.Encrypt,
+		Passphrase:       dto.Passphrase,
+		ArgonMemory:      dto.ArgonMemory,
+		ArgonIterations:  dto.ArgonIterations,
+		ArgonParallelism: dto.ArgonParallelism,
+		Curve:            dto.Curve,
+		SSHUsers:         nonNil(dto.SSHUsers),
+	}
+
+	if p.Current == nil {
+		p.Desired = desired
+		return desired.validate()
+	}
+
+	// The CA is not signed anew, so the IPs, subnets and groups it is constrained to cannot change
+	current := *p.Current
+	constraints := []struct {
+		field            string
+		current, desired []string
+	}{
+		{"ips", current.IPs, desired.IPs},
+		{"subnets", current.Subnets, desired.Subnets},
+		{"groups", current.Groups, desired.Groups},
+	}
+
+	for _, constraint := range constraints {
+		if !slices.Equal(constraint.current, constraint.desired) {
+			return NewFieldError(constraint.field, constraint.field+" constrain the CA and cannot be changed once the network is created")
+		}
+	}
+
+	if !jsonEqual(current.SSHUsers, desired.SSHUsers) {
+		p.NetworkFields = append(p.NetworkFields, "sshUsers")
+	}
+
+	current.SSHUsers = desired.SSHUsers
+	p.Desired = current
+
+	return current.validate()
+}
+
+// checkHostConstraints fails with a ValidationError when the CA of the network cannot sign the host,
+// as its IP or groups are outside of those the CA is constrained to.
+func (n *Network) checkHostConstraints(h *Host) error {
+	if len(n.IPs) > 0 {
+		addr, err := netip.ParsePrefix(h.IP)
+		if err != nil {
+			return NewFieldError("ip", "ip must be an address in CIDR notation, e.g. 100.100.0.1/24")
+		}
+
+		within := false
+		for _, ip := range n.IPs {
+			if prefix, err := netip.ParsePrefix(ip); err == nil && prefix.Contains(addr.Addr()) {
+				within = true
+			}
+		}
+
+		if !within {
+			return NewFieldError("ip", "ip is not within the IPs of the network")
+		}
+	}
+
+	if len(n.Groups) > 0 {
+		for i, group := range h.Groups {
+			if !slices.Contains(n.Groups, group) {
+				return NewFieldError(fmt.Sprintf("groups[%d]", i), "group is not one of the groups of the network: "+group)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *NetworkPlan) planHost(spec *NetworkSpec, hs *HostSpec, current *Host, used map[netip.Addr]bool) (*HostChange, error) {
+	cfg, err := spec.hostConfiguration(hs)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := Host{
+		Name:            hs.Name,
+		IP:              hs.IP,
+		InPub:           []byte(hs.InPub),
+		StaticAddresses: nonNil(hs.StaticAddresses),
+		Subnets:         nonNil(hs.Subnets),
+		Groups:          nonNil(hs.Groups),
+		Site:            hs.Site,
+		Lighthouses:     nonNil(hs.Lighthouses),
+		Relays:          nonNil(hs.Relays),
+		NetworkID:       p.Desired.ID,
+		Configuration:   cfg,
+	}
+
+	if len(desired.InPub) == 0 {
+		desired.InPub = nil
+	}
+
+	if current == nil {
+		if desired.IP == "" {
+			if desired.IP, err = p.Desired.AllocateIP(used); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := desired.validate(); err != nil {
+			return nil, err
+		}
+
+		if err := p.Desired.checkHostConstraints(&desired); err != nil {
+			return nil, err
+		}
+
+		return &HostChange{Action: PlanCreate, Desired: &desired}, nil
+	}
+
+	if desired.IP == "" {
+		desired.IP = current.IP
+	}
+
+	if err := desired.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := p.Desired.checkHostConstraints(&desired); err != nil {
+		return nil, err
+	}
+
+	change := HostChange{Action: PlanUpdate, Current: current, Desired: &desired}
+	for _, f := range hostFields {
+		if !f.equal(current, &desired) {
+			change.Fields = append(change.Fields, f.name)
+			change.Reissue = change.Reissue || f.reissue
+		}
+	}
+
+	if current.Configuration == nil || !configurationsEqual(current.Configuration, desired.Configuration) {
+		change.Fields = append(change.Fields, "configuration")
+	}
+
+	if len(change.Fields) == 0 {
+		return nil, nil
+	}
+
+	desired.ID = current.ID
+	desired.ConfigurationID = current.ConfigurationID
+	if current.Configuration != nil {
+		desired.Configuration.ID = current.Configuration.ID
+	}
+
+	return &change, nil
+}
+
+// Actions lists the changes of the plan, with the certificates that are signed anew.
+func (p *NetworkPlan) Actions() []PlanAction {
+	actions := []PlanAction{}
+
+	switch {
+	case p.Current == nil:
+		actions = append(actions, PlanAction{Action: PlanCreate, ResourceType: AuditResourceNetwork, Name: p.Desired.Name})
+	case len(p.NetworkFields) > 0:
+		actions = append(actions, PlanAction{Action: PlanUpdate, ResourceType: AuditResourceNetwork, ID: &p.Current.ID, Name: p.Current.Name, Fields: p.NetworkFields})
+	}
+
+	for _, change := range p.Hosts {
+		switch change.Action {
+		case PlanCreate:
+			actions = append(actions, PlanAction{Action: PlanCreate, ResourceType: AuditResourceHost, Name: change.Desired.Name})
+		case PlanUpdate:
+			actions = append(actions, PlanAction{Action: PlanUpdate, ResourceType: AuditResourceHost, ID: &change.Current.ID, Name: change.Current.Name, Fields: change.Fields})
+		case PlanDelete:
+			actions = append(actions, PlanAction{Action: PlanDelete, ResourceType: AuditResourceHost, ID: &change.Current.ID, Name: change.Current.Name})
+		}
+
+		if change.Reissue {
+			action := PlanAction{Action: PlanReissue, ResourceType: AuditResourceCertificate, Name: change.Current.Name}
+			if change.Current.Certificate != nil {
+				action.ID = &change.Current.Certificate.ID
+			}
+
+			for _, f := range hostFields {
+				if f.reissue && slices.Contains(change.Fields, f.name) {
+					action.Fields = append(action.Fields, f.name)
+				}
+			}
+
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
+
+// UpdateNetwork updates the SSH users of the network.
+func (p *NetworkPlan) UpdateNetwork(tx *gorm.DB) error {
+	if err := BumpVersion(tx, &Network{}, p.Current.ID, p.Current.Version); err != nil {
+		return err
+	}
+
+	return tx.Model(p.Current).Select("SSHUsers").Updates(&p.Desired).Error
+}
+
+// Update updates the changed fields and configuration of the host, and signs its certificate anew when needed.
+// It returns the replaced certificate, if any.
+func (c *HostChange) Update(tx *gorm.DB) (*Certificate, error) {
+	h := c.Current
+	if err := BumpVersion(tx, &Host{}, h.ID, h.Version); err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, f := range hostFields {
+		if slices.Contains(c.Fields, f.name) {
+			selected = append(selected, f.column)
+		}
+	}
+
+	// BeforeSave sets the IP number of the IP
+	if slices.Contains(c.Fields, "ip") {
+		selected = append(selected, "IPNumber")
+	}
+
+	if len(selected) > 0 {
+		if err := tx.Model(h).Select(selected).Updates(c.Desired).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(c.Fields, "configuration") {
+		if err := BumpVersion(tx, &Configuration{}, h.Configuration.ID, h.Configuration.Version); err != nil {
+			return nil, err
+		}
+
+		err := tx.Model(h.Configuration).Select("*").Omit("id", "host_id", "version", "created_at").Updates(c.Desired.Configuration).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !c.Reissue {
+		return nil, nil
+	}
+
+	h.IP, h.InPub, h.Subnets, h.Groups = c.Desired.IP, c.Desired.InPub, c.Desired.Subnets, c.Desired.Groups
+
+	return h.Reissue(tx)
+}
+
+// Reissue signs a new certificate for the host with the latest valid CA of its network, replacing its current one,
+// which is returned.
+func (h *Host) Reissue(tx *gorm.DB) (*Certificate, error) {
+	var old Certificate
+	err := tx.Session(&gorm.Session{NewDB: true}).Where("owner_id = ? AND owner_type = ?", h.ID, "hosts").First(&old).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	h.Certificate = nil
+	if err := h.Sign(tx); err != nil {
+		return nil, err
+	}
+
+	h.Certificate.OwnerID = h.ID
+	h.Certificate.OwnerType = "hosts"
+
+	if err := tx.Where("owner_id = ? AND owner_type = ?", h.ID, "hosts").Delete(&Certificate{}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(h.Certificate).Error; err != nil {
+		return nil, err
+	}
+
+	if old.ID == uuid.Nil {
+		return nil, nil
+	}
+
+	return &old, nil
+}
+
+func (s *NetworkSpec) validate() error {
+	if strings.TrimSpace(s.Network.Name) == "" {
+		return NewFieldError("network.name", "name cannot be empty")
+	}
+
+	if _, err := s.hostConfiguration(&HostSpec{}); err != nil {
+		return NewFieldError("defaults", err.Error())
+	}
+
+	names := map[string]int{}
+	ips := map[netip.Addr]int{}
+	for i, h := range s.Hosts {
+		field := fmt.Sprintf("hosts[%d]", i)
+
+		if strings.TrimSpace(h.Name) == "" {
+			return NewFieldError(field+".name", "name cannot be empty")
+		}
+
+		if j, dup := names[h.Name]; dup {
+			return NewFieldError(field+".name", fmt.Sprintf("name is already used by hosts[%d]", j))
+		}
+		names[h.Name] = i
+
+		if h.IP != "" {
+			prefix, err := netip.ParsePrefix(h.IP)
+			if err != nil {
+				return NewFieldError(field+".ip", "ip must be an address in CIDR notation, e.g. 100.100.0.1/24")
+			}
+
+			if j, dup := ips[prefix.Addr()]; dup {
+				return NewFieldError(field+".ip", fmt.Sprintf("ip is already used by hosts[%d]", j))
+			}
+			ips[prefix.Addr()] = i
+		}
+
+		for j, policy := range h.Firewall {
+			if _, found := s.Firewall[policy]; !found {
+				return NewFieldError(fmt.Sprintf("%s.firewall[%d]", field, j), "unknown firewall policy: "+policy)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hostConfiguration returns the config of a host of the spec: the default config of new hosts with the defaults
+// of the spec and then the overrides of the host merged in, and the rules of its firewall policies added.
+func (s *NetworkSpec) hostConfiguration(h *HostSpec) (*Configuration, error) {
+	base, err := json.Marshal(newConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(base, &doc); err != nil {
+		return nil, err
+	}
+
+	mergeJSON(doc, s.Defaults)
+	mergeJSON(doc, h.Configuration)
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Configuration
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, NewFieldError("configuration", "invalid config override: "+err.Error())
+	}
+
+	for _, name := range h.Firewall {
+		policy := s.Firewall[name]
+		cfg.Firewall.Inbound = append(cfg.Firewall.Inbound, policy.Inbound...)
+		cfg.Firewall.Outbound = append(cfg.Firewall.Outbound, policy.Outbound...)
+	}
+
+	cfg.ID = uuid.Nil
+	cfg.Version = 0
+
+	return &cfg, nil
+}
+
+// mergeJSON merges the JSON object src into dst. Objects are merged recursively, other values replace those of dst.
+func mergeJSON(dst, src map[string]any) {
+	for key, value := range src {
+		srcObject, srcIsObject := value.(map[string]any)
+		dstObject, dstIsObject := dst[key].(map[string]any)
+
+		if srcIsObject && dstIsObject {
+			mergeJSON(dstObject, srcObject)
+			continue
+		}
+
+		dst[key] = value
+	}
+}
+
+// configurationsEqual compares the nebula settings of two configurations, leaving out their IDs and versions.
+func configurationsEqual(a, b *Configuration) bool {
+	settings := func(cfg Configuration) Configuration {
+		cfg.ID, cfg.HostID, cfg.Host, cfg.Version = uuid.Nil, uuid.Nil, nil, 0
+		cfg.CreatedAt, cfg.UpdatedAt = time.Time{}, time.Time{}
+		return cfg
+	}
+
+	return jsonEqual(settings(*a), settings(*b))
+}
+
+// jsonEqual compares the JSON encodings of two values, so that nil and empty lists and maps are equal.
+func jsonEqual(a, b any) bool {
+	normalize := func(v any) any {
+		raw, _ := json.Marshal(v)
+
+		var out any
+		json.Unmarshal(raw, &out)
+
+		return dropEmpty(out)
+	}
+
+	x, y := normalize(a), normalize(b)
+	rawX, _ := json.Marshal(x)
+	rawY, _ := json.Marshal(y)
+
+	return bytes.Equal(rawX, rawY)
+}
+
+// dropEmpty removes nulls, empty lists and empty objects from decoded JSON.
+func dropEmpty(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			value = dropEmpty(value)
+			if value == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = value
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		for i := range v {
+			v[i] = dropEmpty(v[i])
+		}
+	}
+
+	return v
+}
+
+// nonNil returns an empty list for nil, so that clearing a list is saved as an update.
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+
+	return values
+}
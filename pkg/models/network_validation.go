@@ -11,11 +11,11 @@ import (
 // Validators
 func (n *Network) validate() error {
 	if strings.TrimSpace(n.Name) == "" {
-		return NewValidationError("name cannot be empty")
+		return NewFieldError("name", "name cannot be empty")
 	}
 
 	if n.Duration <= 0 {
-		return NewValidationError("duration must be greater than 0")
+		return NewFieldError("duration", "duration must be greater than 0")
 	}
 
 	if n.Encrypt {
@@ -25,7 +25,7 @@ func (n *Network) validate() error {
 	}
 
 	if len(n.IPs) == 0 {
-		return NewValidationError("at least one IP is required")
+		return NewFieldError("ips", "at least one IP is required")
 	}
 
 	if err := n.validateIPs(); err != nil {
@@ -40,38 +40,42 @@ func (n *Network) validate() error {
 		return err
 	}
 
+	if err := validateSSHUsers(n.SSHUsers); err != nil {
+		return withinField(err, "sshUsers")
+	}
+
 	return nil
 }
 
 func (n *Network) validateEncryption() error {
 	if len(n.Passphrase) == 0 {
-		return NewValidationError("passphrase is required when encryption is enabled")
+		return NewFieldError("passphrase", "passphrase is required when encryption is enabled")
 	}
 
 	validCurves := []string{"25519", "X25519", "Curve25519", "CURVE25519", "P256"}
 	if !slices.Contains(validCurves, n.Curve) {
-		return NewValidationError("invalid curve; valid options are '25519' or 'P256'")
+		return NewFieldError("curve", "invalid curve; valid options are '25519' or 'P256'")
 	}
 
 	if n.ArgonMemory <= 0 || n.ArgonMemory > math.MaxUint32 {
-		return fmt.Errorf("argon_memory must be greater than 0 and no more than %d KiB", uint32(math.MaxUint32))
+		return NewFieldError("argonMemory", fmt.Sprintf("argon_memory must be greater than 0 and no more than %d KiB", uint32(math.MaxUint32)))
 	}
 
 	if n.ArgonParallelism <= 0 || n.ArgonParallelism > math.MaxUint8 {
-		return fmt.Errorf("argon_parallelism must be greater than 0 and no more than %d", math.MaxUint8)
+		return NewFieldError("argonParallelism", fmt.Sprintf("argon_parallelism must be greater than 0 and no more than %d", math.MaxUint8))
 	}
 
 	if n.ArgonIterations <= 0 || n.ArgonIterations > math.MaxUint32 {
-		return fmt.Errorf("argon_iterations must be greater than 0 and no more than %d", uint32(math.MaxUint32))
+		return NewFieldError("argonIterations", fmt.Sprintf("argon_iterations must be greater than 0 and no more than %d", uint32(math.MaxUint32)))
 	}
 
 	return nil
 }
 
 func (n *Network) validateIPs() error {
-	for _, ip := range n.IPs {
+	for i, ip := range n.IPs {
 		if _, _, err := net.ParseCIDR(ip); err != nil {
-			return NewValidationError("invalid IP: " + ip)
+			return NewFieldError(fmt.Sprintf("ips[%d]", i), "invalid IP: "+ip)
 		}
 	}
 	return nil
@@ -79,9 +83,9 @@ func (n *Network) validateIPs() error {
 
 func (n *Network) validateSubnets() error {
 	if len(n.Subnets) > 0 {
-		for _, subnet := range n.Subnets {
+		for i, subnet := range n.Subnets {
 			if _, _, err := net.ParseCIDR(subnet); err != nil {
-				return NewValidationError("invalid subnet: " + subnet)
+				return NewFieldError(fmt.Sprintf("subnets[%d]", i), "invalid subnet: "+subnet)
 			}
 		}
 	}
@@ -90,9 +94,9 @@ func (n *Network) validateSubnets() error {
 
 func (n *Network) validateGroups() error {
 	if len(n.Groups) > 0 {
-		for _, group := range n.Groups {
+		for i, group := range n.Groups {
 			if len(strings.TrimSpace(group)) == 0 {
-				return NewValidationError("group names cannot be empty")
+				return NewFieldError(fmt.Sprintf("groups[%d]", i), "group names cannot be empty")
 			}
 		}
 	}
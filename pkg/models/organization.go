@@ -0,0 +1,133 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrQuotaExceeded        = errors.New("organization quota exceeded")
+	ErrOrganizationNotEmpty = errors.New("organization still has networks")
+)
+
+// Organization owns networks. Networks, their CAs and hosts are isolated per organization.
+type Organization struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	Name        string    `json:"name" gorm:"size:255;not null;uniqueIndex"`
+	MaxNetworks uint      `json:"maxNetworks" gorm:"default:0"` // Maximum number of networks. 0 means unlimited.
+	MaxHosts    uint      `json:"maxHosts" gorm:"default:0"`    // Maximum number of hosts across all networks. 0 means unlimited.
+	Networks    []Network `json:"networks,omitempty" gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// DTO for create/update operations
+type OrganizationDto struct {
+	Name        string `json:"name,omitempty" example:"payments"`
+	MaxNetworks uint   `json:"maxNetworks,omitempty" example:"10"`
+	MaxHosts    uint   `json:"maxHosts,omitempty" example:"500"`
+}
+
+// checkNetworkQuota fails with ErrQuotaExceeded when the organization has no room for another network.
+func checkNetworkQuota(tx *gorm.DB, orgID uuid.UUID) error {
+	var org Organization
+	if err := tx.Session(&gorm.Session{NewDB: true}).First(&org, "id = ?", orgID).Error; err != nil {
+		return organizationNotFound(err)
+	}
+
+	if org.MaxNetworks == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Session(&gorm.Session{NewDB: true}).Model(&Network{}).Where("organization_id = ?", orgID).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if uint(count) >= org.MaxNetworks {
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// checkHostQuota fails with ErrQuotaExceeded when the organization owning the network has no room for another host.
+func checkHostQuota(tx *gorm.DB, networkID uuid.UUID) error {
+	var n Network
+	if err := tx.Session(&gorm.Session{NewDB: true}).First(&n, "id = ?", networkID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return NewFieldError("networkId", "network not found")
+		}
+		return err
+	}
+
+	if n.OrganizationID == nil {
+		return nil
+	}
+
+	return checkHostsQuota(tx, *n.OrganizationID, 1)
+}
+
+// checkHostsQuota fails with ErrQuotaExceeded when the organization has no room for the given number of hosts.
+func checkHostsQuota(tx *gorm.DB, orgID uuid.UUID, hosts int64) error {
+	var org Organization
+	if err := tx.Session(&gorm.Session{NewDB: true}).First(&org, "id = ?", orgID).Error; err != nil {
+		return organizationNotFound(err)
+	}
+
+	if org.MaxHosts == 0 {
+		return nil
+	}
+
+	var count int64
+	err := tx.Session(&gorm.Session{NewDB: true}).Model(&Host{}).
+		Joins("JOIN networks ON networks.id = hosts.network_id").
+		Where("networks.organization_id = ? AND networks.deleted_at IS NULL", org.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if uint(count+hosts) > org.MaxHosts {
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// organizationNotFound reports a missing organization as invalid input rather than an internal error.
+func organizationNotFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NewFieldError("organizationId", "organization not found")
+	}
+
+	return err
+}
+
+// Validators
+func (o *Organization) validate() error {
+	if strings.TrimSpace(o.Name) == "" {
+		return NewFieldError("name", "name cannot be empty")
+	}
+
+	return nil
+}
+
+// Hooks
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	o.ID = uuid.New()
+
+	return nil
+}
+
+func (o *Organization) BeforeSave(tx *gorm.DB) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -1,43 +1,162 @@
 package models
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"math"
 	"strconv"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func Paginate(c *gin.Context) func(db *gorm.DB) *gorm.DB {
+// ListParams are the query parameters of a list request, which also receive the metadata of the page:
+// page, pageSize, total, totalPages and limit. *gin.Context implements it.
+type ListParams interface {
+	GetQuery(key string) (string, bool)
+	Set(key string, value any)
+}
+
+// Cursor is the position of a row in lists paged by cursor, which are ordered by (created_at, id).
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// Cursored is implemented by the items of lists that can be paged by cursor.
+type Cursored interface {
+	PageCursor() Cursor
+}
+
+// String encodes the cursor as an opaque, URL-safe token.
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor encoded by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, NewValidationError("invalid cursor")
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return c, NewValidationError("invalid cursor")
+	}
+
+	return c, nil
+}
+
+// Paginate pages a list by ?page and ?pageSize. The total is counted unless ?count=false.
+func Paginate(c ListParams) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		pageStr := c.DefaultQuery("page", "1")
-		pageSizeStr := c.DefaultQuery("pageSize", "10")
+		pageStr := defaultQuery(c, "page", "1")
+		pageSizeStr := defaultQuery(c, "pageSize", "10")
 
 		page, _ := strconv.Atoi(pageStr)
 		if page <= 0 {
 			page = 1
 		}
 
-		pageSize, _ := strconv.Atoi(pageSizeStr)
-		switch {
-		case pageSize > 100:
-			pageSize = 100
-		case pageSize <= 0:
-			pageSize = 10
-		}
-
-		dbClone := db.Session(&gorm.Session{})
-		var total int64
-		dbClone.Count(&total)
-
-		totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+		pageSize := pageLimit(pageSizeStr)
 
 		c.Set("page", page)
-		c.Set("total", int(total))
 		c.Set("pageSize", pageSize)
-		c.Set("totalPages", totalPages)
+
+		if wantCount(c, true) {
+			total := count(db)
+			c.Set("total", total)
+			c.Set("totalPages", int(math.Ceil(float64(total)/float64(pageSize))))
+		}
 
 		offset := (page - 1) * pageSize
 		return db.Offset(offset).Limit(pageSize)
 	}
 }
+
+// PaginateByCursor pages a list of table like Paginate, or by ?cursor and ?limit when either is given.
+// Cursor pages are ordered by (created_at, id) and start after the row of the cursor, so rows created
+// meanwhile are neither skipped nor seen twice. They cannot be combined with ?sort, and the total is only
+// counted with ?count=true. One row more than the limit is fetched, to tell whether there is a next page.
+func PaginateByCursor(c ListParams, table string) func(db *gorm.DB) *gorm.DB {
+	paginate := Paginate(c)
+
+	return func(db *gorm.DB) *gorm.DB {
+		cursorStr, hasCursor := c.GetQuery("cursor")
+		limitStr, hasLimit := c.GetQuery("limit")
+		if !hasCursor && !hasLimit {
+			return paginate(db)
+		}
+
+		if _, ok := c.GetQuery("sort"); ok {
+			db.AddError(NewValidationError("sort cannot be combined with cursor pagination"))
+			return db
+		}
+
+		limit := pageLimit(limitStr)
+		c.Set("limit", limit)
+
+		if wantCount(c, false) {
+			c.Set("total", count(db))
+		}
+
+		if cursorStr != "" {
+			cursor, err := ParseCursor(cursorStr)
+			if err != nil {
+				db.AddError(err)
+				return db
+			}
+
+			createdAt, id := table+".created_at", table+".id"
+			db = db.Where(createdAt+" > ? OR ("+createdAt+" = ? AND "+id+" > ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+
+		return db.Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Table: table, Name: "created_at"}, Reorder: true},
+			{Column: clause.Column{Table: table, Name: "id"}},
+		}}).Limit(limit + 1)
+	}
+}
+
+// pageLimit parses a page size, which defaults to 10 and is at most 100.
+func pageLimit(s string) int {
+	limit, _ := strconv.Atoi(s)
+	switch {
+	case limit > 100:
+		return 100
+	case limit <= 0:
+		return 10
+	}
+
+	return limit
+}
+
+func wantCount(c ListParams, def bool) bool {
+	value, _ := c.GetQuery("count")
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def
+	}
+
+	return b
+}
+
+func defaultQuery(c ListParams, key, def string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+
+	return def
+}
+
+func count(db *gorm.DB) int {
+	var total int64
+	db.Session(&gorm.Session{}).Count(&total)
+
+	return int(total)
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorEncoding(t *testing.T) {
+	c := Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 42, time.UTC), ID: uuid.New()}
+
+	s := c.String()
+	if strings.ContainsAny(s, "+/=") {
+		t.Errorf("cursor %q is not URL safe", s)
+	}
+
+	parsed, err := ParseCursor(s)
+	if err != nil || !parsed.CreatedAt.Equal(c.CreatedAt) || parsed.ID != c.ID {
+		t.Errorf("ParseCursor(%q) = %+v, %v, want %+v", s, parsed, err, c)
+	}
+
+	for _, invalid := range []string{
+		"not base64!",
+		"bm90IGpzb24",                           // not json
+		Cursor{CreatedAt: c.CreatedAt}.String(), // no id
+	} {
+		var verr ValidationError
+		if _, err := ParseCursor(invalid); !errors.As(err, &verr) {
+			t.Errorf("ParseCursor(%q) returned %v, want a ValidationError", invalid, err)
+		}
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	for s, want := range map[string]int{"": 10, "0": 10, "-5": 10, "abc": 10, "25": 25, "100": 100, "101": 100} {
+		if got := pageLimit(s); got != want {
+			t.Errorf("pageLimit(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestPaginateByCursor(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+
+	// The first two hosts were created at the same time, and are ordered by id
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	createTestHosts(t, db,
+		Host{ID: uuid.MustParse("00000000-0000-0000-0000-000000000002"), Name: "b", IP: "100.100.0.2/16", CreatedAt: created},
+		Host{ID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), Name: "a", IP: "100.100.0.1/16", CreatedAt: created},
+		Host{Name: "c", IP: "100.100.0.3/16", CreatedAt: created.Add(time.Second)},
+		Host{Name: "d", IP: "100.100.0.4/16", CreatedAt: created.Add(2 * time.Second)},
+		Host{Name: "e", IP: "100.100.0.5/16", CreatedAt: created.Add(3 * time.Second)},
+	)
+
+	var pages []string
+	query := map[string]string{"limit": "2"}
+	for {
+		params := newTestParams(query)
+
+		var hosts []Host
+		if err := db.Model(&Host{}).Scopes(PaginateByCursor(params, "hosts")).Find(&hosts).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if params.meta["limit"] != 2 {
+			t.Errorf("limit is %v, want 2", params.meta["limit"])
+		}
+		if _, ok := params.meta["total"]; ok {
+			t.Error("the total was counted without count=true")
+		}
+
+		// One row more than the limit tells whether there is a next page
+		if len(hosts) <= 2 {
+			pages = append(pages, fmt.Sprint(hostNames(hosts)))
+			break
+		}
+
+		hosts = hosts[:2]
+		pages = append(pages, fmt.Sprint(hostNames(hosts)))
+		query = map[string]string{"limit": "2", "cursor": hosts[1].PageCursor().String()}
+
+		if len(pages) > 3 {
+			t.Fatalf("got pages %v without reaching the end", pages)
+		}
+	}
+
+	if got, want := fmt.Sprint(pages), "[[a b] [c d] [e]]"; got != want {
+		t.Errorf("got pages %s, want %s", got, want)
+	}
+
+	// A row created meanwhile is on the next page of the cursor
+	createTestHosts(t, db, Host{Name: "f", IP: "100.100.0.6/16", CreatedAt: created.Add(time.Hour)})
+	params := newTestParams(query)
+
+	var hosts []Host
+	if err := db.Model(&Host{}).Scopes(PaginateByCursor(params, "hosts")).Find(&hosts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(hostNames(hosts)); got != "[e f]" {
+		t.Errorf("got %s after creating a host, want [e f]", got)
+	}
+}
+
+func TestPaginateByCursorCount(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+	createTestHosts(t, db, Host{Name: "a", IP: "100.100.0.1/16"}, Host{Name: "b", IP: "100.100.0.2/16"})
+
+	params := newTestParams(map[string]string{"limit": "1", "count": "true"})
+
+	var hosts []Host
+	if err := db.Model(&Host{}).Scopes(PaginateByCursor(params, "hosts")).Find(&hosts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if params.meta["total"] != 2 {
+		t.Errorf("total is %v, want 2", params.meta["total"])
+	}
+}
+
+func TestPaginateByCursorRejectsInvalidQueries(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+
+	for _, query := range []map[string]string{
+		{"cursor": "garbage"},
+		{"limit": "5", "sort": "name"},
+	} {
+		var hosts []Host
+		err := db.Model(&Host{}).Scopes(PaginateByCursor(newTestParams(query), "hosts")).Find(&hosts).Error
+
+		var invalid ValidationError
+		if !errors.As(err, &invalid) {
+			t.Errorf("%v: got %v, want a ValidationError", query, err)
+		}
+	}
+}
+
+func TestPaginateWithoutCursor(t *testing.T) {
+	db := newTestDB(t, &Host{}, &Configuration{})
+	createTestHosts(t, db, Host{Name: "a", IP: "100.100.0.1/16"}, Host{Name: "b", IP: "100.100.0.2/16"}, Host{Name: "c", IP: "100.100.0.3/16"})
+
+	params := newTestParams(map[string]string{"page": "2", "pageSize": "2", "sort": "name"})
+
+	var hosts []Host
+	err := db.Model(&Host{}).Scopes(Sort(params, HostQuery), PaginateByCursor(params, "hosts")).Find(&hosts).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(hostNames(hosts)); got != "[c]" {
+		t.Errorf("got %s, want [c]", got)
+	}
+	if params.meta["total"] != 3 || params.meta["totalPages"] != 2 || params.meta["page"] != 2 {
+		t.Errorf("got page metadata %v, want page 2 of 2 with 3 hosts", params.meta)
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitWindow counts the requests of a rate limit bucket in one fixed window. It lets API servers
+// behind a load balancer share their rate limits.
+type RateLimitWindow struct {
+	Bucket      string    `gorm:"size:255;primaryKey"` // Route class and API token or client IP.
+	WindowStart int64     `gorm:"primaryKey;autoIncrement:false"`
+	Count       int       `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// HitRateLimitWindow counts a request in the window of bucket that starts at start, in a single
+// statement so that concurrent servers don't lose counts. It returns the count including this request.
+func HitRateLimitWindow(db *gorm.DB, bucket string, start, end time.Time) (int, error) {
+	w := RateLimitWindow{Bucket: bucket, WindowStart: start.Unix(), Count: 1, ExpiresAt: end}
+
+	err := db.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "bucket"}, {Name: "window_start"}},
+			DoUpdates: clause.Assignments(map[string]any{"count": gorm.Expr("rate_limit_windows.count + 1")}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "count"}}},
+	).Create(&w).Error
+
+	return w.Count, err
+}
+
+// PurgeRateLimitWindows deletes the windows that ended before now.
+func PurgeRateLimitWindows(db *gorm.DB, now time.Time) error {
+	return db.Where("expires_at < ?", now).Delete(&RateLimitWindow{}).Error
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Network roles
+const (
+	RoleOwner    = "owner"    // Full control of the network, including deleting it and granting roles.
+	RoleOperator = "operator" // Manages the network's settings and hosts, and reads their keys.
+	RoleViewer   = "viewer"   // Reads the network and its hosts, without keys.
+	RoleEnroller = "enroller" // Enrolls new hosts and downloads their config.
+)
+
+// Actions on a network and its hosts that roles grant
+const (
+	ActionNetworkRead   = "network:read"
+	ActionNetworkUpdate = "network:update"
+	ActionNetworkDelete = "network:delete"
+	ActionHostRead      = "host:read"
+	ActionHostCreate    = "host:create"
+	ActionHostUpdate    = "host:update"
+	ActionHostDelete    = "host:delete"
+	ActionKeysRead      = "keys:read"
+	ActionRolesManage   = "roles:manage"
+)
+
+var Roles = []string{RoleOwner, RoleOperator, RoleViewer, RoleEnroller}
+
+var rolePermissions = map[string][]string{
+	RoleOwner: {
+		ActionNetworkRead, ActionNetworkUpdate, ActionNetworkDelete,
+		ActionHostRead, ActionHostCreate, ActionHostUpdate, ActionHostDelete,
+		ActionKeysRead, ActionRolesManage,
+	},
+	RoleOperator: {
+		ActionNetworkRead, ActionNetworkUpdate,
+		ActionHostRead, ActionHostCreate, ActionHostUpdate, ActionHostDelete,
+		ActionKeysRead,
+	},
+	RoleViewer: {
+		ActionNetworkRead, ActionHostRead,
+	},
+	RoleEnroller: {
+		ActionNetworkRead, ActionHostCreate, ActionKeysRead,
+	},
+}
+
+// RoleBinding grants an API token a role on a network.
+type RoleBinding struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	NetworkID uuid.UUID `json:"networkId" gorm:"type:uuid;not null;uniqueIndex:idx_role_network_token"`
+	Network   *Network  `json:"network,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	TokenID   uuid.UUID `json:"tokenId" gorm:"type:uuid;not null;uniqueIndex:idx_role_network_token;index"`
+	Role      string    `json:"role" gorm:"size:32;not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// DTO for create operations
+type RoleBindingDto struct {
+	TokenID uuid.UUID `json:"tokenId" binding:"required" example:"c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"`
+	Role    string    `json:"role" binding:"required" example:"viewer" enums:"owner,operator,viewer,enroller"`
+}
+
+// RoleAllows reports whether role grants action.
+func RoleAllows(role, action string) bool {
+	return slices.Contains(rolePermissions[role], action)
+}
+
+// rolesAllowing returns every role that grants action.
+func rolesAllowing(action string) []string {
+	var roles []string
+	for _, role := range Roles {
+		if RoleAllows(role, action) {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// Authorize reports whether the token may perform action on the network.
+// Tokens confined to an organization may only act on its networks. Within that
+// boundary, tokens with the admin scope may do anything on every network.
+func Authorize(db *gorm.DB, t *APIToken, networkID uuid.UUID, action string) (bool, error) {
+	if t == nil {
+		return false, nil
+	}
+
+	if t.OrganizationID != nil {
+		// Deleted networks still belong to their organization, for restoring them
+		var n Network
+		if err := db.Unscoped().Select("id", "organization_id").First(&n, "id = ?", networkID).Error; err != nil {
+			return false, err
+		}
+
+		if !t.InOrganization(n.OrganizationID) {
+			return false, nil
+		}
+	}
+
+	if t.IsAdmin() {
+		return true, nil
+	}
+
+	var count int64
+	err := db.Model(&RoleBinding{}).
+		Where("network_id = ? AND token_id = ? AND role IN ?", networkID, t.ID, rolesAllowing(action)).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// NetworksAllowing is a subquery of the IDs of the networks on which the token may perform action.
+// It must not be used for platform-wide admin tokens, which may act on every network.
+// Deleted networks are included when db is unscoped.
+func NetworksAllowing(db *gorm.DB, t *APIToken, action string) *gorm.DB {
+	tx := db.Session(&gorm.Session{NewDB: true})
+	if db.Statement.Unscoped {
+		tx = tx.Unscoped()
+	}
+
+	if t.IsAdmin() {
+		return tx.Model(&Network{}).Select("id").Where("organization_id = ?", t.OrganizationID)
+	}
+
+	// Roles are only granted within the token's organization
+	return tx.Model(&RoleBinding{}).
+		Select("network_id").
+		Where("token_id = ? AND role IN ?", t.ID, rolesAllowing(action))
+}
+
+// Validators
+func (b *RoleBinding) validate() error {
+	if !slices.Contains(Roles, b.Role) {
+		return NewFieldError("role", "invalid role: "+b.Role+"; valid options are 'owner', 'operator', 'viewer' or 'enroller'")
+	}
+
+	return nil
+}
+
+// Hooks
+func (b *RoleBinding) BeforeCreate(tx *gorm.DB) error {
+	b.ID = uuid.New()
+
+	return nil
+}
+
+func (b *RoleBinding) BeforeSave(tx *gorm.DB) error {
+	if err := b.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
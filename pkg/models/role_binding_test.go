@@ -0,0 +1,190 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var allActions = []string{
+	ActionNetworkRead, ActionNetworkUpdate, ActionNetworkDelete,
+	ActionHostRead, ActionHostCreate, ActionHostUpdate, ActionHostDelete,
+	ActionKeysRead, ActionRolesManage,
+}
+
+// newTestDB returns an in-memory SQLite database with the tables of models.
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: is another database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// createTestNetworks inserts networks without their hooks, which would sign a CA for each.
+func createTestNetworks(t *testing.T, db *gorm.DB, orgID *uuid.UUID, names ...string) []Network {
+	t.Helper()
+
+	networks := make([]Network, len(names))
+	for i, name := range names {
+		networks[i] = Network{ID: uuid.New(), Name: name, OrganizationID: orgID}
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Omit("Ca", "Hosts").Create(&networks[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return networks
+}
+
+func bindTestRole(t *testing.T, db *gorm.DB, token *APIToken, n Network, role string) {
+	t.Helper()
+
+	if err := db.Create(&RoleBinding{NetworkID: n.ID, TokenID: token.ID, Role: role}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthorizeRoles(t *testing.T) {
+	db := newTestDB(t, &Network{}, &RoleBinding{})
+	networks := createTestNetworks(t, db, nil, "office", "other")
+
+	allowed := map[string][]string{
+		RoleOwner:    allActions,
+		RoleOperator: {ActionNetworkRead, ActionNetworkUpdate, ActionHostRead, ActionHostCreate, ActionHostUpdate, ActionHostDelete, ActionKeysRead},
+		RoleViewer:   {ActionNetworkRead, ActionHostRead},
+		RoleEnroller: {ActionNetworkRead, ActionHostCreate, ActionKeysRead},
+	}
+
+	for _, role := range Roles {
+		token := &APIToken{ID: uuid.New(), Scopes: []string{ScopeNetworksRead}}
+		bindTestRole(t, db, token, networks[0], role)
+
+		for _, action := range allActions {
+			ok, err := Authorize(db, token, networks[0].ID, action)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := slices.Contains(allowed[role], action); ok != want {
+				t.Errorf("%s may %s: got %v, want %v", role, action, ok, want)
+			}
+
+			// Roles are bound to their network
+			if ok, _ := Authorize(db, token, networks[1].ID, action); ok {
+				t.Errorf("%s of another network may %s", role, action)
+			}
+		}
+	}
+}
+
+func TestAuthorizeTokens(t *testing.T) {
+	db := newTestDB(t, &Network{}, &RoleBinding{})
+
+	orgID, otherOrgID := uuid.New(), uuid.New()
+	n := createTestNetworks(t, db, &orgID, "office")[0]
+
+	tests := []struct {
+		name  string
+		token *APIToken
+		want  bool
+	}{
+		{"no token", nil, false},
+		{"no binding", &APIToken{ID: uuid.New(), Scopes: []string{ScopeNetworksWrite}}, false},
+		{"platform admin", &APIToken{ID: uuid.New(), Scopes: []string{ScopeAdmin}}, true},
+		{"organization admin", &APIToken{ID: uuid.New(), Scopes: []string{ScopeAdmin}, OrganizationID: &orgID}, true},
+		{"admin of another organization", &APIToken{ID: uuid.New(), Scopes: []string{ScopeAdmin}, OrganizationID: &otherOrgID}, false},
+	}
+
+	for _, tt := range tests {
+		for _, action := range allActions {
+			ok, err := Authorize(db, tt.token, n.ID, action)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok != tt.want {
+				t.Errorf("%s may %s: got %v, want %v", tt.name, action, ok, tt.want)
+			}
+		}
+	}
+
+	// Owners of a network in another organization lose it to the organization boundary
+	token := &APIToken{ID: uuid.New(), Scopes: []string{ScopeNetworksRead}, OrganizationID: &otherOrgID}
+	bindTestRole(t, db, token, n, RoleOwner)
+
+	if ok, _ := Authorize(db, token, n.ID, ActionNetworkRead); ok {
+		t.Error("an owner confined to another organization may read the network")
+	}
+}
+
+func TestAccessibleByHidesNetworksWithoutBinding(t *testing.T) {
+	db := newTestDB(t, &Network{}, &RoleBinding{})
+
+	orgID := uuid.New()
+	networks := createTestNetworks(t, db, nil, "viewed", "enrolled", "unbound")
+	createTestNetworks(t, db, &orgID, "organization")
+
+	token := &APIToken{ID: uuid.New(), Scopes: []string{ScopeNetworksRead}}
+	bindTestRole(t, db, token, networks[0], RoleViewer)
+	bindTestRole(t, db, token, networks[1], RoleEnroller)
+
+	accessible := func(token *APIToken, action string) string {
+		var names []string
+		if err := db.Model(&Network{}).Scopes(AccessibleBy(token, "networks.id", action)).Pluck("name", &names).Error; err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(names)
+
+		return fmt.Sprint(names)
+	}
+
+	tests := []struct {
+		name   string
+		token  *APIToken
+		action string
+		want   string
+	}{
+		{"no token", nil, ActionNetworkRead, "[]"},
+		{"bound token", token, ActionNetworkRead, "[enrolled viewed]"},
+		{"viewer", token, ActionHostRead, "[viewed]"},
+		{"enroller", token, ActionKeysRead, "[enrolled]"},
+		{"no role allows it", token, ActionNetworkDelete, "[]"},
+		{"platform admin", &APIToken{ID: uuid.New(), Scopes: []string{ScopeAdmin}}, ActionNetworkDelete, "[enrolled organization unbound viewed]"},
+		{"organization admin", &APIToken{ID: uuid.New(), Scopes: []string{ScopeAdmin}, OrganizationID: &orgID}, ActionNetworkDelete, "[organization]"},
+	}
+
+	for _, tt := range tests {
+		if got := accessible(tt.token, tt.action); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// The subquery of NetworksAllowing is the same for other queries
+	var ids []uuid.UUID
+	if err := db.Model(&RoleBinding{}).Where("network_id IN (?)", NetworksAllowing(db, token, ActionHostCreate)).Pluck("network_id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != networks[1].ID {
+		t.Errorf("NetworksAllowing(host:create) = %v, want the network of the enroller", ids)
+	}
+}
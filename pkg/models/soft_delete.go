@@ -0,0 +1,205 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrNetworkDeleted = errors.New("the network of the host is deleted, restore the network first")
+
+// SoftDelete marks the network and its hosts as deleted at the same time, so that they can be restored together.
+// Their CAs, certificates and configurations are kept until PurgeDeleted removes them.
+func (n *Network) SoftDelete(tx *gorm.DB) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	if err := tx.Model(n).UpdateColumn("deleted_at", now).Error; err != nil {
+		return err
+	}
+
+	n.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+
+	return tx.Model(&Host{}).Where("network_id = ?", n.ID).UpdateColumn("deleted_at", now).Error
+}
+
+// Restore restores the deleted network together with the hosts that were deleted with it.
+// It fails with ErrQuotaExceeded when the organization has no room for the network and its hosts,
+// and with gorm.ErrDuplicatedKey when a network or host with the same name or IP was created meanwhile.
+func (n *Network) Restore(tx *gorm.DB) error {
+	// The hosts deleted with the network
+	hosts := func() *gorm.DB {
+		return tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Host{}).
+			Where("network_id = ? AND deleted_at >= ?", n.ID, n.DeletedAt.Time)
+	}
+
+	if n.OrganizationID != nil {
+		if err := checkNetworkQuota(tx, *n.OrganizationID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := hosts().Count(&count).Error; err != nil {
+			return err
+		}
+
+		if err := checkHostsQuota(tx, *n.OrganizationID, count); err != nil {
+			return err
+		}
+	}
+
+	if err := hosts().UpdateColumn("deleted_at", nil).Error; err != nil {
+		return err
+	}
+
+	n.DeletedAt = gorm.DeletedAt{}
+
+	return tx.Unscoped().Model(n).UpdateColumn("deleted_at", nil).Error
+}
+
+// Restore restores the deleted host. It fails with ErrNetworkDeleted while the network of the host is deleted,
+// and with gorm.ErrDuplicatedKey when a host with the same name or IP was created meanwhile.
+func (h *Host) Restore(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&Network{}).Where("id = ?", h.NetworkID).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNetworkDeleted
+	}
+
+	if err := checkHostQuota(tx, h.NetworkID); err != nil {
+		return err
+	}
+
+	h.DeletedAt = gorm.DeletedAt{}
+
+	return tx.Unscoped().Model(h).UpdateColumn("deleted_at", nil).Error
+}
+
+// WithoutDeletedOwners leaves out the certificates of deleted networks and hosts, which are kept until purged.
+func WithoutDeletedOwners(db *gorm.DB) *gorm.DB {
+	networks := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Network{}).Select("id").Where("deleted_at IS NOT NULL")
+	hosts := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Host{}).Select("id").Where("deleted_at IS NOT NULL")
+
+	return db.Where("certificates.owner_id NOT IN (?) AND certificates.owner_id NOT IN (?)", networks, hosts)
+}
+
+// purgeActor is the actor name of the audit events of purged resources.
+const purgeActor = "purge"
+
+// PurgeDeleted removes the networks and hosts deleted before the cutoff for good, with their CAs,
+// certificates, configurations and role bindings, and records their deletion in the audit log.
+// It returns the number of networks and hosts purged.
+func PurgeDeleted(db *gorm.DB, before time.Time) (int, error) {
+	purged := 0
+
+	var networkIDs []uuid.UUID
+	if err := db.Unscoped().Model(&Network{}).Where("deleted_at < ?", before).Pluck("id", &networkIDs).Error; err != nil {
+		return purged, err
+	}
+
+	for _, id := range networkIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var n Network
+			if err := tx.Unscoped().First(&n, "id = ?", id).Error; err != nil {
+				return err
+			}
+
+			var hosts []Host
+			if err := tx.Unscoped().Where("network_id = ?", id).Find(&hosts).Error; err != nil {
+				return err
+			}
+
+			for i := range hosts {
+				if err := purgeHost(tx, &hosts[i]); err != nil {
+					return err
+				}
+			}
+
+			if err := purgeCertificates(tx, id, id); err != nil {
+				return err
+			}
+
+			if err := tx.Where("network_id = ?", id).Delete(&RoleBinding{}).Error; err != nil {
+				return err
+			}
+
+			if err := recordPurge(tx, AuditResourceNetwork, id, id, n); err != nil {
+				return err
+			}
+
+			return tx.Unscoped().Delete(&n).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	var hostIDs []uuid.UUID
+	if err := db.Unscoped().Model(&Host{}).Where("deleted_at < ?", before).Pluck("id", &hostIDs).Error; err != nil {
+		return purged, err
+	}
+
+	for _, id := range hostIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var h Host
+			if err := tx.Unscoped().First(&h, "id = ?", id).Error; err != nil {
+				return err
+			}
+
+			return purgeHost(tx, &h)
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+func purgeHost(tx *gorm.DB, h *Host) error {
+	if err := purgeCertificates(tx, h.ID, h.NetworkID); err != nil {
+		return err
+	}
+
+	if err := recordPurge(tx, AuditResourceHost, h.ID, h.NetworkID, *h); err != nil {
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(h).Error; err != nil {
+		return err
+	}
+
+	return tx.Delete(&Configuration{}, "id = ?", h.ConfigurationID).Error
+}
+
+func purgeCertificates(tx *gorm.DB, ownerID, networkID uuid.UUID) error {
+	var certs []Certificate
+	if err := tx.Where("owner_id = ?", ownerID).Find(&certs).Error; err != nil {
+		return err
+	}
+
+	for _, crt := range certs {
+		if err := recordPurge(tx, AuditResourceCertificate, crt.ID, networkID, crt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Where("owner_id = ?", ownerID).Delete(&Certificate{}).Error
+}
+
+func recordPurge(tx *gorm.DB, resourceType string, id, networkID uuid.UUID, before any) error {
+	e, err := NewAuditEvent(AuditActionDelete, resourceType, id, &networkID, before, nil)
+	if err != nil {
+		return err
+	}
+
+	e.ActorName = purgeActor
+
+	return RecordAuditEvent(tx, &e)
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DTO for toggling the nebula sshd across the hosts of a network
+type SSHDToggleDto struct {
+	Enabled bool     `json:"enabled" example:"true"`
+	Groups  []string `json:"groups,omitempty" example:"servers"`        // Only hosts in at least one of these groups are changed. Empty means all hosts.
+	Listen  string   `json:"listen,omitempty" example:"127.0.0.1:2222"` // Optional listen address to set on the changed hosts.
+}
+
+// newSSHHostKey generates an ed25519 host key for the nebula sshd.
+// It returns the OpenSSH PEM encoded private key and the public key in authorized_keys format.
+func newSSHHostKey(comment string) ([]byte, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating ssh host key: %s", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshalling ssh host key: %s", err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshalling ssh host public key: %s", err)
+	}
+
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+
+	return pem.EncodeToMemory(block), authorizedKey, nil
+}
+
+// GenerateSSHHostKey sets a new ed25519 sshd host key on the host.
+func (h *Host) GenerateSSHHostKey() error {
+	key, pub, err := newSSHHostKey(h.Name)
+	if err != nil {
+		return err
+	}
+
+	h.SSHHostKey = key
+	h.SSHHostPub = pub
+
+	return nil
+}
+
+// InGroups reports whether the host is a member of at least one of the groups.
+// An empty list matches every host.
+func (h *Host) InGroups(groups []string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+
+	for _, group := range groups {
+		if slices.Contains(h.Groups, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeAuthorizedUsers merges the network's ssh admin users into the host's own,
+// combining the keys of users that appear in both.
+func mergeAuthorizedUsers(host, network []configAuthorizedUser) []configAuthorizedUser {
+	merged := make([]configAuthorizedUser, 0, len(host)+len(network))
+	index := make(map[string]int)
+
+	for _, user := range append(slices.Clone(host), network...) {
+		i, found := index[user.Name]
+		if !found {
+			index[user.Name] = len(merged)
+			merged = append(merged, configAuthorizedUser{Name: user.Name, Keys: slices.Clone(user.Keys)})
+			continue
+		}
+
+		for _, key := range user.Keys {
+			if !slices.Contains(merged[i].Keys, key) {
+				merged[i].Keys = append(merged[i].Keys, key)
+			}
+		}
+	}
+
+	return merged
+}
+
+func validateSSHUsers(users []configAuthorizedUser) error {
+	for i, user := range users {
+		if strings.TrimSpace(user.Name) == "" {
+			return NewFieldError(fmt.Sprintf("[%d].name", i), "ssh user names cannot be empty")
+		}
+
+		if len(user.Keys) == 0 {
+			return NewFieldError(fmt.Sprintf("[%d].keys", i), "ssh user "+user.Name+" needs at least one public key")
+		}
+
+		for j, key := range user.Keys {
+			if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+				return NewFieldError(fmt.Sprintf("[%d].keys[%d]", i, j), "invalid ssh public key for user "+user.Name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Address families of a static address.
+const (
+	FamilyIPv4 = "ip4"
+	FamilyIPv6 = "ip6"
+	FamilyDNS  = "dns"
+)
+
+// StaticAddress is a routable address (IP or DNS name) a host can be reached on from the internet
+// or any other underlay network. It is rendered into the static_host_map of the other hosts.
+type StaticAddress struct {
+	// Host is an IPv4/IPv6 literal or a DNS name. DNS names are resolved by nebula according to static_map.
+	Host string `json:"host" example:"109.243.69.39"`
+
+	// Port is the routable port, e.g. a NAT-mapped port. When 0, the host's listen port is used.
+	Port uint `json:"port,omitempty" example:"4242"`
+
+	// Family is the address family: "ip4", "ip6" or "dns". It is derived from Host when empty.
+	Family string `json:"family,omitempty" example:"ip4" enums:"ip4,ip6,dns"`
+}
+
+// ParseStaticAddress parses "host", "host:port" or "[ipv6]:port" into a StaticAddress.
+func ParseStaticAddress(s string) (StaticAddress, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return StaticAddress{}, NewValidationError("static address cannot be empty")
+	}
+
+	// A bare IPv6 literal contains colons but no port
+	if ip := net.ParseIP(strings.Trim(s, "[]")); ip != nil {
+		return StaticAddress{Host: ip.String()}, nil
+	}
+
+	if !strings.Contains(s, ":") {
+		return StaticAddress{Host: s}, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return StaticAddress{}, NewValidationError("invalid static address: " + s)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return StaticAddress{}, NewValidationError("invalid static address port: " + s)
+	}
+
+	return StaticAddress{Host: host, Port: uint(port)}, nil
+}
+
+// UnmarshalJSON accepts both the structured form and a plain "host[:port]" string,
+// which is how static addresses were stored before they were structured.
+func (a *StaticAddress) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := ParseStaticAddress(s)
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	}
+
+	type staticAddress StaticAddress
+	var v staticAddress
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = StaticAddress(v)
+
+	return nil
+}
+
+// DetectFamily returns the address family of the host part of the address.
+func (a StaticAddress) DetectFamily() string {
+	ip := net.ParseIP(a.Host)
+	switch {
+	case ip == nil:
+		return FamilyDNS
+	case ip.To4() != nil:
+		return FamilyIPv4
+	default:
+		return FamilyIPv6
+	}
+}
+
+// IsHostname reports whether the address is a DNS name rather than an IP literal.
+func (a StaticAddress) IsHostname() bool {
+	return a.DetectFamily() == FamilyDNS
+}
+
+// Addr renders the address as "host:port", bracketing IPv6 literals.
+// defaultPort is used when the address has no port of its own.
+func (a StaticAddress) Addr(defaultPort uint) string {
+	port := a.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	return net.JoinHostPort(a.Host, strconv.FormatUint(uint64(port), 10))
+}
+
+func (a StaticAddress) String() string {
+	if a.Port == 0 {
+		return a.Host
+	}
+
+	return a.Addr(a.Port)
+}
+
+func (a *StaticAddress) validate() error {
+	a.Host = strings.Trim(strings.TrimSpace(a.Host), "[]")
+	if a.Host == "" {
+		return NewFieldError("host", "static address host cannot be empty")
+	}
+
+	if a.Port > 65535 {
+		return NewFieldError("port", fmt.Sprintf("invalid static address port: %d", a.Port))
+	}
+
+	detected := a.DetectFamily()
+	if detected == FamilyDNS && !isValidHostname(a.Host) {
+		return NewFieldError("host", "invalid static address hostname: "+a.Host)
+	}
+
+	switch a.Family {
+	case "":
+		a.Family = detected
+	case FamilyIPv4, FamilyIPv6, FamilyDNS:
+		if a.Family != detected {
+			return NewFieldError("family", fmt.Sprintf("static address %s is not of family %s", a.Host, a.Family))
+		}
+	default:
+		return NewFieldError("family", "invalid static address family; valid options are 'ip4', 'ip6' or 'dns'")
+	}
+
+	return nil
+}
+
+// isValidHostname checks a DNS name against RFC 1123.
+func isValidHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseStaticAddress(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    StaticAddress
+		wantErr bool
+	}{
+		{in: "109.243.69.39", want: StaticAddress{Host: "109.243.69.39"}},
+		{in: "109.243.69.39:4242", want: StaticAddress{Host: "109.243.69.39", Port: 4242}},
+		{in: " lighthouse.example.com:4242 ", want: StaticAddress{Host: "lighthouse.example.com", Port: 4242}},
+		{in: "lighthouse.example.com", want: StaticAddress{Host: "lighthouse.example.com"}},
+		{in: "2001:db8::1", want: StaticAddress{Host: "2001:db8::1"}},
+		{in: "[2001:db8::1]", want: StaticAddress{Host: "2001:db8::1"}},
+		{in: "[2001:db8::1]:4242", want: StaticAddress{Host: "2001:db8::1", Port: 4242}},
+		{in: "", wantErr: true},
+		{in: "example.com:port", wantErr: true},
+		{in: "example.com:70000", wantErr: true},
+		{in: "a:b:c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStaticAddress(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseStaticAddress(%q) = %+v, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("ParseStaticAddress(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestStaticAddressUnmarshalJSON(t *testing.T) {
+	var addrs []StaticAddress
+	data := `["1.2.3.4:4242", {"host": "example.com", "port": 5353, "family": "dns"}]`
+	if err := json.Unmarshal([]byte(data), &addrs); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []StaticAddress{
+		{Host: "1.2.3.4", Port: 4242},
+		{Host: "example.com", Port: 5353, Family: FamilyDNS},
+	}
+	if len(addrs) != len(want) || addrs[0] != want[0] || addrs[1] != want[1] {
+		t.Errorf("got %+v, want %+v", addrs, want)
+	}
+}
+
+func TestStaticAddressValidate(t *testing.T) {
+	tests := []struct {
+		addr       StaticAddress
+		wantFamily string
+		wantField  string
+	}{
+		{addr: StaticAddress{Host: "1.2.3.4"}, wantFamily: FamilyIPv4},
+		{addr: StaticAddress{Host: "[2001:db8::1]"}, wantFamily: FamilyIPv6},
+		{addr: StaticAddress{Host: "lighthouse.example.com."}, wantFamily: FamilyDNS},
+		{addr: StaticAddress{Host: " "}, wantField: "host"},
+		{addr: StaticAddress{Host: "-bad-.example.com"}, wantField: "host"},
+		{addr: StaticAddress{Host: "1.2.3.4", Port: 65536}, wantField: "port"},
+		{addr: StaticAddress{Host: "1.2.3.4", Family: FamilyIPv6}, wantField: "family"},
+		{addr: StaticAddress{Host: "1.2.3.4", Family: "ipx"}, wantField: "family"},
+	}
+
+	for _, tt := range tests {
+		addr := tt.addr
+		err := addr.validate()
+
+		if tt.wantField != "" {
+			invalid, ok := err.(ValidationError)
+			if !ok || invalid.Field != tt.wantField {
+				t.Errorf("validate(%+v) = %v, want an error of field %s", tt.addr, err, tt.wantField)
+			}
+			continue
+		}
+
+		if err != nil || addr.Family != tt.wantFamily {
+			t.Errorf("validate(%+v) = %v with family %q, want family %q", tt.addr, err, addr.Family, tt.wantFamily)
+		}
+	}
+}
+
+func TestStaticAddressAddr(t *testing.T) {
+	tests := []struct {
+		addr StaticAddress
+		want string
+	}{
+		{StaticAddress{Host: "1.2.3.4"}, "1.2.3.4:4242"},
+		{StaticAddress{Host: "1.2.3.4", Port: 5000}, "1.2.3.4:5000"},
+		{StaticAddress{Host: "2001:db8::1"}, "[2001:db8::1]:4242"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.addr.Addr(4242); got != tt.want {
+			t.Errorf("%+v.Addr(4242) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
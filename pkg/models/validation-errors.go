@@ -1,16 +1,22 @@
 package models
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
-var Errors = map[error]struct {
+// ErrorInfo is how the API reports an error.
+type ErrorInfo struct {
 	Status  int
 	Code    string
 	Message string
-}{
+}
+
+// Errors maps the errors the API reports with a specific status. Errors wrapping them are reported the same way.
+var Errors = map[error]ErrorInfo{
 	gorm.ErrRecordNotFound: {
 		Status:  http.StatusNotFound,
 		Code:    "ERR_NOT_FOUND",
@@ -121,9 +127,82 @@ var Errors = map[error]struct {
 		Code:    "ERR_CHECK_CONSTRAINT_VIOLATED",
 		Message: "The operation violates a database check constraint.",
 	},
+	ErrQuotaExceeded: {
+		Status:  http.StatusForbidden,
+		Code:    "ERR_QUOTA_EXCEEDED",
+		Message: "The organization has reached its quota.",
+	},
+	ErrOrganizationNotEmpty: {
+		Status:  http.StatusConflict,
+		Code:    "ERR_ORGANIZATION_NOT_EMPTY",
+		Message: "The organization still has networks, including deleted ones. Delete and purge them first.",
+	},
+	ErrNetworkFull: {
+		Status:  http.StatusConflict,
+		Code:    "ERR_NETWORK_FULL",
+		Message: "The network has no free IP addresses left.",
+	},
+	ErrNetworkDeleted: {
+		Status:  http.StatusConflict,
+		Code:    "ERR_NETWORK_DELETED",
+		Message: "The network of the host is deleted. Restore the network first.",
+	},
+	ErrExportPassphraseRequired: {
+		Status:  http.StatusBadRequest,
+		Code:    "ERR_PASSPHRASE_REQUIRED",
+		Message: "The export is encrypted. Send its passphrase in the X-Koodnet-Passphrase header.",
+	},
+	ErrExportPassphraseInvalid: {
+		Status:  http.StatusBadRequest,
+		Code:    "ERR_INVALID_PASSPHRASE",
+		Message: "The passphrase does not decrypt the export.",
+	},
+	ErrExportUnsupported: {
+		Status:  http.StatusUnprocessableEntity,
+		Code:    "ERR_UNSUPPORTED_EXPORT",
+		Message: "The export is not a network export of a supported version.",
+	},
+	ErrIdempotencyKeyInProgress: {
+		Status:  http.StatusConflict,
+		Code:    "ERR_IDEMPOTENCY_IN_PROGRESS",
+		Message: "A request with the same idempotency key is still being processed. Retry later.",
+	},
+	ErrIdempotencyKeyReused: {
+		Status:  http.StatusUnprocessableEntity,
+		Code:    "ERR_IDEMPOTENCY_KEY_REUSED",
+		Message: "The idempotency key was already used with a different request body.",
+	},
+	ErrVersionConflict: {
+		Status:  http.StatusPreconditionFailed,
+		Code:    "ERR_PRECONDITION_FAILED",
+		Message: "The resource was modified by another request. Fetch it again and retry.",
+	},
+	ErrAuditEventImmutable: {
+		Status:  http.StatusConflict,
+		Code:    "ERR_AUDIT_IMMUTABLE",
+		Message: "The audit log is append-only.",
+	},
+}
+
+// LookupError returns how the API reports err, or an error it wraps.
+func LookupError(err error) (ErrorInfo, bool) {
+	if info, found := Errors[err]; found {
+		return info, true
+	}
+
+	for target, info := range Errors {
+		if errors.Is(err, target) {
+			return info, true
+		}
+	}
+
+	return ErrorInfo{}, false
 }
 
+// ValidationError is an invalid value in a request. Field is the JSON path of the value,
+// e.g. "staticAddresses[0].port", or empty when the error is not about a single field.
 type ValidationError struct {
+	Field   string
 	Message string
 }
 
@@ -134,3 +213,32 @@ func (e ValidationError) Error() string {
 func NewValidationError(message string) ValidationError {
 	return ValidationError{Message: message}
 }
+
+// NewFieldError creates a ValidationError of the value at the JSON path field.
+func NewFieldError(field, message string) ValidationError {
+	return ValidationError{Field: field, Message: message}
+}
+
+// Within nests the field of the error under prefix, e.g. "staticAddresses[0]".
+func (e ValidationError) Within(prefix string) ValidationError {
+	switch {
+	case e.Field == "":
+		e.Field = prefix
+	case strings.HasPrefix(e.Field, "["):
+		e.Field = prefix + e.Field
+	default:
+		e.Field = prefix + "." + e.Field
+	}
+
+	return e
+}
+
+// withinField nests the field of err under prefix when it is a ValidationError, and returns other errors as is.
+func withinField(err error, prefix string) error {
+	var invalid ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Within(prefix)
+	}
+
+	return err
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrVersionConflict = errors.New("the resource was modified since it was read")
+
+// BumpVersion increments the version of a network, host or configuration, provided it is still
+// at version. It fails with ErrVersionConflict when another update came first.
+func BumpVersion(tx *gorm.DB, model any, id uuid.UUID, version uint64) error {
+	result := tx.Session(&gorm.Session{NewDB: true}).Model(model).
+		Where("id = ? AND version = ?", id, version).
+		UpdateColumn("version", gorm.Expr("version + 1"))
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// ETag returns the entity tag of the network.
+func (n *Network) ETag() string {
+	return strconv.Quote(strconv.FormatUint(n.Version, 10))
+}
+
+// ETag returns the entity tag of the host, which changes with the host and its configuration.
+func (h *Host) ETag() string {
+	var configVersion uint64
+	if h.Configuration != nil {
+		configVersion = h.Configuration.Version
+	}
+
+	return strconv.Quote(strconv.FormatUint(h.Version, 10) + "." + strconv.FormatUint(configVersion, 10))
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook events
+const (
+	WebhookEventHostCreated         = "host.created"
+	WebhookEventHostUpdated         = "host.updated"
+	WebhookEventHostDeleted         = "host.deleted"
+	WebhookEventHostRestored        = "host.restored"
+	WebhookEventCertificateIssued   = "certificate.issued"
+	WebhookEventCertificateExpiring = "certificate.expiring"
+	WebhookEventCARotated           = "ca.rotated"
+	WebhookEventConfigChanged       = "config.changed"
+)
+
+var WebhookEvents = []string{
+	WebhookEventHostCreated,
+	WebhookEventHostUpdated,
+	WebhookEventHostDeleted,
+	WebhookEventHostRestored,
+	WebhookEventCertificateIssued,
+	WebhookEventCertificateExpiring,
+	WebhookEventCARotated,
+	WebhookEventConfigChanged,
+}
+
+const (
+	webhookSecretPrefix      = "whsec_"
+	webhookSecretRandomBytes = 32
+)
+
+// Webhook is an endpoint that receives events as JSON POST requests, signed with its secret.
+type Webhook struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	Name           string     `json:"name" gorm:"size:255;not null"`
+	URL            string     `json:"url" gorm:"size:2048;not null"`
+	Secret         string     `json:"-" gorm:"size:255;not null"`                      // Key of the HMAC signature. Only returned when the webhook is created.
+	Events         []string   `json:"events" gorm:"serializer:json;default:'[]'"`      // Events to send. Empty means all.
+	NetworkID      *uuid.UUID `json:"networkId,omitempty" gorm:"type:uuid;index"`      // Only send events of this network.
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty" gorm:"type:uuid;index"` // Only send events of this organization's networks. Webhooks without one get the events of every network.
+	Active         bool       `json:"active" gorm:"not null"`                          // Inactive webhooks get no new events.
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// DTO for create/update operations
+type WebhookDto struct {
+	Name      string     `json:"name,omitempty" example:"deploy"`
+	URL       string     `json:"url,omitempty" example:"https://ci.example.com/hooks/koodnet"`
+	Events    []string   `json:"events,omitempty" example:"config.changed,host.deleted"`
+	NetworkID *uuid.UUID `json:"networkId,omitempty" example:"c6d6c4c4-b65b-40e1-bcf2-1fd3122c653d"`
+	Active    *bool      `json:"active,omitempty" example:"true"`
+}
+
+// WebhookEvent is the JSON body of webhook requests.
+type WebhookEvent struct {
+	ID             uuid.UUID  `json:"id"` // Same for every webhook the event is sent to.
+	Type           string     `json:"type" example:"config.changed"`
+	NetworkID      uuid.UUID  `json:"networkId"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty"`
+	Data           any        `json:"data"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// NewWebhookSecret generates a signing secret.
+func NewWebhookSecret() (string, error) {
+	buf := make([]byte, webhookSecretRandomBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Signature signs a request body sent at timestamp, in the format of the X-Koodnet-Signature header:
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">".
+func (w *Webhook) Signature(timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Subscribed reports whether the webhook wants the event.
+func (w *Webhook) Subscribed(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// webhooksFor returns the active webhooks that want event of the network, and the network's organization.
+func webhooksFor(tx *gorm.DB, event string, networkID uuid.UUID) ([]Webhook, *uuid.UUID, error) {
+	var n Network
+	if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Select("id", "organization_id").First(&n, "id = ?", networkID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	query := tx.Session(&gorm.Session{NewDB: true}).
+		Where("active = ?", true).
+		Where("network_id IS NULL OR network_id = ?", networkID)
+
+	if n.OrganizationID != nil {
+		query = query.Where("organization_id IS NULL OR organization_id = ?", *n.OrganizationID)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+
+	var webhooks []Webhook
+	if err := query.Find(&webhooks).Error; err != nil {
+		return nil, nil, err
+	}
+
+	subscribed := webhooks[:0]
+	for _, w := range webhooks {
+		if w.Subscribed(event) {
+			subscribed = append(subscribed, w)
+		}
+	}
+
+	return subscribed, n.OrganizationID, nil
+}
+
+// HasWebhooks reports whether any webhook wants event of the network, to skip building costly events.
+func HasWebhooks(tx *gorm.DB, event string, networkID uuid.UUID) (bool, error) {
+	webhooks, _, err := webhooksFor(tx, event, networkID)
+	return len(webhooks) > 0, err
+}
+
+// EnqueueWebhookEvent adds a delivery of the event to the outbox of every webhook that wants it.
+// tx should be the transaction of the change, so that the event is sent if and only if the change is saved.
+func EnqueueWebhookEvent(tx *gorm.DB, event string, networkID uuid.UUID, data any) error {
+	webhooks, orgID, err := webhooksFor(tx, event, networkID)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(WebhookEvent{
+		ID:             uuid.New(),
+		Type:           event,
+		NetworkID:      networkID,
+		OrganizationID: orgID,
+		Data:           data,
+		CreatedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, w := range webhooks {
+		d := WebhookDelivery{
+			WebhookID:     w.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+
+		if err := tx.Session(&gorm.Session{NewDB: true}).Create(&d).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validators
+func (w *Webhook) validate() error {
+	if strings.TrimSpace(w.Name) == "" {
+		return NewFieldError("name", "name cannot be empty")
+	}
+
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewFieldError("url", "url must be an absolute http or https URL")
+	}
+
+	for i, event := range w.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return NewFieldError(fmt.Sprintf("events[%d]", i), "invalid event: "+event+"; valid options are "+strings.Join(WebhookEvents, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Hooks
+func (w *Webhook) BeforeCreate(tx *gorm.DB) error {
+	w.ID = uuid.New()
+
+	if w.Secret == "" {
+		secret, err := NewWebhookSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+
+	return nil
+}
+
+func (w *Webhook) BeforeSave(tx *gorm.DB) error {
+	if err := w.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deliveries are removed together with their webhook
+func (w *Webhook) BeforeDelete(tx *gorm.DB) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Where("webhook_id = ?", w.ID).Delete(&WebhookDelivery{}).Error
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed" // Given up after WebhookMaxAttempts.
+)
+
+// WebhookMaxAttempts is the number of times a delivery is attempted before it fails.
+// With the backoff of webhookRetryDelay, the last attempt is made about 20 hours after the event.
+const WebhookMaxAttempts = 12
+
+const (
+	webhookRetryBaseDelay = 30 * time.Second
+	webhookRetryMaxDelay  = 6 * time.Hour
+)
+
+// WebhookDelivery is an event in the outbox of a webhook. Deliveries are written in the transaction
+// of the change that caused the event and sent by the dispatcher, so events survive restarts.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	WebhookID      uuid.UUID  `json:"webhookId" gorm:"type:uuid;not null;index"`
+	Event          string     `json:"event" gorm:"size:64;not null"`
+	Payload        string     `json:"payload" gorm:"not null"` // JSON body of the request, a WebhookEvent.
+	Status         string     `json:"status" gorm:"size:16;not null;index:idx_webhook_delivery_due"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt" gorm:"not null;index:idx_webhook_delivery_due"`
+	LastStatusCode int        `json:"lastStatusCode,omitempty"` // HTTP status of the last attempt, 0 when the request failed.
+	LastError      string     `json:"lastError,omitempty" gorm:"size:1024"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// webhookRetryDelay is the exponential backoff after the given number of failed attempts.
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, webhookRetryMaxDelay)
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due, and postpones them by
+// lease so that other API servers sharing the database do not send them at the same time.
+func ClaimWebhookDeliveries(db *gorm.DB, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	now := time.Now()
+
+	var due []WebhookDelivery
+	err := db.Where("status = ? AND next_attempt_at <= ?", WebhookDeliveryPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := due[:0]
+	for _, d := range due {
+		res := db.Model(&WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at <= ?", d.ID, WebhookDeliveryPending, now).
+			Update("next_attempt_at", now.Add(lease))
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		// Claimed by another server
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		claimed = append(claimed, d)
+	}
+
+	return claimed, nil
+}
+
+// RecordAttempt stores the outcome of sending the delivery. A nil err marks it delivered,
+// otherwise it is retried with exponential backoff until WebhookMaxAttempts.
+func (d *WebhookDelivery) RecordAttempt(db *gorm.DB, statusCode int, err error) error {
+	now := time.Now()
+
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	d.LastError = ""
+
+	switch {
+	case err == nil:
+		d.Status = WebhookDeliveryDelivered
+		d.DeliveredAt = &now
+	case d.Attempts >= WebhookMaxAttempts:
+		d.Status = WebhookDeliveryFailed
+	default:
+		d.NextAttemptAt = now.Add(webhookRetryDelay(d.Attempts))
+	}
+
+	if err != nil {
+		d.LastError = err.Error()
+		if len(d.LastError) > 1024 {
+			d.LastError = d.LastError[:1024]
+		}
+	}
+
+	return db.Model(d).Select("Attempts", "LastStatusCode", "LastError", "Status", "DeliveredAt", "NextAttemptAt").Updates(d).Error
+}
+
+// Hooks
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	d.ID = uuid.New()
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/middleware"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const requestIDKey = "x-request-id"
+
+// methodScopes are the scopes the API token must grant for each method, as for the matching REST routes.
+var methodScopes = map[string][]string{
+	koodnetv1.NetworkService_ListNetworks_FullMethodName:  {models.ScopeNetworksRead},
+	koodnetv1.NetworkService_GetNetwork_FullMethodName:    {models.ScopeNetworksRead},
+	koodnetv1.NetworkService_CreateNetwork_FullMethodName: {models.ScopeNetworksWrite},
+	koodnetv1.NetworkService_UpdateNetwork_FullMethodName: {models.ScopeNetworksWrite},
+	koodnetv1.NetworkService_DeleteNetwork_FullMethodName: {models.ScopeNetworksWrite},
+
+	koodnetv1.HostService_ListHosts_FullMethodName:       {models.ScopeHostsRead},
+	koodnetv1.HostService_GetHost_FullMethodName:         {models.ScopeHostsRead},
+	koodnetv1.HostService_CreateHost_FullMethodName:      {models.ScopeHostsWrite},
+	koodnetv1.HostService_UpdateHost_FullMethodName:      {models.ScopeHostsWrite},
+	koodnetv1.HostService_DeleteHost_FullMethodName:      {models.ScopeHostsWrite},
+	koodnetv1.HostService_GetHostConfig_FullMethodName:   {models.ScopeHostsRead, models.ScopeKeysRead},
+	koodnetv1.HostService_WatchHostConfig_FullMethodName: {models.ScopeHostsRead},
+
+	koodnetv1.CertificateService_ListCertificates_FullMethodName: {models.ScopeCertificatesRead},
+}
+
+// methodRateClasses sort methods into the rate limit classes of their policies, as for the matching REST routes.
+// Other methods are in the default class.
+var methodRateClasses = map[string]string{
+	koodnetv1.HostService_CreateHost_FullMethodName:      middleware.RateClassEnroll,
+	koodnetv1.HostService_GetHostConfig_FullMethodName:   middleware.RateClassConfig,
+	koodnetv1.HostService_WatchHostConfig_FullMethodName: middleware.RateClassConfig,
+}
+
+// unaryInterceptor authenticates and rate limits unary calls, and logs them.
+func unaryInterceptor(l *logrus.Logger, limiter *middleware.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var resp any
+
+		err := intercept(l, limiter, ctx, info.FullMethod, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+
+		return resp, err
+	}
+}
+
+// streamInterceptor authenticates and rate limits streaming calls, and logs them once they end.
+func streamInterceptor(l *logrus.Logger, limiter *middleware.RateLimiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return intercept(l, limiter, ss.Context(), info.FullMethod, func(ctx context.Context) error {
+			return handler(srv, &callerStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// callerStream passes the context with the caller to the handlers of streaming calls.
+type callerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *callerStream) Context() context.Context {
+	return s.ctx
+}
+
+// intercept tags a call with a request ID, taken from the x-request-id metadata when the client sends one,
+// authenticates its API token from the "authorization: Bearer <token>" metadata, checks the scopes of its
+// method, rate limits it like the REST API when limiter is set and calls handle with the caller in the context.
+// The errors of handle are converted into statuses, and the call is logged like the requests of the REST API.
+func intercept(l *logrus.Logger, limiter *middleware.RateLimiter, ctx context.Context, method string, handle func(ctx context.Context) error) error {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := firstValue(md, requestIDKey)
+	if requestID == "" || len(requestID) > 64 {
+		requestID = uuid.NewString()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+
+	caller := service.Caller{RequestID: requestID, SourceIP: sourceIP(ctx)}
+
+	// Calls are limited per client IP before authentication, then per API token and method class
+	err := limit(l, ctx, limiter, middleware.RateClassIP, caller)
+	if err == nil {
+		caller.Token, err = authenticate(ctx, md, method)
+	}
+	if err == nil {
+		err = limit(l, ctx, limiter, rateClass(method), caller)
+	}
+	if err == nil {
+		err = handle(service.NewContext(ctx, caller))
+	}
+
+	result := toStatus(err)
+
+	logFields := logrus.Fields{
+		"method":     method,
+		"status":     status.Code(result).String(),
+		"duration":   time.Since(start),
+		"ip":         caller.SourceIP,
+		"user-agent": firstValue(md, "user-agent"),
+		"request-id": requestID,
+	}
+
+	// Internal errors are logged, as their details are not sent to the client
+	if status.Code(result) == codes.Internal {
+		logFields["errors"] = err.Error()
+	}
+
+	l.WithFields(logFields).Info("Request")
+
+	return result
+}
+
+// authenticate verifies the API token of a call and checks that it grants the scopes of its method.
+func authenticate(ctx context.Context, md metadata.MD, method string) (*models.APIToken, error) {
+	scopes, ok := methodScopes[method]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Unknown method %s.", method)
+	}
+
+	raw, found := strings.CutPrefix(firstValue(md, "authorization"), "Bearer ")
+	if !found || strings.TrimSpace(raw) == "" {
+		return nil, status.Error(codes.Unauthenticated, "An API token is required.")
+	}
+
+	token, err := models.VerifyAPIToken(database.Conn.WithContext(ctx), strings.TrimSpace(raw))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	for _, scope := range scopes {
+		if !token.HasScope(scope) {
+			return nil, status.Error(codes.PermissionDenied, "The API token is missing the "+scope+" scope.")
+		}
+	}
+
+	return token, nil
+}
+
+// limit counts a call of the caller in class, and returns a ResourceExhausted status with a retry-after
+// header once the policy of the class is exceeded. When the store fails, calls are let through.
+func limit(l *logrus.Logger, ctx context.Context, limiter *middleware.RateLimiter, class string, caller service.Caller) error {
+	if limiter == nil {
+		return nil
+	}
+
+	p, count, reset, err := limiter.Hit(class, middleware.RateLimitKey(caller.Token, caller.SourceIP))
+	if err != nil {
+		l.WithError(err).WithField("request-id", caller.RequestID).Warn("Rate limit store failed")
+		return nil
+	}
+
+	if count <= p.Limit {
+		return nil
+	}
+
+	retryAfter := middleware.RetryAfter(reset)
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+
+	return status.Errorf(codes.ResourceExhausted, "Rate limit of %s for %s calls exceeded, retry in %d seconds.", p, class, retryAfter)
+}
+
+func rateClass(method string) string {
+	if class, ok := methodRateClasses[method]; ok {
+		return class
+	}
+
+	return middleware.RateClassDefault
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// sourceIP returns the IP address of the client of a call.
+func sourceIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
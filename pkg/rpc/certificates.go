@@ -0,0 +1,24 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+	"github.com/koodeyo/koodnet/pkg/service"
+)
+
+type certificateServer struct {
+	koodnetv1.UnimplementedCertificateServiceServer
+}
+
+func (certificateServer) ListCertificates(ctx context.Context, req *koodnetv1.ListRequest) (*koodnetv1.ListCertificatesResponse, error) {
+	params := newListParams(req)
+
+	certificates, err := service.ListCertificates(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	data, info := paginated(certificates, params, toCertificate)
+	return &koodnetv1.ListCertificatesResponse{Data: data, Metadata: info}, nil
+}
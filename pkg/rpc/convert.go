@@ -0,0 +1,228 @@
+package rpc
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toNetwork(n *models.Network) *koodnetv1.Network {
+	pb := &koodnetv1.Network{
+		Id:               n.ID.String(),
+		Name:             n.Name,
+		Ips:              n.IPs,
+		Subnets:          n.Subnets,
+		Groups:           n.Groups,
+		Encrypt:          n.Encrypt,
+		Passphrase:       n.Passphrase,
+		ArgonMemory:      uint32(n.ArgonMemory),
+		ArgonIterations:  uint32(n.ArgonIterations),
+		ArgonParallelism: uint32(n.ArgonParallelism),
+		Curve:            n.Curve,
+		Duration:         int64(n.Duration),
+		Version:          n.Version,
+		Etag:             n.ETag(),
+		CreatedAt:        timestamppb.New(n.CreatedAt),
+		UpdatedAt:        timestamppb.New(n.UpdatedAt),
+	}
+
+	if n.OrganizationID != nil {
+		pb.OrganizationId = n.OrganizationID.String()
+	}
+
+	for _, user := range n.SSHUsers {
+		pb.SshUsers = append(pb.SshUsers, &koodnetv1.SSHUser{Name: user.Name, Keys: user.Keys})
+	}
+
+	for i := range n.Ca {
+		pb.Ca = append(pb.Ca, toCertificate(&n.Ca[i]))
+	}
+
+	for i := range n.Hosts {
+		pb.Hosts = append(pb.Hosts, toHost(&n.Hosts[i]))
+	}
+
+	return pb
+}
+
+func toHost(h *models.Host) *koodnetv1.Host {
+	pb := &koodnetv1.Host{
+		Id:              h.ID.String(),
+		Name:            h.Name,
+		Ip:              h.IP,
+		Subnets:         h.Subnets,
+		Groups:          h.Groups,
+		Site:            h.Site,
+		Lighthouses:     h.Lighthouses,
+		Relays:          h.Relays,
+		InPub:           h.InPub,
+		SshHostPub:      h.SSHHostPub,
+		NetworkId:       h.NetworkID.String(),
+		ConfigurationId: h.ConfigurationID.String(),
+		Version:         h.Version,
+		Etag:            h.ETag(),
+		CreatedAt:       timestamppb.New(h.CreatedAt),
+		UpdatedAt:       timestamppb.New(h.UpdatedAt),
+	}
+
+	for _, addr := range h.StaticAddresses {
+		pb.StaticAddresses = append(pb.StaticAddresses, &koodnetv1.StaticAddress{Host: addr.Host, Port: uint32(addr.Port)})
+	}
+
+	if h.Configuration != nil {
+		pb.Configuration = toStruct(h.Configuration)
+	}
+
+	if h.Certificate != nil {
+		pb.Certificate = toCertificate(h.Certificate)
+	}
+
+	return pb
+}
+
+func toCertificate(c *models.Certificate) *koodnetv1.Certificate {
+	return &koodnetv1.Certificate{
+		Id:         c.ID.String(),
+		OwnerId:    c.OwnerID.String(),
+		OwnerType:  c.OwnerType,
+		NotBefore:  timestamppb.New(c.NotBefore),
+		NotAfter:   timestamppb.New(c.NotAfter),
+		Crt:        c.Crt,
+		Key:        c.Key,
+		Pub:        c.Pub,
+		Passphrase: c.Passphrase,
+		IsCa:       c.IsCA,
+		CreatedAt:  timestamppb.New(c.CreatedAt),
+		UpdatedAt:  timestamppb.New(c.UpdatedAt),
+	}
+}
+
+func toConfigDiffs(diffs []service.ConfigDiff) []*koodnetv1.ConfigDiff {
+	pb := make([]*koodnetv1.ConfigDiff, len(diffs))
+	for i, diff := range diffs {
+		pb[i] = &koodnetv1.ConfigDiff{HostId: diff.HostID.String(), HostName: diff.HostName, Diff: diff.Diff}
+	}
+
+	return pb
+}
+
+// toStruct converts a value into a Struct in the shape of its JSON, as the REST API shows it.
+func toStruct(v any) *structpb.Struct {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+
+	s, _ := structpb.NewStruct(m)
+	return s
+}
+
+// fromStruct decodes a Struct into v, as the REST API decodes the JSON of the same shape.
+func fromStruct(s *structpb.Struct, v any, field string) error {
+	b, err := json.Marshal(s.AsMap())
+	if err != nil {
+		return models.NewFieldError(field, err.Error())
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return models.NewFieldError(field, err.Error())
+	}
+
+	return nil
+}
+
+// parseID parses the UUID of a request field, which is not set when empty.
+func parseID(s, field string) (uuid.UUID, error) {
+	if s == "" {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, models.NewFieldError(field, "must be a UUID")
+	}
+
+	return id, nil
+}
+
+func networkDto(in *koodnetv1.NetworkInput) (models.NetworkDto, error) {
+	dto := models.NetworkDto{
+		Name:             in.GetName(),
+		IPs:              in.GetIps(),
+		Subnets:          in.GetSubnets(),
+		Groups:           in.GetGroups(),
+		Duration:         time.Duration(in.GetDuration()),
+		Encrypt:          in.GetEncrypt(),
+		Passphrase:       in.GetPassphrase(),
+		ArgonMemory:      uint(in.GetArgonMemory()),
+		ArgonIterations:  uint(in.GetArgonIterations()),
+		ArgonParallelism: uint(in.GetArgonParallelism()),
+		Curve:            in.GetCurve(),
+	}
+
+	organizationID, err := parseID(in.GetOrganizationId(), "organization_id")
+	if err != nil {
+		return dto, err
+	}
+
+	if organizationID != uuid.Nil {
+		dto.OrganizationID = &organizationID
+	}
+
+	// The SSH users have the same JSON shape in both
+	if len(in.GetSshUsers()) > 0 {
+		b, err := json.Marshal(in.GetSshUsers())
+		if err != nil {
+			return dto, err
+		}
+
+		if err := json.Unmarshal(b, &dto.SSHUsers); err != nil {
+			return dto, models.NewFieldError("ssh_users", err.Error())
+		}
+	}
+
+	return dto, nil
+}
+
+func hostDto(in *koodnetv1.HostInput) (models.HostDto, error) {
+	dto := models.HostDto{
+		Name:        in.GetName(),
+		IP:          in.GetIp(),
+		InPub:       string(in.GetInPub()),
+		Subnets:     in.GetSubnets(),
+		Groups:      in.GetGroups(),
+		Site:        in.GetSite(),
+		Lighthouses: in.GetLighthouses(),
+		Relays:      in.GetRelays(),
+	}
+
+	for _, addr := range in.GetStaticAddresses() {
+		dto.StaticAddresses = append(dto.StaticAddresses, models.StaticAddress{Host: addr.GetHost(), Port: uint(addr.GetPort())})
+	}
+
+	networkID, err := parseID(in.GetNetworkId(), "network_id")
+	if err != nil {
+		return dto, err
+	}
+	dto.NetworkID = networkID
+
+	if in.GetConfiguration() != nil {
+		dto.Configuration = &models.Configuration{}
+		if err := fromStruct(in.GetConfiguration(), dto.Configuration, "configuration"); err != nil {
+			return dto, err
+		}
+	}
+
+	return dto, nil
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// httpCodes map the statuses of models.Errors to gRPC codes.
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusNotImplemented:      codes.Unimplemented,
+}
+
+// toStatus converts err into a gRPC status error, mapping the errors as the REST API does:
+//   - a ValidationError is INVALID_ARGUMENT,
+//   - the errors of the service layer are PERMISSION_DENIED or FAILED_PRECONDITION with their message,
+//   - an error of models.Errors, or wrapping one, has the code of its HTTP status,
+//   - context errors are DEADLINE_EXCEEDED or CANCELED,
+//   - anything else is INTERNAL, without the text of the error.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var invalid models.ValidationError
+	if errors.As(err, &invalid) {
+		return status.Error(codes.InvalidArgument, invalid.Error())
+	}
+
+	var forbidden *service.ForbiddenError
+	if errors.As(err, &forbidden) {
+		return status.Error(codes.PermissionDenied, forbidden.Message)
+	}
+
+	var confirmation *service.ConfirmationRequiredError
+	if errors.As(err, &confirmation) {
+		return status.Errorf(codes.FailedPrecondition, "The network still has %d hosts. Repeat the request with confirm %q to delete it with them.", confirmation.Hosts, confirmation.Network)
+	}
+
+	var precondition *service.PreconditionFailedError
+	if errors.As(err, &precondition) {
+		return status.Errorf(codes.FailedPrecondition, "if_match does not match the current ETag %s of the resource.", precondition.ETag)
+	}
+
+	if errInfo, found := models.LookupError(err); found {
+		code, ok := httpCodes[errInfo.Status]
+		if !ok {
+			code = codes.Internal
+		}
+
+		return status.Error(code, errInfo.Message)
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	return status.Error(codes.Internal, "An internal server error occurred.")
+}
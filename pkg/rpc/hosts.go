@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"gorm.io/gorm"
+)
+
+type hostServer struct {
+	koodnetv1.UnimplementedHostServiceServer
+}
+
+func (hostServer) ListHosts(ctx context.Context, req *koodnetv1.ListRequest) (*koodnetv1.ListHostsResponse, error) {
+	params := newListParams(req)
+
+	hosts, err := service.ListHosts(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	data, info := paginated(hosts, params, toHost)
+	return &koodnetv1.ListHostsResponse{Data: data, Metadata: info}, nil
+}
+
+func (hostServer) GetHost(ctx context.Context, req *koodnetv1.GetRequest) (*koodnetv1.Host, error) {
+	host, err := service.GetHost(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return toHost(host), nil
+}
+
+func (hostServer) CreateHost(ctx context.Context, req *koodnetv1.CreateHostRequest) (*koodnetv1.HostResponse, error) {
+	dto, err := hostDto(req.GetHost())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := service.WriteOptions{DryRun: req.GetDryRun()}
+	host, diffs, err := service.CreateHost(ctx, dto, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return &koodnetv1.HostResponse{Diffs: toConfigDiffs(diffs)}, nil
+	}
+
+	return &koodnetv1.HostResponse{Host: toHost(host)}, nil
+}
+
+func (hostServer) UpdateHost(ctx context.Context, req *koodnetv1.UpdateHostRequest) (*koodnetv1.HostResponse, error) {
+	dto, err := hostDto(req.GetHost())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := service.WriteOptions{IfMatch: req.GetIfMatch(), DryRun: req.GetDryRun()}
+	host, diffs, err := service.UpdateHost(ctx, req.GetId(), service.HostFromDto(dto), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return &koodnetv1.HostResponse{Diffs: toConfigDiffs(diffs)}, nil
+	}
+
+	return &koodnetv1.HostResponse{Host: toHost(host)}, nil
+}
+
+func (hostServer) DeleteHost(ctx context.Context, req *koodnetv1.GetRequest) (*emptypb.Empty, error) {
+	if err := service.DeleteHost(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (hostServer) GetHostConfig(ctx context.Context, req *koodnetv1.GetRequest) (*koodnetv1.HostConfig, error) {
+	yml, revision, err := service.HostConfig(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return &koodnetv1.HostConfig{Yaml: yml, Revision: revision}, nil
+}
+
+// WatchHostConfig sends the revision of the host's config whenever it differs from the last one sent,
+// until the client cancels the call, its deadline passes or the host is deleted.
+func (hostServer) WatchHostConfig(req *koodnetv1.WatchHostConfigRequest, stream grpc.ServerStreamingServer[koodnetv1.ConfigRevision]) error {
+	ctx := stream.Context()
+
+	w, err := service.WatchHostConfig(ctx, req.GetId())
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	sent := req.GetRevision()
+	for {
+		revision, err := w.Revision()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return stream.Send(&koodnetv1.ConfigRevision{HostId: w.HostID.String(), Deleted: true})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if revision != sent {
+			if err := stream.Send(&koodnetv1.ConfigRevision{HostId: w.HostID.String(), Revision: revision}); err != nil {
+				return err
+			}
+			sent = revision
+		}
+
+		select {
+		case <-w.Changed():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
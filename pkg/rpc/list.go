@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"strconv"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+)
+
+// listParams passes a ListRequest to the list scopes as the query parameters of the REST API,
+// and keeps the page info they set.
+type listParams struct {
+	query  map[string]string
+	values map[string]int
+}
+
+func newListParams(req *koodnetv1.ListRequest) *listParams {
+	query := map[string]string{}
+	for key, value := range req.GetFilters() {
+		query[key] = value
+	}
+
+	if req.GetSort() != "" {
+		query["sort"] = req.GetSort()
+	}
+
+	if req.GetPage() > 0 {
+		query["page"] = strconv.Itoa(int(req.GetPage()))
+	}
+
+	if req.GetPageSize() > 0 {
+		query["pageSize"] = strconv.Itoa(int(req.GetPageSize()))
+	}
+
+	if req.GetCursor() != "" {
+		query["cursor"] = req.GetCursor()
+	}
+
+	if req.GetLimit() > 0 {
+		query["limit"] = strconv.Itoa(int(req.GetLimit()))
+	}
+
+	if req.Count != nil {
+		query["count"] = strconv.FormatBool(req.GetCount())
+	}
+
+	return &listParams{query: query, values: map[string]int{}}
+}
+
+func (p *listParams) GetQuery(key string) (string, bool) {
+	value, ok := p.query[key]
+	return value, ok
+}
+
+func (p *listParams) Set(key string, value any) {
+	if i, ok := value.(int); ok {
+		p.values[key] = i
+	}
+}
+
+func (p *listParams) optional(key string) *int32 {
+	i, ok := p.values[key]
+	if !ok {
+		return nil
+	}
+
+	i32 := int32(i)
+	return &i32
+}
+
+// paginated converts a page of items and returns it with its page info.
+// Cursor pages are fetched with one item more than the limit when there is a next page.
+func paginated[T any, P any](items []T, p *listParams, convert func(*T) P) ([]P, *koodnetv1.PageInfo) {
+	info := &koodnetv1.PageInfo{
+		Page:       int32(p.values["page"]),
+		PageSize:   int32(p.values["pageSize"]),
+		Total:      p.optional("total"),
+		TotalPages: p.optional("totalPages"),
+	}
+
+	if limit := p.values["limit"]; limit > 0 {
+		info = &koodnetv1.PageInfo{Limit: int32(limit), Total: p.optional("total")}
+
+		if len(items) > limit {
+			items = items[:limit]
+			if last, ok := any(items[limit-1]).(models.Cursored); ok {
+				info.NextCursor = last.PageCursor().String()
+			}
+		}
+	}
+
+	data := make([]P, len(items))
+	for i := range items {
+		data[i] = convert(&items[i])
+	}
+
+	return data, info
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+	"github.com/koodeyo/koodnet/pkg/service"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type networkServer struct {
+	koodnetv1.UnimplementedNetworkServiceServer
+}
+
+func (networkServer) ListNetworks(ctx context.Context, req *koodnetv1.ListRequest) (*koodnetv1.ListNetworksResponse, error) {
+	params := newListParams(req)
+
+	networks, err := service.ListNetworks(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	data, info := paginated(networks, params, toNetwork)
+	return &koodnetv1.ListNetworksResponse{Data: data, Metadata: info}, nil
+}
+
+func (networkServer) GetNetwork(ctx context.Context, req *koodnetv1.GetRequest) (*koodnetv1.Network, error) {
+	n, err := service.GetNetwork(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return toNetwork(n), nil
+}
+
+func (networkServer) CreateNetwork(ctx context.Context, req *koodnetv1.NetworkInput) (*koodnetv1.Network, error) {
+	dto, err := networkDto(req)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := service.CreateNetwork(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	return toNetwork(n), nil
+}
+
+func (networkServer) UpdateNetwork(ctx context.Context, req *koodnetv1.UpdateNetworkRequest) (*koodnetv1.UpdateNetworkResponse, error) {
+	dto, err := networkDto(req.GetNetwork())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := service.WriteOptions{IfMatch: req.GetIfMatch(), DryRun: req.GetDryRun()}
+	n, diffs, err := service.UpdateNetwork(ctx, req.GetId(), dto, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return &koodnetv1.UpdateNetworkResponse{Diffs: toConfigDiffs(diffs)}, nil
+	}
+
+	return &koodnetv1.UpdateNetworkResponse{Network: toNetwork(n)}, nil
+}
+
+func (networkServer) DeleteNetwork(ctx context.Context, req *koodnetv1.DeleteNetworkRequest) (*emptypb.Empty, error) {
+	if err := service.DeleteNetwork(ctx, req.GetId(), req.GetConfirm()); err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
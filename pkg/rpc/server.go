@@ -0,0 +1,39 @@
+// Package rpc serves the gRPC API of koodnet-api, defined in proto/koodnet/v1. It authenticates and
+// authorizes calls like the REST API does, and runs them through the same service layer.
+package rpc
+
+import (
+	"crypto/tls"
+
+	"github.com/koodeyo/koodnet/pkg/middleware"
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Options configure the gRPC server.
+type Options struct {
+	TLS         *tls.Config             // Serve over TLS. Nil serves without it.
+	RateLimiter *middleware.RateLimiter // Rate limit calls by the policies of the REST API. Nil doesn't limit them.
+}
+
+// NewServer returns a gRPC server with the network, host and certificate services registered.
+func NewServer(l *logrus.Logger, opts Options) *grpc.Server {
+	serverOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(unaryInterceptor(l, opts.RateLimiter)),
+		grpc.StreamInterceptor(streamInterceptor(l, opts.RateLimiter)),
+	}
+
+	if opts.TLS != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opts.TLS)))
+	}
+
+	s := grpc.NewServer(serverOpts...)
+
+	koodnetv1.RegisterNetworkServiceServer(s, &networkServer{})
+	koodnetv1.RegisterHostServiceServer(s, &hostServer{})
+	koodnetv1.RegisterCertificateServiceServer(s, &certificateServer{})
+
+	return s
+}
@@ -0,0 +1,273 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/middleware"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/koodeyo/koodnet/pkg/rpc/koodnetv1"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/gorm/logger"
+)
+
+// startTestServer serves the gRPC API with opts over an in-memory listener, on a fresh SQLite database, and
+// returns a connection to it with creds, or without transport security when creds is nil.
+func startTestServer(t *testing.T, opts Options, creds credentials.TransportCredentials) *grpc.ClientConn {
+	t.Helper()
+
+	database.Connect(config.DatabaseConfig{SQLitePath: filepath.Join(t.TempDir(), "koodnet.db")})
+	database.Conn.Logger = logger.Discard
+	database.Migrate()
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer(l, opts)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient("passthrough:///koodnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(creds),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// withTestToken creates an API token with scopes, and returns a context that sends it.
+func withTestToken(t *testing.T, scopes ...string) context.Context {
+	t.Helper()
+
+	token, raw, err := models.NewAPIToken("test", scopes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Conn.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+raw)
+}
+
+func testNetworkInput(name string) *koodnetv1.NetworkInput {
+	return &koodnetv1.NetworkInput{
+		Name:     name,
+		Ips:      []string{"100.100.0.0/16"},
+		Duration: int64(24 * time.Hour),
+		Curve:    "25519",
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	networks := koodnetv1.NewNetworkServiceClient(startTestServer(t, Options{}, nil))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"no token", context.Background(), codes.Unauthenticated},
+		{"unknown token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer knt_unknown"), codes.Unauthenticated},
+		{"missing scope", withTestToken(t, models.ScopeNetworksRead), codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		if _, err := networks.CreateNetwork(tt.ctx, testNetworkInput("denied")); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+		}
+	}
+
+	// Tokens without a role on a network cannot read it
+	ctx := withTestToken(t, models.ScopeNetworksRead, models.ScopeNetworksWrite)
+	n, err := networks.CreateNetwork(ctx, testNetworkInput("private"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := withTestToken(t, models.ScopeNetworksRead)
+	if _, err := networks.GetNetwork(other, &koodnetv1.GetRequest{Id: n.GetId()}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("reading the network of another token got %v, want PermissionDenied", err)
+	}
+}
+
+func TestCreateAndGet(t *testing.T) {
+	conn := startTestServer(t, Options{}, nil)
+	networks, hosts := koodnetv1.NewNetworkServiceClient(conn), koodnetv1.NewHostServiceClient(conn)
+	ctx := withTestToken(t, models.ScopeNetworksRead, models.ScopeNetworksWrite, models.ScopeHostsRead, models.ScopeHostsWrite)
+
+	n, err := networks.CreateNetwork(ctx, testNetworkInput("office"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := networks.GetNetwork(ctx, &koodnetv1.GetRequest{Id: n.GetId()})
+	if err != nil || got.GetName() != "office" || got.GetEtag() != n.GetEtag() || len(got.GetCa()) != 1 {
+		t.Fatalf("GetNetwork() = %v, %v, want the created network with its CA", got, err)
+	}
+
+	created, err := hosts.CreateHost(ctx, &koodnetv1.CreateHostRequest{Host: &koodnetv1.HostInput{
+		NetworkId:       n.GetId(),
+		Name:            "web",
+		Ip:              "100.100.0.1/16",
+		Groups:          []string{"web"},
+		StaticAddresses: []*koodnetv1.StaticAddress{{Host: "203.0.113.1", Port: 4242}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := hosts.GetHost(ctx, &koodnetv1.GetRequest{Id: created.GetHost().GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host.GetName() != "web" || host.GetNetworkId() != n.GetId() || len(host.GetStaticAddresses()) != 1 || host.GetStaticAddresses()[0].GetPort() != 4242 {
+		t.Errorf("GetHost() = %v, want the created host", host)
+	}
+
+	// Invalid input is reported with the field
+	_, err = hosts.CreateHost(ctx, &koodnetv1.CreateHostRequest{Host: &koodnetv1.HostInput{NetworkId: "not-a-uuid"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("creating a host with an invalid network ID got %v, want InvalidArgument", err)
+	}
+}
+
+func TestKeysRequireKeysRead(t *testing.T) {
+	conn := startTestServer(t, Options{}, nil)
+	networks, hosts := koodnetv1.NewNetworkServiceClient(conn), koodnetv1.NewHostServiceClient(conn)
+
+	for _, withKeys := range []bool{false, true} {
+		scopes := []string{models.ScopeNetworksRead, models.ScopeNetworksWrite, models.ScopeHostsWrite}
+		if withKeys {
+			scopes = append(scopes, models.ScopeKeysRead)
+		}
+		ctx := withTestToken(t, scopes...)
+
+		created, err := networks.CreateNetwork(ctx, testNetworkInput(fmt.Sprint("keys-", withKeys)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := networks.GetNetwork(ctx, &koodnetv1.GetRequest{Id: created.GetId()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		host, err := hosts.CreateHost(ctx, &koodnetv1.CreateHostRequest{Host: &koodnetv1.HostInput{
+			NetworkId: created.GetId(), Name: "web", Ip: "100.100.0.1/16",
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, crt := range map[string]*koodnetv1.Certificate{
+			"created CA": created.GetCa()[0],
+			"read CA":    got.GetCa()[0],
+			"host":       host.GetHost().GetCertificate(),
+		} {
+			if hasKey := len(crt.GetKey()) > 0; hasKey != withKeys {
+				t.Errorf("keys:read %v: %s has a key %v", withKeys, name, hasKey)
+			}
+		}
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	limiter := &middleware.RateLimiter{
+		Store:    middleware.NewMemoryRateLimitStore(),
+		Policies: map[string]middleware.RatePolicy{middleware.RateClassDefault: {Limit: 1, Period: time.Hour}},
+	}
+
+	networks := koodnetv1.NewNetworkServiceClient(startTestServer(t, Options{RateLimiter: limiter}, nil))
+	ctx := withTestToken(t, models.ScopeNetworksRead)
+
+	if _, err := networks.ListNetworks(ctx, &koodnetv1.ListRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var header metadata.MD
+	_, err := networks.ListNetworks(ctx, &koodnetv1.ListRequest{}, grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted || len(header.Get("retry-after")) == 0 {
+		t.Errorf("second call got %v with header %v, want ResourceExhausted with retry-after", err, header)
+	}
+
+	// Other tokens have buckets of their own
+	if _, err := networks.ListNetworks(withTestToken(t, models.ScopeNetworksRead), &koodnetv1.ListRequest{}); err != nil {
+		t.Errorf("call of another token got %v", err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	crt, pool := newTestCertificate(t)
+
+	networks := koodnetv1.NewNetworkServiceClient(startTestServer(t,
+		Options{TLS: &tls.Config{Certificates: []tls.Certificate{crt}}},
+		credentials.NewClientTLSFromCert(pool, "localhost"),
+	))
+
+	if _, err := networks.ListNetworks(withTestToken(t, models.ScopeNetworksRead), &koodnetv1.ListRequest{}); err != nil {
+		t.Errorf("call over TLS got %v", err)
+	}
+}
+
+// newTestCertificate returns a self-signed certificate for localhost, and a pool trusting it.
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApplyResult lists the changes of applying a spec, and for dry runs the config changes of every affected host.
+type ApplyResult struct {
+	NetworkID *uuid.UUID // Not set in dry runs of networks that are yet to be created.
+	Actions   []models.PlanAction
+	Diffs     []ConfigDiff
+}
+
+// Apply brings a network and its hosts to the state described by spec, in one transaction, see models.PlanNetwork.
+// With dryRun the plan is returned with the redacted config diff of every affected host, without applying it.
+func Apply(ctx context.Context, spec *models.NetworkSpec, dryRun bool) (*ApplyResult, error) {
+	token := CallerFromContext(ctx).Token
+	if token == nil {
+		return nil, &ForbiddenError{Message: "An API token is required."}
+	}
+
+	organizationID, err := NetworkOrganization(ctx, spec.Network.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ApplyResult{}
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		plan, err := models.PlanNetwork(tx, spec, organizationID)
+		if err != nil {
+			return err
+		}
+
+		if action := deniedPlanAction(tx, token, plan); action != "" {
+			return &ForbiddenError{Message: "Your role on this network does not allow " + action + "."}
+		}
+
+		result.Actions = plan.Actions()
+
+		var before map[uuid.UUID]RenderedConfig
+		if plan.Current != nil && dryRun {
+			if before, err = RedactedNetworkConfigs(tx, plan.Current.ID); err != nil {
+				return err
+			}
+		}
+
+		networkID, err := applyPlan(ctx, tx, plan)
+		if err != nil {
+			return err
+		}
+
+		if plan.Current != nil || !dryRun {
+			result.NetworkID = &networkID
+		}
+
+		if dryRun {
+			after, err := RedactedNetworkConfigs(tx, networkID)
+			if err != nil {
+				return err
+			}
+
+			result.Diffs = DiffConfigs(before, after)
+
+			return errDryRun
+		}
+
+		return NotifyConfigChanges(tx, networkID)
+	})
+
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// deniedPlanAction returns the first action of the plan on an existing network that the token's role does not allow.
+// Creating a network makes the caller its owner, which allows everything.
+func deniedPlanAction(tx *gorm.DB, token *models.APIToken, plan *models.NetworkPlan) string {
+	if plan.Current == nil {
+		return ""
+	}
+
+	actions := []string{models.ActionNetworkRead}
+	if len(plan.NetworkFields) > 0 {
+		actions = append(actions, models.ActionNetworkUpdate)
+	}
+
+	for _, change := range plan.Hosts {
+		switch change.Action {
+		case models.PlanCreate:
+			actions = append(actions, models.ActionHostCreate)
+		case models.PlanUpdate:
+			actions = append(actions, models.ActionHostUpdate)
+		case models.PlanDelete:
+			actions = append(actions, models.ActionHostDelete)
+		}
+	}
+
+	for _, action := range actions {
+		if allowed, err := models.Authorize(tx, token, plan.Current.ID, action); err != nil || !allowed {
+			return action
+		}
+	}
+
+	return ""
+}
+
+// applyPlan makes the changes of the plan, recording them in the audit log and queueing their webhook events.
+// It returns the ID of the network.
+func applyPlan(ctx context.Context, tx *gorm.DB, plan *models.NetworkPlan) (uuid.UUID, error) {
+	n := plan.Desired
+
+	if plan.Current == nil {
+		if err := tx.Create(&n).Error; err != nil {
+			return uuid.Nil, err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceNetwork, n.ID, &n.ID, nil, n); err != nil {
+			return uuid.Nil, err
+		}
+
+		if err := AuditCertificates(ctx, tx, models.AuditActionCreate, n.ID, CertificatePointers(n.Ca)...); err != nil {
+			return uuid.Nil, err
+		}
+
+		if err := QueueCertificatesIssued(tx, n.ID, CertificatePointers(n.Ca)...); err != nil {
+			return uuid.Nil, err
+		}
+
+		err := tx.Create(&models.RoleBinding{
+			NetworkID: n.ID,
+			TokenID:   CallerFromContext(ctx).Token.ID,
+			Role:      models.RoleOwner,
+		}).Error
+		if err != nil {
+			return uuid.Nil, err
+		}
+	} else if len(plan.NetworkFields) > 0 {
+		if err := plan.UpdateNetwork(tx); err != nil {
+			return uuid.Nil, err
+		}
+
+		if err := tx.Omit(clause.Associations).First(&n, "id = ?", n.ID).Error; err != nil {
+			return uuid.Nil, err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionUpdate, models.AuditResourceNetwork, n.ID, &n.ID, *plan.Current, n); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	for _, change := range plan.Hosts {
+		var err error
+		switch change.Action {
+		case models.PlanCreate:
+			err = applyHostCreate(ctx, tx, n.ID, change)
+		case models.PlanUpdate:
+			err = applyHostUpdate(ctx, tx, change)
+		case models.PlanDelete:
+			err = applyHostDelete(ctx, tx, change)
+		}
+
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	return n.ID, nil
+}
+
+func applyHostCreate(ctx context.Context, tx *gorm.DB, networkID uuid.UUID, change models.HostChange) error {
+	host := *change.Desired
+	host.NetworkID = networkID
+
+	if err := tx.Create(&host).Error; err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceHost, host.ID, &host.NetworkID, nil, host); err != nil {
+		return err
+	}
+
+	if err := AuditCertificates(ctx, tx, models.AuditActionCreate, host.NetworkID, host.Certificate); err != nil {
+		return err
+	}
+
+	if err := QueueHostEvent(tx, models.WebhookEventHostCreated, host); err != nil {
+		return err
+	}
+
+	return QueueCertificatesIssued(tx, host.NetworkID, host.Certificate)
+}
+
+func applyHostUpdate(ctx context.Context, tx *gorm.DB, change models.HostChange) error {
+	before := *change.Current
+	if before.Configuration != nil {
+		cfg := *before.Configuration
+		before.Configuration = &cfg
+	}
+	before.Certificate = nil
+
+	old, err := change.Update(tx)
+	if err != nil {
+		return err
+	}
+
+	var host models.Host
+	if err := tx.Preload("Configuration").First(&host, "id = ?", before.ID).Error; err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, models.AuditActionUpdate, models.AuditResourceHost, host.ID, &host.NetworkID, before, host); err != nil {
+		return err
+	}
+
+	if err := QueueHostEvent(tx, models.WebhookEventHostUpdated, host); err != nil {
+		return err
+	}
+
+	if !change.Reissue {
+		return nil
+	}
+
+	if err := AuditCertificates(ctx, tx, models.AuditActionDelete, host.NetworkID, old); err != nil {
+		return err
+	}
+
+	if err := AuditCertificates(ctx, tx, models.AuditActionCreate, host.NetworkID, change.Current.Certificate); err != nil {
+		return err
+	}
+
+	return QueueCertificatesIssued(tx, host.NetworkID, change.Current.Certificate)
+}
+
+func applyHostDelete(ctx context.Context, tx *gorm.DB, change models.HostChange) error {
+	host := *change.Current
+
+	if err := Audit(ctx, tx, models.AuditActionDelete, models.AuditResourceHost, host.ID, &host.NetworkID, host, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Delete(&host).Error; err != nil {
+		return err
+	}
+
+	return QueueHostEvent(tx, models.WebhookEventHostDeleted, host)
+}
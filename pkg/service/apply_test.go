@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+func testNetworkSpec(hosts ...models.HostSpec) *models.NetworkSpec {
+	return &models.NetworkSpec{Network: testNetworkDto("office"), Hosts: hosts}
+}
+
+// planSummary lists the actions of a plan as "action resource name [fields]".
+func planSummary(actions []models.PlanAction) []string {
+	summary := []string{}
+	for _, a := range actions {
+		summary = append(summary, strings.TrimSpace(a.Action+" "+a.ResourceType+" "+a.Name+" "+strings.Join(a.Fields, ",")))
+	}
+
+	return summary
+}
+
+func networkHosts(t *testing.T, result *ApplyResult) map[string]models.Host {
+	t.Helper()
+
+	var hosts []models.Host
+	if err := database.Conn.Where("network_id = ?", result.NetworkID).Find(&hosts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	byName := map[string]models.Host{}
+	for _, h := range hosts {
+		byName[h.Name] = h
+	}
+
+	return byName
+}
+
+func TestApply(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+
+	spec := testNetworkSpec(
+		models.HostSpec{Name: "web", IP: "100.100.0.1/16", Groups: []string{"servers"}},
+		models.HostSpec{Name: "db"},
+	)
+
+	// The plan of a new network has no ID and creates nothing
+	plan, err := Apply(ctx, spec, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"create network office", "create host web", "create host db"}; !slices.Equal(planSummary(plan.Actions), want) {
+		t.Errorf("plan = %q, want %q", planSummary(plan.Actions), want)
+	}
+	if plan.NetworkID != nil {
+		t.Error("the plan of a new network has a network ID")
+	}
+
+	created, err := Apply(ctx, spec, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := networkHosts(t, created)
+	if len(hosts) != 2 || hosts["db"].IP != "100.100.0.2/16" {
+		t.Fatalf("created %v, want web and db with the next free IP", hosts)
+	}
+
+	// Applying the same spec again changes nothing
+	if again, err := Apply(ctx, spec, false); err != nil || len(again.Actions) != 0 {
+		t.Fatalf("reapplying got %v, %v, want no actions", again, err)
+	}
+
+	spec.Hosts = []models.HostSpec{
+		{Name: "web", IP: "100.100.0.1/16", Groups: []string{"servers", "ssh"}},
+		{Name: "ci", Site: "eu-west"},
+	}
+
+	plan, err = Apply(ctx, spec, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"delete host db", "update host web groups", "reissue certificate web groups", "create host ci"}
+	if !slices.Equal(planSummary(plan.Actions), want) {
+		t.Errorf("plan = %q, want %q", planSummary(plan.Actions), want)
+	}
+	if plan.NetworkID == nil || *plan.NetworkID != *created.NetworkID || len(plan.Diffs) == 0 {
+		t.Errorf("plan of the network got %v with %d diffs, want its ID and the config diffs", plan.NetworkID, len(plan.Diffs))
+	}
+
+	// The dry run is rolled back
+	if hosts := networkHosts(t, created); len(hosts) != 2 || len(hosts["web"].Groups) != 1 {
+		t.Fatalf("the dry run changed the hosts to %v", hosts)
+	}
+
+	applied, err := Apply(ctx, spec, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(planSummary(applied.Actions), want) {
+		t.Errorf("applied %q, want %q", planSummary(applied.Actions), want)
+	}
+
+	hosts = networkHosts(t, created)
+	if _, ok := hosts["db"]; ok || len(hosts) != 2 || !slices.Equal(hosts["web"].Groups, []string{"servers", "ssh"}) || hosts["ci"].Site != "eu-west" {
+		t.Errorf("applied %v, want web with the ssh group and ci", hosts)
+	}
+
+}
+
+func TestApplyChecksRole(t *testing.T) {
+	connectTestDB(t)
+	owner := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+
+	spec := testNetworkSpec(models.HostSpec{Name: "web", IP: "100.100.0.1/16"})
+
+	created, err := Apply(owner, spec, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := GetNetwork(owner, created.NetworkID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role   string
+		hosts  []models.HostSpec
+		denied bool
+	}{
+		{models.RoleViewer, []models.HostSpec{{Name: "web", IP: "100.100.0.1/16"}}, false},
+		{models.RoleViewer, []models.HostSpec{{Name: "web", IP: "100.100.0.1/16"}, {Name: "db"}}, true},
+		{models.RoleEnroller, []models.HostSpec{{Name: "web", IP: "100.100.0.1/16"}, {Name: "db"}}, false},
+		{models.RoleEnroller, []models.HostSpec{{Name: "web", IP: "100.100.0.9/16"}}, true},
+		{models.RoleEnroller, nil, true},
+		{models.RoleOperator, nil, false},
+	}
+
+	for _, tt := range tests {
+		ctx := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+		bindTestRole(t, ctx, n, tt.role)
+
+		spec.Hosts = tt.hosts
+
+		// The dry run is refused like the apply
+		_, err := Apply(ctx, spec, true)
+
+		var forbidden *ForbiddenError
+		if denied := errors.As(err, &forbidden); denied != tt.denied || (!denied && err != nil) {
+			t.Errorf("%s applying %d hosts got %v, want denied %v", tt.role, len(tt.hosts), err, tt.denied)
+		}
+	}
+
+	// Without a role, not even reading the network is allowed
+	stranger := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+	spec.Hosts = nil
+
+	var forbidden *ForbiddenError
+	if _, err := Apply(stranger, spec, false); !errors.As(err, &forbidden) {
+		t.Errorf("a token without a role got %v, want a ForbiddenError", err)
+	}
+
+	var count int64
+	database.Conn.Model(&models.Host{}).Where("network_id = ?", n.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("the network has %d hosts, want web only", count)
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Audit records action on a resource in the audit log, on behalf of the caller.
+// tx should be the transaction of the change, so that the change is not saved without its event.
+func Audit(ctx context.Context, tx *gorm.DB, action, resourceType string, resourceID uuid.UUID, networkID *uuid.UUID, before, after any) error {
+	e, err := models.NewAuditEvent(action, resourceType, resourceID, networkID, before, after)
+	if err != nil {
+		return err
+	}
+
+	caller := CallerFromContext(ctx)
+	if caller.Token != nil {
+		e.ActorID = &caller.Token.ID
+		e.ActorName = caller.Token.Name
+	}
+
+	e.RequestID = caller.RequestID
+	e.SourceIP = caller.SourceIP
+
+	return models.RecordAuditEvent(tx, &e)
+}
+
+// AuditCertificates records action on each of the certificates of a network or host.
+func AuditCertificates(ctx context.Context, tx *gorm.DB, action string, networkID uuid.UUID, certs ...*models.Certificate) error {
+	for _, crt := range certs {
+		if crt == nil {
+			continue
+		}
+
+		var before, after any = nil, crt
+		if action == models.AuditActionDelete {
+			before, after = crt, nil
+		}
+
+		if err := Audit(ctx, tx, action, models.AuditResourceCertificate, crt.ID, &networkID, before, after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CertificatePointers returns pointers to the certificates, for AuditCertificates.
+func CertificatePointers(certs []models.Certificate) []*models.Certificate {
+	ptrs := make([]*models.Certificate, len(certs))
+	for i := range certs {
+		ptrs[i] = &certs[i]
+	}
+
+	return ptrs
+}
+
+// AuditFilter selects audit events. Empty fields match any event.
+type AuditFilter struct {
+	Action       string
+	ResourceType string
+	ResourceID   string
+	NetworkID    string
+	ActorID      string
+	RequestID    string
+	Since        time.Time // Only events at or after this time.
+	Until        time.Time // Only events before this time.
+}
+
+// ListAuditEvents returns the audit events matching filter, newest first, paginated by params.
+// Callers confined to an organization only see the events of its networks.
+func ListAuditEvents(ctx context.Context, filter AuditFilter, params models.ListParams) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+
+	query := db(ctx).Model(&models.AuditEvent{})
+	if token := CallerFromContext(ctx).Token; token != nil && token.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *token.OrganizationID)
+	}
+
+	conditions := []struct {
+		cond  string
+		value string
+	}{
+		{"action = ?", filter.Action},
+		{"resource_type = ?", filter.ResourceType},
+		{"resource_id = ?", filter.ResourceID},
+		{"network_id = ?", filter.NetworkID},
+		{"actor_id = ?", filter.ActorID},
+		{"request_id = ?", filter.RequestID},
+	}
+
+	for _, c := range conditions {
+		if c.value != "" {
+			query = query.Where(c.cond, c.value)
+		}
+	}
+
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until.UTC())
+	}
+
+	if err := query.Scopes(models.Paginate(params)).Order("sequence DESC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// VerifyAuditChain recomputes the hash chain of the audit log, see models.VerifyAuditChain.
+func VerifyAuditChain(ctx context.Context) (models.AuditChainStatus, error) {
+	return models.VerifyAuditChain(db(ctx))
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// can reports whether the caller's role on the network grants action.
+func can(ctx context.Context, networkID uuid.UUID, action string) bool {
+	allowed, err := models.Authorize(db(ctx), CallerFromContext(ctx).Token, networkID, action)
+	return err == nil && allowed
+}
+
+// authorize is like can, but returns a ForbiddenError when the action is not allowed.
+func authorize(ctx context.Context, networkID uuid.UUID, action string) error {
+	if can(ctx, networkID, action) {
+		return nil
+	}
+
+	return &ForbiddenError{Message: "Your role on this network does not allow " + action + "."}
+}
+
+// hasScope reports whether the caller's API token grants scope.
+func hasScope(ctx context.Context, scope string) bool {
+	token := CallerFromContext(ctx).Token
+	return token != nil && token.HasScope(scope)
+}
+
+// canReadKeys reports whether the caller may see the private keys of the network,
+// which takes both the keys:read scope and a role that allows reading keys.
+func canReadKeys(ctx context.Context, networkID uuid.UUID) bool {
+	return hasScope(ctx, models.ScopeKeysRead) && can(ctx, networkID, models.ActionKeysRead)
+}
+
+// checkIfMatch returns a PreconditionFailedError unless ifMatch, when set, matches the current ETag of the resource.
+func checkIfMatch(ifMatch, etag string) error {
+	if ifMatch == "" {
+		return nil
+	}
+
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return nil
+		}
+	}
+
+	return &PreconditionFailedError{ETag: etag}
+}
+
+// NetworkOrganization returns the organization to create a network in. Tokens confined to an organization
+// create networks in it, and are forbidden to ask for another one.
+func NetworkOrganization(ctx context.Context, requested *uuid.UUID) (*uuid.UUID, error) {
+	token := CallerFromContext(ctx).Token
+	if token == nil || token.OrganizationID == nil {
+		return requested, nil
+	}
+
+	if requested != nil && *requested != *token.OrganizationID {
+		return nil, &ForbiddenError{Message: "Networks can only be created in the organization of the API token."}
+	}
+
+	return token.OrganizationID, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/internal"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// ListCertificates returns the CAs and host certificates the caller may read, filtered, sorted and paginated by params.
+// Their private keys are left out unless the caller may read the keys of their network.
+func ListCertificates(ctx context.Context, params models.ListParams) ([]models.Certificate, error) {
+	var certificates []models.Certificate
+
+	token := CallerFromContext(ctx).Token
+	err := db(ctx).Model(&models.Certificate{}).
+		Scopes(
+			models.Filter(params, models.CertificateQuery),
+			models.CertificatesAccessibleBy(token, models.ActionHostRead),
+			models.WithoutDeletedOwners,
+			models.Sort(params, models.CertificateQuery),
+			models.Paginate(params),
+		).
+		Find(&certificates).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Private keys require the keys:read scope and role
+	withKeys := map[uuid.UUID]bool{}
+	if hasScope(ctx, models.ScopeKeysRead) {
+		var ids []uuid.UUID
+		db(ctx).Model(&models.Certificate{}).
+			Scopes(models.CertificatesAccessibleBy(token, models.ActionKeysRead)).
+			Where("id IN ?", internal.MapValues(certificates, func(cert models.Certificate) uuid.UUID { return cert.ID })).
+			Pluck("id", &ids)
+
+		for _, id := range ids {
+			withKeys[id] = true
+		}
+	}
+
+	for i := range certificates {
+		if !withKeys[certificates[i].ID] {
+			certificates[i].RedactKeys()
+		}
+	}
+
+	return certificates, nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// configWatchHub polls the config revision counters of the networks that agents watch, in a single
+// query for all of them, and wakes the watchers of a network when its counter moves. The counters
+// live in the database, so changes made through other API servers wake watchers too.
+type configWatchHub struct {
+	mu       sync.Mutex
+	running  bool
+	networks map[uuid.UUID]*watchedNetwork
+}
+
+type watchedNetwork struct {
+	revision    uint64
+	polled      bool
+	subscribers map[chan struct{}]bool
+}
+
+var configWatches = &configWatchHub{networks: map[uuid.UUID]*watchedNetwork{}}
+
+// ConfigWatchInterval is how often the revisions are polled. It is set from the configuration
+// before the APIs are served.
+var ConfigWatchInterval = 2 * time.Second
+
+// subscribe returns a channel that receives when the configs of the network may have changed.
+// It also receives after the first poll, so that changes made before that are not missed.
+func (h *configWatchHub) subscribe(networkID uuid.UUID) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.networks[networkID]
+	if !ok {
+		w = &watchedNetwork{subscribers: map[chan struct{}]bool{}}
+		h.networks[networkID] = w
+	}
+	w.subscribers[ch] = true
+
+	if !h.running {
+		h.running = true
+		go h.run(ConfigWatchInterval)
+	}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(w.subscribers, ch)
+		if len(w.subscribers) == 0 && h.networks[networkID] == w {
+			delete(h.networks, networkID)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// run polls until no network is watched anymore.
+func (h *configWatchHub) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.mu.Lock()
+		if len(h.networks) == 0 {
+			h.running = false
+			h.mu.Unlock()
+			return
+		}
+
+		ids := make([]uuid.UUID, 0, len(h.networks))
+		for id := range h.networks {
+			ids = append(ids, id)
+		}
+		h.mu.Unlock()
+
+		revisions, err := models.NetworkConfigRevisions(database.Conn, ids)
+		if err != nil {
+			continue
+		}
+
+		h.mu.Lock()
+		for _, id := range ids {
+			w, ok := h.networks[id]
+			if !ok {
+				continue
+			}
+
+			// Deleted networks wake their watchers too, which then find their host gone
+			revision, exists := revisions[id]
+			if w.polled && exists && revision == w.revision {
+				continue
+			}
+			w.revision, w.polled = revision, true
+
+			for ch := range w.subscribers {
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// ConfigWatch follows the rendered config of a host across changes, and across networks when the host moves.
+type ConfigWatch struct {
+	HostID      uuid.UUID
+	networkID   uuid.UUID
+	changed     <-chan struct{}
+	unsubscribe func()
+}
+
+// WatchHostConfig starts watching the config of a host, which the caller's role must allow reading.
+// The watch must be closed.
+func WatchHostConfig(ctx context.Context, id string) (*ConfigWatch, error) {
+	var host models.Host
+
+	if err := db(ctx).Select("id", "network_id").First(&host, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, host.NetworkID, models.ActionHostRead); err != nil {
+		return nil, err
+	}
+
+	w := &ConfigWatch{HostID: host.ID, networkID: host.NetworkID}
+	w.changed, w.unsubscribe = configWatches.subscribe(host.NetworkID)
+
+	return w, nil
+}
+
+// Changed receives when the config may have changed since the last Revision.
+func (w *ConfigWatch) Changed() <-chan struct{} {
+	return w.changed
+}
+
+// Revision returns the stored revision of the host's config, which NotifyConfigChanges keeps current.
+// The config is only rendered for hosts that have none stored yet. It fails with gorm.ErrRecordNotFound
+// once the host is deleted.
+func (w *ConfigWatch) Revision() (string, error) {
+	var host models.Host
+	if err := database.Conn.Select("id", "network_id", "config_revision").First(&host, "id = ?", w.HostID).Error; err != nil {
+		return "", err
+	}
+
+	if host.NetworkID != w.networkID {
+		w.unsubscribe()
+		w.networkID = host.NetworkID
+		w.changed, w.unsubscribe = configWatches.subscribe(host.NetworkID)
+	}
+
+	if host.ConfigRevision != "" {
+		return host.ConfigRevision, nil
+	}
+
+	cfg, err := RenderHostConfig(database.Conn, w.HostID)
+	if err != nil {
+		return "", err
+	}
+
+	revision := models.ConfigRevision(cfg.YAML)
+	return revision, models.StoreConfigRevision(database.Conn, w.HostID, revision)
+}
+
+func (w *ConfigWatch) Close() {
+	w.unsubscribe()
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+)
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// ConfigDiff is the unified diff of a single host's rendered config.
+type ConfigDiff struct {
+	HostID   uuid.UUID `json:"hostId"`
+	HostName string    `json:"hostName"`
+	Diff     string    `json:"diff"`
+}
+
+// RenderedConfig is the YAML config of a host.
+type RenderedConfig struct {
+	Name      string
+	NetworkID uuid.UUID
+	YAML      string
+}
+
+// Preview applies change in a transaction that is always rolled back,
+// and returns the config diff of every host in the network, with the private keys redacted.
+func Preview(ctx context.Context, networkID uuid.UUID, change func(tx *gorm.DB) error) ([]ConfigDiff, error) {
+	var diffs []ConfigDiff
+
+	err := db(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := RedactedNetworkConfigs(tx, networkID)
+		if err != nil {
+			return err
+		}
+
+		if err := change(tx); err != nil {
+			return err
+		}
+
+		after, err := RedactedNetworkConfigs(tx, networkID)
+		if err != nil {
+			return err
+		}
+
+		diffs = DiffConfigs(before, after)
+
+		return errDryRun
+	})
+
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	return diffs, nil
+}
+
+// NotifyConfigChanges renders the configs of the network's hosts, stores the revision of every config
+// that changed, including those of new hosts, and queues config.changed for them. It also wakes the agents
+// watching configs of the network, which compare the stored revisions. Changes to lighthouses, relays
+// or the network affect the configs of other hosts too.
+func NotifyConfigChanges(tx *gorm.DB, networkID uuid.UUID) error {
+	if err := models.TouchNetworkConfig(tx, networkID); err != nil {
+		return err
+	}
+
+	hosts, err := models.NetworkHostsWithFullDetails(tx, networkID)
+	if err != nil {
+		return err
+	}
+
+	changes := []configChange{}
+	for i := range hosts {
+		cfg, err := renderHost(&hosts[i], false)
+		if err != nil {
+			return err
+		}
+
+		revision := models.ConfigRevision(cfg.YAML)
+		if revision == hosts[i].ConfigRevision {
+			continue
+		}
+
+		if err := models.StoreConfigRevision(tx, hosts[i].ID, revision); err != nil {
+			return err
+		}
+
+		changes = append(changes, configChange{HostID: hosts[i].ID, HostName: cfg.Name})
+	}
+
+	wanted, err := models.HasWebhooks(tx, models.WebhookEventConfigChanged, networkID)
+	if err != nil || !wanted {
+		return err
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].HostName < changes[j].HostName
+	})
+
+	for _, change := range changes {
+		if err := models.EnqueueWebhookEvent(tx, models.WebhookEventConfigChanged, networkID, change); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderNetworkConfigs renders the YAML config of every host in the network.
+func RenderNetworkConfigs(tx *gorm.DB, networkID uuid.UUID) (map[uuid.UUID]RenderedConfig, error) {
+	return renderNetworkConfigs(tx, networkID, false)
+}
+
+// RedactedNetworkConfigs renders the YAML config of every host in the network for diffs shown to callers,
+// which may not be allowed to read keys. Private keys are replaced by a fingerprint, so diffs still show
+// when a key changes.
+func RedactedNetworkConfigs(tx *gorm.DB, networkID uuid.UUID) (map[uuid.UUID]RenderedConfig, error) {
+	return renderNetworkConfigs(tx, networkID, true)
+}
+
+func renderNetworkConfigs(tx *gorm.DB, networkID uuid.UUID, redact bool) (map[uuid.UUID]RenderedConfig, error) {
+	hosts, err := models.NetworkHostsWithFullDetails(tx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[uuid.UUID]RenderedConfig, len(hosts))
+	for i := range hosts {
+		cfg, err := renderHost(&hosts[i], redact)
+		if err != nil {
+			return nil, err
+		}
+
+		configs[hosts[i].ID] = cfg
+	}
+
+	return configs, nil
+}
+
+// RenderHostConfig renders the YAML config of a host.
+func RenderHostConfig(tx *gorm.DB, id uuid.UUID) (RenderedConfig, error) {
+	var host models.Host
+	if err := tx.Scopes(models.PreloadHostWithFullDetails(id.String())).First(&host).Error; err != nil {
+		return RenderedConfig{}, err
+	}
+
+	return renderHost(&host, false)
+}
+
+// renderHost renders the config of a host loaded with its full details.
+func renderHost(host *models.Host, redact bool) (RenderedConfig, error) {
+	if redact {
+		redactConfigKeys(host)
+	}
+
+	yml, err := host.Marshal(true)
+	if err != nil {
+		return RenderedConfig{}, err
+	}
+
+	return RenderedConfig{Name: host.Name, NetworkID: host.NetworkID, YAML: yml}, nil
+}
+
+// redactConfigKeys replaces the private keys a host's config is rendered with by their fingerprints.
+func redactConfigKeys(host *models.Host) {
+	if host.Certificate != nil {
+		crt := *host.Certificate
+		crt.Key = []byte(redactedKey(string(crt.Key)))
+		host.Certificate = &crt
+	}
+
+	host.SSHHostKey = []byte(redactedKey(string(host.SSHHostKey)))
+
+	if host.Configuration != nil {
+		host.Configuration.SSHD.HostKey = redactedKey(host.Configuration.SSHD.HostKey)
+	}
+}
+
+func redactedKey(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("REDACTED sha256:%x", sum[:8])
+}
+
+// DiffConfigs returns the diff of every host whose config differs, sorted by host name.
+func DiffConfigs(before, after map[uuid.UUID]RenderedConfig) []ConfigDiff {
+	ids := make(map[uuid.UUID]bool)
+	for id := range before {
+		ids[id] = true
+	}
+	for id := range after {
+		ids[id] = true
+	}
+
+	diffs := []ConfigDiff{}
+	for id := range ids {
+		a, b := before[id], after[id]
+		if a.YAML == b.YAML {
+			continue
+		}
+
+		name := b.Name
+		if name == "" {
+			name = a.Name
+		}
+
+		diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(a.YAML),
+			B:        difflib.SplitLines(b.YAML),
+			FromFile: "a/" + name + "/config.yml",
+			ToFile:   "b/" + name + "/config.yml",
+			Context:  3,
+		})
+
+		diffs = append(diffs, ConfigDiff{HostID: id, HostName: name, Diff: diff})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].HostName < diffs[j].HostName
+	})
+
+	return diffs
+}
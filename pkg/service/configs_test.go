@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// testConfiguration returns a host configuration from its JSON.
+func testConfiguration(t *testing.T, js string) *models.Configuration {
+	t.Helper()
+
+	var cfg models.Configuration
+	if err := json.Unmarshal([]byte(js), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	return &cfg
+}
+
+func TestNotifyConfigChangesStoresRevisions(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsRead, models.ScopeHostsWrite)
+
+	n, err := CreateNetwork(ctx, testNetworkDto("office"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhook := models.Webhook{Name: "deploy", URL: "https://ci.example.com/hooks", Events: []string{models.WebhookEventConfigChanged}, Active: true}
+	if err := database.Conn.Create(&webhook).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	dtos := []models.HostDto{
+		testHostDto(n, "lighthouse-1", "100.100.0.1/16"),
+		testHostDto(n, "lighthouse-2", "100.100.0.2/16"),
+		testHostDto(n, "relay", "100.100.0.3/16"),
+		testHostDto(n, "laptop", "100.100.0.4/16"),
+	}
+	dtos[0].StaticAddresses = []models.StaticAddress{{Host: "203.0.113.1", Port: 4242}}
+	dtos[0].Configuration = testConfiguration(t, `{"lighthouse": {"amLighthouse": true}}`)
+	dtos[1].StaticAddresses = []models.StaticAddress{{Host: "203.0.113.2", Port: 4242}}
+	dtos[1].Configuration = testConfiguration(t, `{"lighthouse": {"amLighthouse": true}}`)
+	dtos[2].Configuration = testConfiguration(t, `{"relay": {"amRelay": true}}`)
+	dtos[3].Configuration = testConfiguration(t, `{"relay": {"useRelays": true}}`)
+
+	var hosts []*models.Host
+	for _, dto := range dtos {
+		host, _, err := CreateHost(ctx, dto, WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, host)
+	}
+
+	// The stored revisions are those of config.yml, rendered one host at a time
+	for _, host := range hosts {
+		var stored models.Host
+		if err := database.Conn.Select("config_revision").First(&stored, "id = ?", host.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := RenderHostConfig(database.Conn, host.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := models.ConfigRevision(cfg.YAML); stored.ConfigRevision != want {
+			t.Errorf("%s: stored revision %q, want %q", host.Name, stored.ConfigRevision, want)
+		}
+	}
+
+	// Moving a lighthouse changes the config of every other host, but not its own
+	var before int64
+	database.Conn.Model(&models.WebhookDelivery{}).Count(&before)
+
+	update := models.Host{StaticAddresses: []models.StaticAddress{{Host: "203.0.113.9", Port: 4242}}}
+	if _, _, err := UpdateHost(ctx, hosts[0].ID.String(), update, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var after int64
+	database.Conn.Model(&models.WebhookDelivery{}).Count(&after)
+	if after-before != 3 {
+		t.Errorf("updating a lighthouse queued %d config.changed events, want 3", after-before)
+	}
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ForbiddenError is returned when the caller may not perform an operation.
+type ForbiddenError struct {
+	Message string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
+// PreconditionFailedError is returned when the resource no longer has the ETag of WriteOptions.IfMatch.
+type PreconditionFailedError struct {
+	ETag string // Current ETag of the resource.
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return "If-Match does not match the current ETag " + e.ETag + " of the resource."
+}
+
+// ConfirmationRequiredError is returned when a network that still has hosts is deleted without confirming its name.
+type ConfirmationRequiredError struct {
+	Network string
+	Hosts   int64
+}
+
+func (e *ConfirmationRequiredError) Error() string {
+	return fmt.Sprintf("The network still has %d hosts. Repeat the request with ?confirm=%s to delete it with them.", e.Hosts, url.QueryEscape(e.Network))
+}
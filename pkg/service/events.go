@@ -0,0 +1,40 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// configChange is the data of config.changed events. Configs hold private keys, so receivers
+// fetch the new config from /hosts/{id}/config.yml rather than getting it in the event.
+type configChange struct {
+	HostID   uuid.UUID `json:"hostId"`
+	HostName string    `json:"hostName"`
+}
+
+// QueueHostEvent queues a host event with the host's private keys left out.
+func QueueHostEvent(tx *gorm.DB, event string, host models.Host) error {
+	host.RedactKeys()
+	host.Network = nil
+
+	return models.EnqueueWebhookEvent(tx, event, host.NetworkID, host)
+}
+
+// QueueCertificatesIssued queues certificate.issued for each certificate, with its private key left out.
+func QueueCertificatesIssued(tx *gorm.DB, networkID uuid.UUID, certs ...*models.Certificate) error {
+	for _, crt := range certs {
+		if crt == nil {
+			continue
+		}
+
+		issued := *crt
+		issued.RedactKeys()
+
+		if err := models.EnqueueWebhookEvent(tx, models.WebhookEventCertificateIssued, networkID, issued); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
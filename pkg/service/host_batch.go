@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ErrBatchFailed is returned by BatchCreateHosts when any row failed, rolling back the whole batch.
+var ErrBatchFailed = errors.New("batch failed")
+
+// BatchHostResult is the outcome of one row of a batch.
+type BatchHostResult struct {
+	Row    int // 1-based position of the host in the batch.
+	Name   string
+	Host   *models.Host // The created host, or nil when the batch failed.
+	Errors []error
+}
+
+// BatchCreateHosts creates hosts in a network in one transaction. Hosts without an IP get the next free address
+// of the network. When any row fails, no host is created and ErrBatchFailed is returned with the errors of every row.
+// The private keys of the certificates are left out unless the caller may read the keys of the network.
+func BatchCreateHosts(ctx context.Context, networkID string, dtos []models.HostDto) ([]BatchHostResult, error) {
+	n, err := findNetwork(ctx, networkID, models.ActionHostCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchHostResult, len(dtos))
+	failed := false
+
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		used, err := models.UsedHostIPs(tx, n.ID)
+		if err != nil {
+			return err
+		}
+
+		// Reserve the IPs given in the batch before allocating the missing ones
+		names := map[string]int{}
+		ips := map[netip.Addr]int{}
+		for i, dto := range dtos {
+			results[i] = BatchHostResult{Row: i + 1, Name: dto.Name}
+
+			if strings.TrimSpace(dto.Name) == "" {
+				results[i].Errors = append(results[i].Errors, models.NewFieldError("name", "name cannot be empty"))
+			} else if row, dup := names[dto.Name]; dup {
+				results[i].Errors = append(results[i].Errors, models.NewFieldError("name", "name is already used by row "+strconv.Itoa(row)))
+			} else {
+				names[dto.Name] = i + 1
+			}
+
+			if prefix, err := netip.ParsePrefix(dto.IP); err == nil {
+				if row, dup := ips[prefix.Addr()]; dup {
+					results[i].Errors = append(results[i].Errors, models.NewFieldError("ip", "ip is already used by row "+strconv.Itoa(row)))
+				}
+				ips[prefix.Addr()] = i + 1
+				used[prefix.Addr()] = true
+			}
+		}
+
+		for i, dto := range dtos {
+			if len(results[i].Errors) > 0 {
+				failed = true
+				continue
+			}
+
+			dto.NetworkID = n.ID
+			if dto.IP == "" {
+				ip, err := n.AllocateIP(used)
+				if err != nil {
+					results[i].Errors = append(results[i].Errors, err)
+					failed = true
+					continue
+				}
+				dto.IP = ip
+			}
+
+			host := HostFromDto(dto)
+
+			// Each row gets a savepoint, so that every failing row is reported
+			savepoint := "batch_row_" + strconv.Itoa(i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return err
+			}
+
+			err := tx.Create(&host).Error
+			if err == nil {
+				err = Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceHost, host.ID, &host.NetworkID, nil, host)
+			}
+			if err == nil {
+				err = AuditCertificates(ctx, tx, models.AuditActionCreate, host.NetworkID, host.Certificate)
+			}
+			if err == nil {
+				err = QueueHostEvent(tx, models.WebhookEventHostCreated, host)
+			}
+			if err == nil {
+				err = QueueCertificatesIssued(tx, host.NetworkID, host.Certificate)
+			}
+
+			if err != nil {
+				if err := tx.RollbackTo(savepoint).Error; err != nil {
+					return err
+				}
+
+				results[i].Errors = append(results[i].Errors, err)
+				failed = true
+				continue
+			}
+
+			results[i].Host = &host
+		}
+
+		if failed {
+			return ErrBatchFailed
+		}
+
+		return NotifyConfigChanges(tx, n.ID)
+	})
+
+	if failed {
+		for i := range results {
+			results[i].Host = nil
+		}
+
+		return results, ErrBatchFailed
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Private keys require the keys:read scope and role
+	if !canReadKeys(ctx, n.ID) {
+		for i := range results {
+			results[i].Host.RedactKeys()
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListHosts returns the hosts the caller may read, filtered, sorted and paginated by params.
+func ListHosts(ctx context.Context, params models.ListParams) ([]models.Host, error) {
+	var hosts []models.Host
+
+	err := db(ctx).Model(&models.Host{}).
+		Scopes(
+			models.Filter(params, models.HostQuery),
+			models.AccessibleBy(CallerFromContext(ctx).Token, "hosts.network_id", models.ActionHostRead),
+			models.Sort(params, models.HostQuery),
+			models.PaginateByCursor(params, "hosts"),
+		).
+		Find(&hosts).Error
+
+	return hosts, err
+}
+
+// ListNetworkHosts returns the hosts of a network, filtered, sorted and paginated by params.
+func ListNetworkHosts(ctx context.Context, networkID string, params models.ListParams) ([]models.Host, error) {
+	var n models.Network
+
+	if err := db(ctx).Omit(clause.Associations).First(&n, "id = ?", networkID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionHostRead); err != nil {
+		return nil, err
+	}
+
+	var hosts []models.Host
+
+	err := db(ctx).Model(&models.Host{}).
+		Where("hosts.network_id = ?", n.ID).
+		Scopes(models.Filter(params, models.HostQuery), models.Sort(params, models.HostQuery), models.PaginateByCursor(params, "hosts")).
+		Find(&hosts).Error
+
+	return hosts, err
+}
+
+// GetHost returns a host with its configuration.
+func GetHost(ctx context.Context, id string) (*models.Host, error) {
+	var host models.Host
+
+	if err := db(ctx).Preload("Configuration").First(&host, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, host.NetworkID, models.ActionHostRead); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// GetNetworkHost returns a host of a network by its name, with its configuration.
+func GetNetworkHost(ctx context.Context, networkID, name string) (*models.Host, error) {
+	n, err := findNetwork(ctx, networkID, models.ActionHostRead)
+	if err != nil {
+		return nil, err
+	}
+
+	var host models.Host
+	if err := db(ctx).Preload("Configuration").First(&host, "network_id = ? AND name = ?", n.ID, name).Error; err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// CreateHost creates a host in the network of dto, signing its certificate. Dry runs return the config diffs instead.
+// The private key of the certificate is left out unless the caller may read the keys of the network.
+func CreateHost(ctx context.Context, dto models.HostDto, opts WriteOptions) (*models.Host, []ConfigDiff, error) {
+	host := HostFromDto(dto)
+
+	if err := authorize(ctx, host.NetworkID, models.ActionHostCreate); err != nil {
+		return nil, nil, err
+	}
+
+	create := func(tx *gorm.DB) error {
+		return tx.Create(&host).Error
+	}
+
+	if opts.DryRun {
+		diffs, err := Preview(ctx, host.NetworkID, create)
+		return nil, diffs, err
+	}
+
+	err := db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := create(tx); err != nil {
+			return err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceHost, host.ID, &host.NetworkID, nil, host); err != nil {
+			return err
+		}
+
+		if err := AuditCertificates(ctx, tx, models.AuditActionCreate, host.NetworkID, host.Certificate); err != nil {
+			return err
+		}
+
+		if err := QueueHostEvent(tx, models.WebhookEventHostCreated, host); err != nil {
+			return err
+		}
+
+		if err := QueueCertificatesIssued(tx, host.NetworkID, host.Certificate); err != nil {
+			return err
+		}
+
+		return NotifyConfigChanges(tx, host.NetworkID)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Private keys require the keys:read scope and role
+	if !canReadKeys(ctx, host.NetworkID) {
+		host.RedactKeys()
+	}
+
+	return &host, nil, nil
+}
+
+// CreateNetworkHost is CreateHost in the network networkID, whatever network dto names.
+func CreateNetworkHost(ctx context.Context, networkID string, dto models.HostDto, opts WriteOptions) (*models.Host, []ConfigDiff, error) {
+	n, err := findNetwork(ctx, networkID, models.ActionHostCreate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dto.NetworkID = n.ID
+
+	return CreateHost(ctx, dto, opts)
+}
+
+// UpdateHost updates the set fields of update on a host, and its configuration when update has one.
+// Setting another network moves the host there. Dry runs return the config diffs instead.
+func UpdateHost(ctx context.Context, id string, update models.Host, opts WriteOptions) (*models.Host, []ConfigDiff, error) {
+	var host models.Host
+
+	if err := db(ctx).Preload("Configuration").First(&host, "id = ?", id).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if err := authorize(ctx, host.NetworkID, models.ActionHostUpdate); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkIfMatch(opts.IfMatch, host.ETag()); err != nil {
+		return nil, nil, err
+	}
+
+	// Moving a host into another network requires enrolling it there
+	moved := update.NetworkID != uuid.Nil && update.NetworkID != host.NetworkID
+	if moved {
+		if err := authorize(ctx, update.NetworkID, models.ActionHostCreate); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	hasCfg := update.Configuration != nil
+
+	// - Full association saving is conditionally enabled when a "Configuration" update is present.
+	// - Versions are only changed by BumpVersion, which fails if another update came first.
+	update.ID = host.ID
+	update.Version = 0
+	if hasCfg {
+		update.Configuration.ID = host.Configuration.ID
+		update.ConfigurationID = host.ConfigurationID
+		update.Configuration.Version = host.Configuration.Version + 1
+	}
+
+	save := func(tx *gorm.DB) error {
+		if err := models.BumpVersion(tx, &models.Host{}, host.ID, host.Version); err != nil {
+			return err
+		}
+
+		if hasCfg {
+			if err := models.BumpVersion(tx, &models.Configuration{}, host.Configuration.ID, host.Configuration.Version); err != nil {
+				return err
+			}
+		}
+
+		return tx.Session(&gorm.Session{FullSaveAssociations: hasCfg}).Updates(&update).Error
+	}
+
+	if opts.DryRun {
+		diffs, err := Preview(ctx, host.NetworkID, save)
+		return nil, diffs, err
+	}
+
+	// Update the host and refresh it
+	before := host
+	if host.Configuration != nil {
+		cfg := *host.Configuration
+		before.Configuration = &cfg
+	}
+
+	err := db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := save(tx); err != nil {
+			return err
+		}
+
+		if err := tx.Preload("Configuration").First(&host, "id = ?", host.ID).Error; err != nil {
+			return err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionUpdate, models.AuditResourceHost, host.ID, &host.NetworkID, before, host); err != nil {
+			return err
+		}
+
+		if err := QueueHostEvent(tx, models.WebhookEventHostUpdated, host); err != nil {
+			return err
+		}
+
+		// Hosts moved into another network change the configs of both networks
+		if moved {
+			if err := NotifyConfigChanges(tx, before.NetworkID); err != nil {
+				return err
+			}
+
+			return NotifyConfigChanges(tx, host.NetworkID)
+		}
+
+		return NotifyConfigChanges(tx, host.NetworkID)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &host, nil, nil
+}
+
+// DeleteHost deletes a host. It can be restored until it is purged.
+func DeleteHost(ctx context.Context, id string) error {
+	var host models.Host
+
+	if err := db(ctx).First(&host, "id = ?", id).Error; err != nil {
+		return err
+	}
+
+	if err := authorize(ctx, host.NetworkID, models.ActionHostDelete); err != nil {
+		return err
+	}
+
+	return db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := Audit(ctx, tx, models.AuditActionDelete, models.AuditResourceHost, host.ID, &host.NetworkID, host, nil); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&host).Error; err != nil {
+			return err
+		}
+
+		if err := QueueHostEvent(tx, models.WebhookEventHostDeleted, host); err != nil {
+			return err
+		}
+
+		return NotifyConfigChanges(tx, host.NetworkID)
+	})
+}
+
+// HostConfig returns the rendered YAML config of a host and its revision. Configs hold private keys,
+// so the caller's role must allow reading keys, and the download is recorded in the audit log.
+func HostConfig(ctx context.Context, id string) (yml, revision string, err error) {
+	var host models.Host
+
+	if err := db(ctx).Scopes(models.PreloadHostWithFullDetails(id)).First(&host).Error; err != nil {
+		return "", "", err
+	}
+
+	if err := authorize(ctx, host.NetworkID, models.ActionKeysRead); err != nil {
+		return "", "", err
+	}
+
+	// Handing out key material is recorded in the audit log
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		return Audit(ctx, tx, models.AuditActionDownload, models.AuditResourceHost, host.ID, &host.NetworkID, nil, nil)
+	})
+
+	if err != nil {
+		return "", "", err
+	}
+
+	yml, _ = host.Marshal(true)
+
+	return yml, models.ConfigRevision(yml), nil
+}
+
+// HostFromDto maps a create payload onto a host model
+func HostFromDto(dto models.HostDto) models.Host {
+	return models.Host{
+		IP:              dto.IP,
+		Name:            dto.Name,
+		Groups:          dto.Groups,
+		Subnets:         dto.Subnets,
+		Site:            dto.Site,
+		Lighthouses:     dto.Lighthouses,
+		Relays:          dto.Relays,
+		NetworkID:       dto.NetworkID,
+		Configuration:   dto.Configuration,
+		InPub:           []byte(dto.InPub),
+		StaticAddresses: dto.StaticAddresses,
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+// testListParams returns list parameters with the query string query.
+func testListParams(query string) models.ListParams {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+
+	return c
+}
+
+func TestListHostsByIPRange(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsRead, models.ScopeHostsWrite)
+
+	n, err := CreateNetwork(ctx, testNetworkDto("office"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]string{}
+	for name, ip := range map[string]string{"web": "100.100.0.1/16", "db": "100.100.0.2/16", "old": "100.100.1.3/16"} {
+		host, _, err := CreateHost(ctx, testHostDto(n, name, ip), WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = host.ID.String()
+	}
+
+	// Updated and deleted hosts are matched by their current IP
+	if _, _, err := UpdateHost(ctx, ids["db"], models.Host{IP: "100.100.1.2/16"}, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteHost(ctx, ids["old"]); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"ip=100.100.0.0/24":              "[web]",
+		"ip=100.100.1.0/24":              "[db]",
+		"ip=100.100.0.0/16&sort=name":    "[db web]",
+		"ip=100.100.1.0/24&deleted=true": "[old]",
+	}
+
+	for query, want := range tests {
+		hosts, err := ListHosts(ctx, testListParams(query))
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+
+		names := make([]string, len(hosts))
+		for i, h := range hosts {
+			names[i] = h.Name
+		}
+
+		if got := fmt.Sprint(names); got != want {
+			t.Errorf("%s: got %s, want %s", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RotateNetworkCA issues a new CA for a network. Hosts signed afterwards get certificates of the new CA,
+// while the previous CAs stay trusted until they expire, so existing certificates keep working.
+// The key of the new CA is left out unless the caller may read the keys of the network.
+func RotateNetworkCA(ctx context.Context, id string) (*models.Certificate, error) {
+	var n models.Network
+
+	if err := db(ctx).Omit(clause.Associations).First(&n, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionNetworkUpdate); err != nil {
+		return nil, err
+	}
+
+	ca, err := n.NewCA()
+	if err != nil {
+		return nil, err
+	}
+	ca.OwnerID, ca.OwnerType = n.ID, "networks"
+
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ca).Error; err != nil {
+			return err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceCertificate, ca.ID, &n.ID, nil, ca); err != nil {
+			return err
+		}
+
+		rotated := *ca
+		rotated.RedactKeys()
+
+		if err := models.EnqueueWebhookEvent(tx, models.WebhookEventCARotated, n.ID, rotated); err != nil {
+			return err
+		}
+
+		return NotifyConfigChanges(tx, n.ID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !canReadKeys(ctx, n.ID) {
+		ca.RedactKeys()
+	}
+
+	return ca, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/slackhq/nebula/cert"
+)
+
+func TestRotateNetworkCA(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+
+	n, err := CreateNetwork(ctx, testNetworkDto("office"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhook := models.Webhook{Name: "deploy", URL: "https://ci.example.com/hooks", Events: []string{models.WebhookEventCARotated}, Active: true}
+	if err := database.Conn.Create(&webhook).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := RotateNetworkCA(ctx, n.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ca.Key) > 0 {
+		t.Error("the new CA has a key without keys:read")
+	}
+
+	// Hosts are signed by the new CA, while the previous CA stays trusted
+	host, _, err := CreateHost(ctx, testHostDto(n, "web", "100.100.0.1/16"), WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cas int64
+	database.Conn.Model(&models.Certificate{}).Where("owner_id = ? AND is_ca = ?", n.ID, true).Count(&cas)
+	if cas != 2 {
+		t.Errorf("the network has %d CAs, want 2", cas)
+	}
+
+	hostCert, _, err := cert.UnmarshalNebulaCertificateFromPEM(host.Certificate.Crt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _, err := cert.UnmarshalNebulaCertificateFromPEM(ca.Crt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint, _ := caCert.Sha256Sum(); hostCert.Details.Issuer != fingerprint {
+		t.Error("the host certificate is not signed by the new CA")
+	}
+
+	var delivery models.WebhookDelivery
+	if err := database.Conn.First(&delivery, "event = ?", models.WebhookEventCARotated).Error; err != nil {
+		t.Fatalf("no ca.rotated event was queued: %v", err)
+	}
+
+	var event struct {
+		Data models.Certificate `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(delivery.Payload), &event); err != nil || event.Data.ID != ca.ID || len(event.Data.Key) > 0 {
+		t.Errorf("got ca.rotated payload %s, want the new CA without its key", delivery.Payload)
+	}
+}
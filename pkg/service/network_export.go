@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ImportOptions is how a network export is imported.
+type ImportOptions struct {
+	Passphrase     string     // Passphrase of an encrypted export.
+	RemapIDs       bool       // Give everything new IDs instead of keeping those of the export.
+	OrganizationID *uuid.UUID // Organization to import the network into, see NetworkOrganization.
+	Name           string     // Name of the imported network, instead of its exported name.
+}
+
+// ExportNetwork exports a network with its CAs, hosts, configurations and certificates, including their private keys,
+// encrypted with passphrase unless it is empty. Handing out the keys is recorded in the audit log.
+func ExportNetwork(ctx context.Context, id, passphrase string) (*models.Network, *models.NetworkExport, error) {
+	// The export holds the private keys of the CAs and every host
+	n, err := findNetwork(ctx, id, models.ActionHostRead)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionKeysRead); err != nil {
+		return nil, nil, err
+	}
+
+	archive, err := models.ExportNetwork(db(ctx), n.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	export, err := models.NewNetworkExport(archive, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		return Audit(ctx, tx, models.AuditActionDownload, models.AuditResourceNetwork, n.ID, &n.ID, nil, nil)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return n, export, nil
+}
+
+// ImportNetwork imports a network exported with ExportNetwork, with its hosts, and makes the caller its owner.
+// Keys are left out of the returned network unless the caller may read them.
+func ImportNetwork(ctx context.Context, export models.NetworkExport, opts ImportOptions) (*models.Network, error) {
+	token := CallerFromContext(ctx).Token
+	if token == nil {
+		return nil, &ForbiddenError{Message: "An API token is required."}
+	}
+
+	organizationID, err := NetworkOrganization(ctx, opts.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := export.Open(opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.RemapIDs {
+		archive.RemapIDs()
+	}
+
+	if opts.Name != "" {
+		archive.Network.Name = opts.Name
+	}
+
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := archive.Import(tx, organizationID); err != nil {
+			return err
+		}
+
+		n := archive.Network
+		if err := Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceNetwork, n.ID, &n.ID, nil, n); err != nil {
+			return err
+		}
+
+		if err := AuditCertificates(ctx, tx, models.AuditActionCreate, n.ID, CertificatePointers(n.Ca)...); err != nil {
+			return err
+		}
+
+		for _, h := range archive.Hosts {
+			if err := Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceHost, h.ID, &n.ID, nil, h.Host); err != nil {
+				return err
+			}
+
+			if err := AuditCertificates(ctx, tx, models.AuditActionCreate, n.ID, h.Certificate); err != nil {
+				return err
+			}
+
+			if err := QueueHostEvent(tx, models.WebhookEventHostCreated, h.Host); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.RoleBinding{
+			NetworkID: n.ID,
+			TokenID:   token.ID,
+			Role:      models.RoleOwner,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	n := archive.Network
+	for _, h := range archive.Hosts {
+		n.Hosts = append(n.Hosts, h.Host)
+	}
+
+	if !canReadKeys(ctx, n.ID) {
+		n.RedactKeys()
+		for i := range n.Hosts {
+			n.Hosts[i].RedactKeys()
+		}
+	}
+
+	return &n, nil
+}
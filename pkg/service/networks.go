@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListNetworks returns the networks the caller may read, filtered, sorted and paginated by params.
+// Their passphrases are left out unless the caller may read their keys.
+func ListNetworks(ctx context.Context, params models.ListParams) ([]models.Network, error) {
+	var networks []models.Network
+
+	err := db(ctx).Model(&models.Network{}).
+		Scopes(
+			models.Filter(params, models.NetworkQuery),
+			models.AccessibleBy(CallerFromContext(ctx).Token, "networks.id", models.ActionNetworkRead),
+			models.Sort(params, models.NetworkQuery),
+			models.Paginate(params),
+		).
+		Find(&networks).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Passphrases require the keys:read scope and role
+	for i := range networks {
+		if !canReadKeys(ctx, networks[i].ID) {
+			networks[i].RedactKeys()
+		}
+	}
+
+	return networks, nil
+}
+
+// findNetwork returns a network without its associations, when the caller's role allows action on it.
+func findNetwork(ctx context.Context, id, action string) (*models.Network, error) {
+	var n models.Network
+
+	if err := db(ctx).Omit(clause.Associations).First(&n, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, n.ID, action); err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// GetNetwork returns a network with its CAs and hosts. The CA private keys are left out unless the caller may read them.
+func GetNetwork(ctx context.Context, id string) (*models.Network, error) {
+	var n models.Network
+
+	if err := db(ctx).Preload("Ca").Preload("Hosts").First(&n, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionNetworkRead); err != nil {
+		return nil, err
+	}
+
+	// CA private keys require the keys:read scope and role
+	if !canReadKeys(ctx, n.ID) {
+		n.RedactKeys()
+	}
+
+	return &n, nil
+}
+
+// GetNetworkHosts returns a network with its hosts and their configurations, for callers allowed to read its hosts.
+func GetNetworkHosts(ctx context.Context, id string) (*models.Network, error) {
+	var n models.Network
+
+	if err := db(ctx).Preload("Hosts.Configuration").First(&n, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionHostRead); err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// CreateNetwork creates a network in the caller's organization, making the caller its owner.
+// The CA private keys and passphrase are left out unless the caller may read them.
+func CreateNetwork(ctx context.Context, dto models.NetworkDto) (*models.Network, error) {
+	token := CallerFromContext(ctx).Token
+	if token == nil {
+		return nil, &ForbiddenError{Message: "An API token is required."}
+	}
+
+	organizationID, err := NetworkOrganization(ctx, dto.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	n := NetworkFromDto(dto)
+	n.OrganizationID = organizationID
+
+	// Save to the database, making the caller the owner of the network
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&n).Error; err != nil {
+			return err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionCreate, models.AuditResourceNetwork, n.ID, &n.ID, nil, n); err != nil {
+			return err
+		}
+
+		if err := AuditCertificates(ctx, tx, models.AuditActionCreate, n.ID, CertificatePointers(n.Ca)...); err != nil {
+			return err
+		}
+
+		if err := QueueCertificatesIssued(tx, n.ID, CertificatePointers(n.Ca)...); err != nil {
+			return err
+		}
+
+		return tx.Create(&models.RoleBinding{
+			NetworkID: n.ID,
+			TokenID:   token.ID,
+			Role:      models.RoleOwner,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// CA private keys require the keys:read scope and role
+	if !canReadKeys(ctx, n.ID) {
+		n.RedactKeys()
+	}
+
+	return &n, nil
+}
+
+// UpdateNetwork updates the set fields of dto on a network. Dry runs return the config diffs instead.
+func UpdateNetwork(ctx context.Context, id string, dto models.NetworkDto, opts WriteOptions) (*models.Network, []ConfigDiff, error) {
+	var n models.Network
+
+	if err := db(ctx).Omit(clause.Associations).First(&n, "id = ?", id).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionNetworkUpdate); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkIfMatch(opts.IfMatch, n.ETag()); err != nil {
+		return nil, nil, err
+	}
+
+	update := NetworkFromDto(dto)
+	save := func(tx *gorm.DB) error {
+		if err := models.BumpVersion(tx, &models.Network{}, n.ID, n.Version); err != nil {
+			return err
+		}
+
+		return tx.Model(&n).Updates(&update).Error
+	}
+
+	if opts.DryRun {
+		diffs, err := Preview(ctx, n.ID, save)
+		return nil, diffs, err
+	}
+
+	// Save the updated network to the database
+	before := n
+	err := db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := save(tx); err != nil {
+			return err
+		}
+
+		if err := tx.First(&n, "id = ?", n.ID).Error; err != nil {
+			return err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionUpdate, models.AuditResourceNetwork, n.ID, &n.ID, before, n); err != nil {
+			return err
+		}
+
+		return NotifyConfigChanges(tx, n.ID)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Passphrases require the keys:read scope and role
+	if !canReadKeys(ctx, n.ID) {
+		n.RedactKeys()
+	}
+
+	return &n, nil, nil
+}
+
+// DeleteNetwork deletes a network together with its hosts. A network that still has hosts is only
+// deleted when confirm is its name.
+func DeleteNetwork(ctx context.Context, id, confirm string) error {
+	var n models.Network
+
+	if err := db(ctx).Omit(clause.Associations).First(&n, "id = ?", id).Error; err != nil {
+		return err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionNetworkDelete); err != nil {
+		return err
+	}
+
+	var hosts int64
+	if err := db(ctx).Model(&models.Host{}).Where("network_id = ?", n.ID).Count(&hosts).Error; err != nil {
+		return err
+	}
+
+	if hosts > 0 && confirm != n.Name {
+		return &ConfirmationRequiredError{Network: n.Name, Hosts: hosts}
+	}
+
+	// Delete the network with its hosts, recording it in the audit log
+	return db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := Audit(ctx, tx, models.AuditActionDelete, models.AuditResourceNetwork, n.ID, &n.ID, n, nil); err != nil {
+			return err
+		}
+
+		return n.SoftDelete(tx)
+	})
+}
+
+// NetworkFromDto maps a create/update payload onto a network model
+func NetworkFromDto(dto models.NetworkDto) models.Network {
+	return models.Network{
+		Name:             dto.Name,             // Name of the network
+		IPs:              dto.IPs,              // List of IP ranges
+		Subnets:          dto.Subnets,          // List of subnets
+		Groups:           dto.Groups,           // Associated groups
+		Duration:         dto.Duration,         // Duration in seconds
+		Encrypt:          dto.Encrypt,          // Whether encryption is enabled
+		Passphrase:       dto.Passphrase,       // Encryption passphrase
+		ArgonMemory:      dto.ArgonMemory,      // Memory usage for Argon2
+		ArgonIterations:  dto.ArgonIterations,  // Iterations for Argon2
+		ArgonParallelism: dto.ArgonParallelism, // Parallelism for Argon2
+		Curve:            dto.Curve,            // Cryptographic curve (e.g., 25519)
+		SSHUsers:         dto.SSHUsers,         // SSH admin users for every host
+	}
+}
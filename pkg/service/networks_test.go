@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm/logger"
+)
+
+// connectTestDB points database.Conn at a fresh SQLite database.
+func connectTestDB(t *testing.T) {
+	t.Helper()
+
+	database.Connect(config.DatabaseConfig{SQLitePath: filepath.Join(t.TempDir(), "koodnet.db")})
+	database.Conn.Logger = logger.Discard
+	database.Migrate()
+}
+
+// newTestCaller creates an API token with scopes, and returns a context with it as the caller.
+func newTestCaller(t *testing.T, scopes ...string) context.Context {
+	t.Helper()
+
+	token, _, err := models.NewAPIToken("test", scopes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Conn.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return NewContext(context.Background(), Caller{Token: token, RequestID: "test"})
+}
+
+// bindTestRole gives the token of ctx role on a network.
+func bindTestRole(t *testing.T, ctx context.Context, n *models.Network, role string) {
+	t.Helper()
+
+	binding := models.RoleBinding{NetworkID: n.ID, TokenID: CallerFromContext(ctx).Token.ID, Role: role}
+	if err := database.Conn.Create(&binding).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testNetworkDto(name string) models.NetworkDto {
+	return models.NetworkDto{
+		Name:     name,
+		IPs:      []string{"100.100.0.0/16"},
+		Duration: 24 * time.Hour,
+		Curve:    "25519",
+	}
+}
+
+func testHostDto(n *models.Network, name, ip string) models.HostDto {
+	return models.HostDto{NetworkID: n.ID, Name: name, IP: ip}
+}
+
+func TestCreateRedactsKeysWithoutKeysRead(t *testing.T) {
+	connectTestDB(t)
+
+	for _, withKeys := range []bool{false, true} {
+		scopes := []string{models.ScopeNetworksWrite, models.ScopeHostsWrite}
+		if withKeys {
+			scopes = append(scopes, models.ScopeKeysRead)
+		}
+		ctx := newTestCaller(t, scopes...)
+
+		n, err := CreateNetwork(ctx, testNetworkDto(fmt.Sprint("keys-", withKeys)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := len(n.Ca) > 0 && len(n.Ca[0].Key) > 0; got != withKeys {
+			t.Errorf("keys:read %v: created network has keys %v", withKeys, got)
+		}
+
+		host, _, err := CreateHost(ctx, testHostDto(n, "web", "100.100.0.1/16"), WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := host.Certificate != nil && len(host.Certificate.Key) > 0; got != withKeys {
+			t.Errorf("keys:read %v: created host has a key %v", withKeys, got)
+		}
+
+		results, err := BatchCreateHosts(ctx, n.ID.String(), []models.HostDto{{Name: "db", IP: "100.100.0.2/16"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := results[0].Host.Certificate != nil && len(results[0].Host.Certificate.Key) > 0; got != withKeys {
+			t.Errorf("keys:read %v: batch created host has a key %v", withKeys, got)
+		}
+	}
+}
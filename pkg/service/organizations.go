@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// requirePlatformToken returns a ForbiddenError unless the caller's API token is platform-wide.
+func requirePlatformToken(ctx context.Context) error {
+	token := CallerFromContext(ctx).Token
+	if token != nil && token.OrganizationID == nil {
+		return nil
+	}
+
+	return &ForbiddenError{Message: "Only platform-wide API tokens can manage organizations."}
+}
+
+// ListOrganizations returns the organizations visible to the caller, paginated by params.
+func ListOrganizations(ctx context.Context, params models.ListParams) ([]models.Organization, error) {
+	var organizations []models.Organization
+
+	query := db(ctx).Model(&models.Organization{})
+	if token := CallerFromContext(ctx).Token; token != nil && token.OrganizationID != nil {
+		query = query.Where("id = ?", *token.OrganizationID)
+	}
+
+	if err := query.Scopes(models.Paginate(params)).Find(&organizations).Error; err != nil {
+		return nil, err
+	}
+
+	return organizations, nil
+}
+
+// GetOrganization returns an organization. Organizations the caller is not a member of are not found.
+func GetOrganization(ctx context.Context, id string) (*models.Organization, error) {
+	var org models.Organization
+
+	if err := db(ctx).First(&org, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if token := CallerFromContext(ctx).Token; token == nil || !token.InOrganization(&org.ID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &org, nil
+}
+
+// CreateOrganization creates an organization. Only platform-wide API tokens manage organizations.
+func CreateOrganization(ctx context.Context, dto models.OrganizationDto) (*models.Organization, error) {
+	if err := requirePlatformToken(ctx); err != nil {
+		return nil, err
+	}
+
+	org := models.Organization{
+		Name:        dto.Name,
+		MaxNetworks: dto.MaxNetworks,
+		MaxHosts:    dto.MaxHosts,
+	}
+
+	if err := db(ctx).Create(&org).Error; err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// UpdateOrganization updates the set fields of dto on an organization.
+func UpdateOrganization(ctx context.Context, id string, dto models.OrganizationDto) (*models.Organization, error) {
+	if err := requirePlatformToken(ctx); err != nil {
+		return nil, err
+	}
+
+	org, err := GetOrganization(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	update := models.Organization{
+		Name:        dto.Name,
+		MaxNetworks: dto.MaxNetworks,
+		MaxHosts:    dto.MaxHosts,
+	}
+
+	if err := db(ctx).Model(org).Updates(&update).Error; err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// DeleteOrganization deletes an organization. It fails with models.ErrOrganizationNotEmpty while the organization
+// still has networks, including deleted networks that are not purged yet, so that no network, CA or host is
+// removed without its own deletion being recorded.
+func DeleteOrganization(ctx context.Context, id string) error {
+	if err := requirePlatformToken(ctx); err != nil {
+		return err
+	}
+
+	org, err := GetOrganization(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return db(ctx).Transaction(func(tx *gorm.DB) error {
+		var networks int64
+		if err := tx.Unscoped().Model(&models.Network{}).Where("organization_id = ?", org.ID).Count(&networks).Error; err != nil {
+			return err
+		}
+
+		if networks > 0 {
+			return models.ErrOrganizationNotEmpty
+		}
+
+		return tx.Delete(org).Error
+	})
+}
+
+// ListOrganizationNetworks returns the networks of an organization that the caller may read, filtered, sorted
+// and paginated by params. Their passphrases are left out unless the caller may read their keys.
+func ListOrganizationNetworks(ctx context.Context, id string, params models.ListParams) ([]models.Network, error) {
+	org, err := GetOrganization(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []models.Network
+
+	err = db(ctx).Model(&models.Network{}).
+		Where("organization_id = ?", org.ID).
+		Scopes(
+			models.Filter(params, models.NetworkQuery),
+			models.AccessibleBy(CallerFromContext(ctx).Token, "networks.id", models.ActionNetworkRead),
+			models.Sort(params, models.NetworkQuery),
+			models.Paginate(params),
+		).
+		Find(&networks).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Passphrases require the keys:read scope and role
+	for i := range networks {
+		if !canReadKeys(ctx, networks[i].ID) {
+			networks[i].RedactKeys()
+		}
+	}
+
+	return networks, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+func TestDeleteOrganizationRefusesNetworks(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeAdmin, models.ScopeNetworksWrite)
+
+	org, err := CreateOrganization(ctx, models.OrganizationDto{Name: "payments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dto := testNetworkDto("office")
+	dto.OrganizationID = &org.ID
+
+	n, err := CreateNetwork(ctx, dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteOrganization(ctx, org.ID.String()); !errors.Is(err, models.ErrOrganizationNotEmpty) {
+		t.Fatalf("deleting an organization with a network got %v, want ErrOrganizationNotEmpty", err)
+	}
+
+	// Deleted networks are kept with their CAs until they are purged
+	if err := DeleteNetwork(ctx, n.ID.String(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteOrganization(ctx, org.ID.String()); !errors.Is(err, models.ErrOrganizationNotEmpty) {
+		t.Fatalf("deleting an organization with a deleted network got %v, want ErrOrganizationNotEmpty", err)
+	}
+
+	if _, err := models.PurgeDeleted(database.Conn, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteOrganization(ctx, org.ID.String()); err != nil {
+		t.Errorf("deleting an empty organization got %v", err)
+	}
+}
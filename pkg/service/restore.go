@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RestoreNetwork restores a deleted network together with the hosts that were deleted with it.
+// Its passphrase and CA keys are left out unless the caller may read its keys.
+func RestoreNetwork(ctx context.Context, id string) (*models.Network, error) {
+	var n models.Network
+
+	if err := db(ctx).Unscoped().Omit(clause.Associations).First(&n, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, n.ID, models.ActionNetworkDelete); err != nil {
+		return nil, err
+	}
+
+	err := db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := n.Restore(tx); err != nil {
+			return err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionRestore, models.AuditResourceNetwork, n.ID, &n.ID, nil, n); err != nil {
+			return err
+		}
+
+		return NotifyConfigChanges(tx, n.ID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !canReadKeys(ctx, n.ID) {
+		n.RedactKeys()
+	}
+
+	return &n, nil
+}
+
+// RestoreHost restores a deleted host with its certificate and configuration.
+func RestoreHost(ctx context.Context, id string) (*models.Host, error) {
+	var host models.Host
+
+	if err := db(ctx).Unscoped().Preload("Configuration").First(&host, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, host.NetworkID, models.ActionHostDelete); err != nil {
+		return nil, err
+	}
+
+	err := db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := host.Restore(tx); err != nil {
+			return err
+		}
+
+		if err := Audit(ctx, tx, models.AuditActionRestore, models.AuditResourceHost, host.ID, &host.NetworkID, nil, host); err != nil {
+			return err
+		}
+
+		if err := QueueHostEvent(tx, models.WebhookEventHostRestored, host); err != nil {
+			return err
+		}
+
+		return NotifyConfigChanges(tx, host.NetworkID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+)
+
+func createTestHost(t *testing.T, ctx context.Context, n *models.Network, name, ip string) *models.Host {
+	t.Helper()
+
+	host, _, err := CreateHost(ctx, testHostDto(n, name, ip), WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return host
+}
+
+func countHosts(t *testing.T, n *models.Network) int64 {
+	t.Helper()
+
+	var count int64
+	if err := database.Conn.Model(&models.Host{}).Where("network_id = ?", n.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestRestoreNetworkChecksHostQuota(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeAdmin, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+
+	org, err := CreateOrganization(ctx, models.OrganizationDto{Name: "payments", MaxHosts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newNetwork := func(name string) *models.Network {
+		dto := testNetworkDto(name)
+		dto.OrganizationID = &org.ID
+
+		n, err := CreateNetwork(ctx, dto)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return n
+	}
+
+	office := newNetwork("office")
+	createTestHost(t, ctx, office, "web", "100.100.0.1/16")
+	createTestHost(t, ctx, office, "db", "100.100.0.2/16")
+
+	if err := DeleteNetwork(ctx, office.ID.String(), office.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hosts of the deleted network no longer count, so another network takes their room
+	lab := newNetwork("lab")
+	host := createTestHost(t, ctx, lab, "ci", "100.100.0.1/16")
+
+	if _, err := RestoreNetwork(ctx, office.ID.String()); !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Fatalf("restoring 2 hosts into 1 free host got %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := GetNetwork(ctx, office.ID.String()); err == nil {
+		t.Fatal("the network was restored over the host quota")
+	}
+
+	if err := DeleteHost(ctx, host.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RestoreNetwork(ctx, office.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if count := countHosts(t, office); count != 2 {
+		t.Errorf("restored %d hosts, want 2", count)
+	}
+}
+
+func TestRestoreHostOfDeletedNetwork(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+
+	n, err := CreateNetwork(ctx, testNetworkDto("office"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := createTestHost(t, ctx, n, "web", "100.100.0.1/16")
+	createTestHost(t, ctx, n, "db", "100.100.0.2/16")
+
+	// web is deleted on its own before the network
+	if err := DeleteHost(ctx, web.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteNetwork(ctx, n.ID.String(), n.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RestoreHost(ctx, web.ID.String()); !errors.Is(err, models.ErrNetworkDeleted) {
+		t.Fatalf("restoring a host of a deleted network got %v, want ErrNetworkDeleted", err)
+	}
+
+	// Only the hosts deleted with the network are restored with it
+	if _, err := RestoreNetwork(ctx, n.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if count := countHosts(t, n); count != 1 {
+		t.Errorf("restored %d hosts with the network, want 1", count)
+	}
+
+	if _, err := RestoreHost(ctx, web.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if count := countHosts(t, n); count != 2 {
+		t.Errorf("%d hosts after restoring web, want 2", count)
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	connectTestDB(t)
+	ctx := newTestCaller(t, models.ScopeNetworksWrite, models.ScopeHostsWrite)
+
+	office, err := CreateNetwork(ctx, testNetworkDto("office"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lab, err := CreateNetwork(ctx, testNetworkDto("lab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := createTestHost(t, ctx, office, "web", "100.100.0.1/16")
+	ci := createTestHost(t, ctx, lab, "ci", "100.100.0.1/16")
+
+	if err := DeleteHost(ctx, web.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteNetwork(ctx, lab.ID.String(), lab.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing was deleted before the cutoff
+	if purged, err := models.PurgeDeleted(database.Conn, time.Now().Add(-time.Minute)); err != nil || purged != 0 {
+		t.Fatalf("PurgeDeleted() = %d, %v, want nothing purged", purged, err)
+	}
+
+	purged, err := models.PurgeDeleted(database.Conn, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("purged %d, want the lab network and web", purged)
+	}
+
+	for _, id := range []any{lab.ID, web.ID, ci.ID} {
+		var count int64
+		database.Conn.Unscoped().Model(&models.Host{}).Where("id = ?", id).Count(&count)
+		if id == lab.ID {
+			database.Conn.Unscoped().Model(&models.Network{}).Where("id = ?", id).Count(&count)
+		}
+		if count != 0 {
+			t.Errorf("%v was not purged", id)
+		}
+
+		database.Conn.Model(&models.Certificate{}).Where("owner_id = ?", id).Count(&count)
+		if count != 0 {
+			t.Errorf("the certificates of %v were not purged", id)
+		}
+	}
+
+	if _, err := GetNetwork(ctx, office.ID.String()); err != nil {
+		t.Errorf("the live network was purged: %v", err)
+	}
+
+	var events int64
+	database.Conn.Model(&models.AuditEvent{}).Where("actor_name = ? AND action = ?", "purge", models.AuditActionDelete).Count(&events)
+	if events == 0 {
+		t.Error("the purge was not recorded in the audit log")
+	}
+}
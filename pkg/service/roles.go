@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm/clause"
+)
+
+// ListRoleBindings returns which API tokens hold which role on a network.
+func ListRoleBindings(ctx context.Context, networkID string) ([]models.RoleBinding, error) {
+	n, err := findNetwork(ctx, networkID, models.ActionRolesManage)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := []models.RoleBinding{}
+	if err := db(ctx).Find(&bindings, "network_id = ?", n.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return bindings, nil
+}
+
+// GrantRole grants an API token a role on a network, replacing the role it held before.
+// Roles never cross organization boundaries.
+func GrantRole(ctx context.Context, networkID string, dto models.RoleBindingDto) (*models.RoleBinding, error) {
+	n, err := findNetwork(ctx, networkID, models.ActionRolesManage)
+	if err != nil {
+		return nil, err
+	}
+
+	var t models.APIToken
+	if err := db(ctx).First(&t, "id = ?", dto.TokenID).Error; err != nil {
+		return nil, err
+	}
+
+	if !t.InOrganization(n.OrganizationID) {
+		return nil, &ForbiddenError{Message: "The API token belongs to another organization."}
+	}
+
+	binding := models.RoleBinding{
+		NetworkID: n.ID,
+		TokenID:   t.ID,
+		Role:      dto.Role,
+	}
+
+	err = db(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "network_id"}, {Name: "token_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(&binding).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh the binding, which keeps its ID when it replaced an existing one
+	if err := db(ctx).First(&binding, "network_id = ? AND token_id = ?", n.ID, t.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &binding, nil
+}
+
+// RevokeRole deletes a role binding of a network.
+func RevokeRole(ctx context.Context, networkID, bindingID string) error {
+	var binding models.RoleBinding
+
+	if err := db(ctx).First(&binding, "id = ? AND network_id = ?", bindingID, networkID).Error; err != nil {
+		return err
+	}
+
+	if err := authorize(ctx, binding.NetworkID, models.ActionRolesManage); err != nil {
+		return err
+	}
+
+	return db(ctx).Delete(&binding).Error
+}
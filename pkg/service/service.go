@@ -0,0 +1,42 @@
+// Package service implements the operations on networks, hosts, certificates and configs that the REST API
+// in pkg/api and the gRPC API in pkg/rpc share: authorization, the audit log, webhook events and config watches.
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Caller is who makes a request, for authorization and the audit log.
+type Caller struct {
+	Token     *models.APIToken
+	RequestID string
+	SourceIP  string
+}
+
+type callerKey struct{}
+
+// NewContext returns a context carrying the caller of a request.
+func NewContext(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller of the request of ctx. Without one, every operation is forbidden.
+func CallerFromContext(ctx context.Context) Caller {
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+// WriteOptions control how a change is made.
+type WriteOptions struct {
+	IfMatch string // ETags the resource must still have, as in an If-Match header. Empty skips the check.
+	DryRun  bool   // Roll the change back, and return the config diff of every affected host instead.
+}
+
+// db returns the database connection bound to ctx, so that queries stop at its deadline.
+func db(ctx context.Context) *gorm.DB {
+	return database.Conn.WithContext(ctx)
+}
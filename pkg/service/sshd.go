@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ToggleNetworkSSHD enables or disables the nebula sshd on every host of a network, or on the hosts in the groups
+// of dto, and returns the updated hosts.
+func ToggleNetworkSSHD(ctx context.Context, networkID string, dto models.SSHDToggleDto) ([]models.Host, error) {
+	n, err := findNetwork(ctx, networkID, models.ActionHostUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []models.Host
+	if err := db(ctx).Preload("Configuration").Find(&hosts, "network_id = ?", n.ID).Error; err != nil {
+		return nil, err
+	}
+
+	updated := []models.Host{}
+	err = db(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, host := range hosts {
+			if host.Configuration == nil || !host.InGroups(dto.Groups) {
+				continue
+			}
+
+			before := host
+			cfg := *host.Configuration
+			before.Configuration = &cfg
+
+			host.Configuration.SSHD.Enabled = dto.Enabled
+			if dto.Listen != "" {
+				host.Configuration.SSHD.Listen = dto.Listen
+			}
+
+			if err := models.BumpVersion(tx, &models.Configuration{}, host.Configuration.ID, host.Configuration.Version); err != nil {
+				return err
+			}
+			host.Configuration.Version++
+
+			if err := tx.Model(host.Configuration).Select("SSHD").Updates(host.Configuration).Error; err != nil {
+				return err
+			}
+
+			// Hosts created before koodnet managed sshd host keys get one on first enable
+			if dto.Enabled && len(host.SSHHostKey) == 0 {
+				if err := host.GenerateSSHHostKey(); err != nil {
+					return err
+				}
+
+				if err := models.BumpVersion(tx, &models.Host{}, host.ID, host.Version); err != nil {
+					return err
+				}
+				host.Version++
+
+				if err := tx.Model(&host).Select("SSHHostKey", "SSHHostPub").Updates(&host).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := Audit(ctx, tx, models.AuditActionUpdate, models.AuditResourceHost, host.ID, &host.NetworkID, before, host); err != nil {
+				return err
+			}
+
+			if err := QueueHostEvent(tx, models.WebhookEventHostUpdated, host); err != nil {
+				return err
+			}
+
+			updated = append(updated, host)
+		}
+
+		return NotifyConfigChanges(tx, n.ID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ListAPITokens returns the API tokens visible to the caller, paginated by params.
+func ListAPITokens(ctx context.Context, params models.ListParams) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+
+	query := db(ctx).Model(&models.APIToken{})
+	if token := CallerFromContext(ctx).Token; token != nil && token.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *token.OrganizationID)
+	}
+
+	if err := query.Scopes(models.Paginate(params)).Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// CreateAPIToken creates an API token and returns it with its secret, which is not stored.
+// Tokens confined to an organization create tokens in it, and are forbidden to ask for another one.
+func CreateAPIToken(ctx context.Context, dto models.APITokenDto) (*models.APIToken, string, error) {
+	caller := CallerFromContext(ctx).Token
+	if caller == nil {
+		return nil, "", &ForbiddenError{Message: "An API token is required."}
+	}
+
+	if caller.OrganizationID != nil {
+		if dto.OrganizationID != nil && !caller.InOrganization(dto.OrganizationID) {
+			return nil, "", &ForbiddenError{Message: "API tokens can only be created in the organization of the API token."}
+		}
+
+		dto.OrganizationID = caller.OrganizationID
+	}
+
+	t, raw, err := models.NewAPIToken(dto.Name, dto.Scopes, dto.ExpiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	t.OrganizationID = dto.OrganizationID
+
+	if err := db(ctx).Create(t).Error; err != nil {
+		return nil, "", err
+	}
+
+	return t, raw, nil
+}
+
+// RevokeAPIToken revokes an API token, which is kept for reference. Tokens of other organizations are not found.
+func RevokeAPIToken(ctx context.Context, id string) error {
+	var t models.APIToken
+
+	if err := db(ctx).First(&t, "id = ?", id).Error; err != nil {
+		return err
+	}
+
+	if caller := CallerFromContext(ctx).Token; caller == nil || !caller.InOrganization(t.OrganizationID) {
+		return gorm.ErrRecordNotFound
+	}
+
+	return db(ctx).Model(&t).Update("revoked_at", time.Now()).Error
+}
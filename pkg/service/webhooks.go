@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+
+	"github.com/koodeyo/koodnet/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ListWebhooks returns the webhooks visible to the caller, paginated by params.
+func ListWebhooks(ctx context.Context, params models.ListParams) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+
+	query := db(ctx).Model(&models.Webhook{})
+	if token := CallerFromContext(ctx).Token; token != nil && token.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *token.OrganizationID)
+	}
+
+	if err := query.Scopes(models.Paginate(params)).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhook returns a webhook. Webhooks of other organizations are not found.
+func GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	var w models.Webhook
+
+	if err := db(ctx).First(&w, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if token := CallerFromContext(ctx).Token; token == nil || !token.InOrganization(w.OrganizationID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &w, nil
+}
+
+// CreateWebhook registers a webhook in the caller's organization. Its secret is only set on the returned webhook.
+// Webhooks limited to a network require the caller to be allowed to read the network.
+func CreateWebhook(ctx context.Context, dto models.WebhookDto) (*models.Webhook, error) {
+	token := CallerFromContext(ctx).Token
+	if token == nil {
+		return nil, &ForbiddenError{Message: "An API token is required."}
+	}
+
+	if dto.NetworkID != nil {
+		if err := authorize(ctx, *dto.NetworkID, models.ActionNetworkRead); err != nil {
+			return nil, err
+		}
+	}
+
+	w := models.Webhook{
+		Name:           dto.Name,
+		URL:            dto.URL,
+		Events:         dto.Events,
+		NetworkID:      dto.NetworkID,
+		OrganizationID: token.OrganizationID,
+		Active:         dto.Active == nil || *dto.Active,
+	}
+
+	if w.Events == nil {
+		w.Events = []string{}
+	}
+
+	if err := db(ctx).Create(&w).Error; err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// UpdateWebhook updates the set fields of dto on a webhook.
+func UpdateWebhook(ctx context.Context, id string, dto models.WebhookDto) (*models.Webhook, error) {
+	w, err := GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if dto.NetworkID != nil {
+		if err := authorize(ctx, *dto.NetworkID, models.ActionNetworkRead); err != nil {
+			return nil, err
+		}
+	}
+
+	if dto.Name != "" {
+		w.Name = dto.Name
+	}
+	if dto.URL != "" {
+		w.URL = dto.URL
+	}
+	if dto.Events != nil {
+		w.Events = dto.Events
+	}
+	if dto.NetworkID != nil {
+		w.NetworkID = dto.NetworkID
+	}
+	if dto.Active != nil {
+		w.Active = *dto.Active
+	}
+
+	if err := db(ctx).Save(w).Error; err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// DeleteWebhook deletes a webhook and its pending deliveries.
+func DeleteWebhook(ctx context.Context, id string) error {
+	w, err := GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return db(ctx).Delete(w).Error
+}
+
+// ListWebhookDeliveries returns the deliveries of a webhook, newest first, optionally only those with status,
+// paginated by params.
+func ListWebhookDeliveries(ctx context.Context, id, status string, params models.ListParams) ([]models.WebhookDelivery, error) {
+	w, err := GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []models.WebhookDelivery
+
+	query := db(ctx).Model(&models.WebhookDelivery{}).Where("webhook_id = ?", w.ID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Scopes(models.Paginate(params)).Order("created_at DESC").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,177 @@
+// Package webhooks sends the webhook events queued in the outbox by the API.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	expiryScanInterval = time.Hour
+	requestTimeout     = 10 * time.Second
+	claimBatchSize     = 50
+)
+
+// Dispatcher polls the webhook outbox, sends due deliveries and queues certificate.expiring events.
+// Several API servers sharing a database can each run one.
+type Dispatcher struct {
+	DB           *gorm.DB
+	Client       *http.Client
+	Logger       *logrus.Logger
+	PollInterval time.Duration // How often the outbox is checked for due deliveries.
+	ExpiryWindow time.Duration // How long before expiry certificate.expiring is sent.
+}
+
+// NewDispatcher creates a dispatcher with the intervals of cfg.
+func NewDispatcher(db *gorm.DB, l *logrus.Logger, cfg config.WebhooksConfig) *Dispatcher {
+	return &Dispatcher{
+		DB:           db,
+		Client:       &http.Client{Timeout: requestTimeout},
+		Logger:       l,
+		PollInterval: cfg.PollInterval,
+		ExpiryWindow: cfg.ExpiryWindow,
+	}
+}
+
+// Run sends deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	var lastScan time.Time
+	for {
+		if time.Since(lastScan) >= expiryScanInterval {
+			if err := d.queueExpiringCertificates(); err != nil {
+				d.Logger.WithError(err).Error("Failed to queue certificate.expiring webhook events")
+			}
+			lastScan = time.Now()
+		}
+
+		if err := d.deliverDue(ctx); err != nil {
+			d.Logger.WithError(err).Error("Failed to send webhook deliveries")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue sends every delivery that is due.
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	for ctx.Err() == nil {
+		// Deliveries stay claimed for longer than it takes to send the batch
+		deliveries, err := models.ClaimWebhookDeliveries(d.DB, claimBatchSize, claimBatchSize*requestTimeout)
+		if err != nil {
+			return err
+		}
+
+		for i := range deliveries {
+			d.deliver(ctx, &deliveries[i])
+		}
+
+		if len(deliveries) < claimBatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	var webhook models.Webhook
+	status, err := 0, d.DB.First(&webhook, "id = ?", delivery.WebhookID).Error
+	if err == nil {
+		status, err = d.send(ctx, &webhook, delivery)
+	}
+
+	if err != nil {
+		d.Logger.WithFields(logrus.Fields{
+			"webhook":  delivery.WebhookID,
+			"delivery": delivery.ID,
+			"event":    delivery.Event,
+			"attempt":  delivery.Attempts + 1,
+		}).WithError(err).Warn("Webhook delivery failed")
+	}
+
+	if err := delivery.RecordAttempt(d.DB, status, err); err != nil {
+		d.Logger.WithError(err).Error("Failed to record webhook delivery attempt")
+	}
+}
+
+// send POSTs the delivery to the webhook. Any status other than 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "koodnet-webhooks")
+	req.Header.Set("X-Koodnet-Event", delivery.Event)
+	req.Header.Set("X-Koodnet-Delivery", delivery.ID.String())
+	req.Header.Set("X-Koodnet-Signature", webhook.Signature(time.Now(), body))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+// queueExpiringCertificates queues certificate.expiring once for every certificate that expires within the window.
+func (d *Dispatcher) queueExpiringCertificates() error {
+	certs, err := models.ExpiringCertificates(d.DB, d.ExpiryWindow)
+	if err != nil {
+		return err
+	}
+
+	for _, crt := range certs {
+		err := d.DB.Transaction(func(tx *gorm.DB) error {
+			marked, err := crt.MarkExpiryNotified(tx)
+			if err != nil || !marked {
+				return err
+			}
+
+			networkID, err := crt.NetworkID(tx)
+			if err == nil {
+				crt.RedactKeys()
+				err = models.EnqueueWebhookEvent(tx, models.WebhookEventCertificateExpiring, networkID, crt)
+			}
+
+			// Certificates left behind by deleted hosts or networks have no one to notify
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+
+			return err
+		})
+
+		if err != nil {
+			d.Logger.WithField("certificate", crt.ID).WithError(err).Error("Failed to queue certificate.expiring webhook event")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koodeyo/koodnet/pkg/config"
+	"github.com/koodeyo/koodnet/pkg/database"
+	"github.com/koodeyo/koodnet/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDispatcher returns a dispatcher on a fresh SQLite database, with a webhook for url subscribed to
+// the events of a network.
+func newTestDispatcher(t *testing.T, url string) (*Dispatcher, *models.Webhook, *models.Network) {
+	t.Helper()
+
+	database.Connect(config.DatabaseConfig{SQLitePath: filepath.Join(t.TempDir(), "koodnet.db")})
+	database.Conn.Logger = logger.Discard
+	database.Migrate()
+
+	n := models.Network{Name: "office", IPs: []string{"100.100.0.0/16"}, Duration: 24 * time.Hour, Curve: "25519"}
+	if err := database.Conn.Create(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	webhook := models.Webhook{Name: "deploy", URL: url, Active: true}
+	if err := database.Conn.Create(&webhook).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	return NewDispatcher(database.Conn, l, config.WebhooksConfig{PollInterval: time.Second, ExpiryWindow: time.Hour}), &webhook, &n
+}
+
+// testDelivery returns the only delivery in the outbox.
+func testDelivery(t *testing.T) models.WebhookDelivery {
+	t.Helper()
+
+	var deliveries []models.WebhookDelivery
+	if err := database.Conn.Find(&deliveries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+
+	return deliveries[0]
+}
+
+func TestDeliverySignature(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header, body}
+	}))
+	defer server.Close()
+
+	d, webhook, n := newTestDispatcher(t, server.URL)
+	if err := models.EnqueueWebhookEvent(database.Conn, models.WebhookEventHostCreated, n.ID, map[string]string{"name": "web"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.deliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if event := req.header.Get("X-Koodnet-Event"); event != models.WebhookEventHostCreated {
+		t.Errorf("X-Koodnet-Event is %q, want %s", event, models.WebhookEventHostCreated)
+	}
+
+	// Receivers verify the signature with the secret of the webhook
+	var timestamp, signature string
+	for _, part := range strings.Split(req.header.Get("X-Koodnet-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "." + string(req.body)))
+	if want := hex.EncodeToString(mac.Sum(nil)); timestamp == "" || !hmac.Equal([]byte(signature), []byte(want)) {
+		t.Errorf("signature %q does not match the HMAC %s of the body", req.header.Get("X-Koodnet-Signature"), want)
+	}
+
+	var event models.WebhookEvent
+	if err := json.Unmarshal(req.body, &event); err != nil || event.Type != models.WebhookEventHostCreated || event.NetworkID != n.ID {
+		t.Errorf("got body %s, want a host.created event of the network", req.body)
+	}
+
+	if delivery := testDelivery(t); delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 1 {
+		t.Errorf("delivery is %s after %d attempts, want delivered after 1", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestDeliveryRetriesServerErrors(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	d, _, n := newTestDispatcher(t, server.URL)
+	if err := models.EnqueueWebhookEvent(database.Conn, models.WebhookEventConfigChanged, n.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.deliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := testDelivery(t)
+	if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 || delivery.LastStatusCode != status {
+		t.Fatalf("delivery is %s after %d attempts with status %d, want pending after 1 with %d", delivery.Status, delivery.Attempts, delivery.LastStatusCode, status)
+	}
+	if !delivery.NextAttemptAt.After(time.Now()) {
+		t.Errorf("the retry is due at %v, want a backoff", delivery.NextAttemptAt)
+	}
+
+	// Not retried before the backoff ends
+	if err := d.deliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if delivery := testDelivery(t); delivery.Attempts != 1 {
+		t.Errorf("delivery was attempted %d times during the backoff, want 1", delivery.Attempts)
+	}
+
+	status = http.StatusOK
+	if err := database.Conn.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.deliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery := testDelivery(t); delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 2 || delivery.LastError != "" {
+		t.Errorf("delivery is %s after %d attempts with error %q, want delivered after 2", delivery.Status, delivery.Attempts, delivery.LastError)
+	}
+}